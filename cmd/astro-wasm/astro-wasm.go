@@ -4,8 +4,7 @@
 package main
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
@@ -14,6 +13,7 @@ import (
 	"github.com/norunners/vert"
 	astro "github.com/withastro/compiler/internal"
 	"github.com/withastro/compiler/internal/printer"
+	"github.com/withastro/compiler/internal/service"
 	t "github.com/withastro/compiler/internal/t"
 	"github.com/withastro/compiler/internal/transform"
 	wasm_utils "github.com/withastro/compiler/internal_wasm/utils"
@@ -26,6 +26,10 @@ func main() {
 	module := js.Global().Get("@astrojs/compiler")
 	module.Set("transform", Transform())
 	module.Set("parse", Parse())
+	module.Set("transformSync", TransformSync())
+	module.Set("parseSync", ParseSync())
+	module.Set("fromJSON", FromJSON())
+	module.Set("fromJSONSync", FromJSONSync())
 
 	<-make(chan struct{})
 }
@@ -45,103 +49,86 @@ func jsBool(j js.Value) bool {
 }
 
 func makeParseOptions(options js.Value) t.ParseOptions {
-	position := true
-
-	pos := options.Get("position")
-	if !pos.IsNull() && !pos.IsUndefined() {
-		position = pos.Bool()
-	}
-
-	return t.ParseOptions{
-		Position: position,
+	parseOptions := service.ParseOptions{}
+	if pos := options.Get("position"); !pos.IsNull() && !pos.IsUndefined() {
+		position := pos.Bool()
+		parseOptions.Position = &position
 	}
+	parseOptions.FrontmatterAST = jsBool(options.Get("frontmatterAST"))
+	parseOptions.ExpressionAST = jsBool(options.Get("expressionAST"))
+	return parseOptions.ParseOptions()
 }
 
 func makeTransformOptions(options js.Value, hash string) transform.TransformOptions {
-	filename := jsString(options.Get("sourcefile"))
-	if filename == "" {
-		filename = "<stdin>"
-	}
-
-	pathname := jsString(options.Get("pathname"))
-	if pathname == "" {
-		pathname = "<stdin>"
-	}
-
-	internalURL := jsString(options.Get("internalURL"))
-	if internalURL == "" {
-		internalURL = "astro/internal"
-	}
-
-	sourcemap := jsString(options.Get("sourcemap"))
-	if sourcemap == "<boolean: true>" {
-		sourcemap = "both"
-	}
-
-	site := jsString(options.Get("site"))
-	if site == "" {
-		site = "https://astro.build"
+	sourcemap := service.SourceMapOption(jsString(options.Get("sourcemap")))
+	if value := options.Get("sourcemap"); value.Type() == js.TypeBoolean {
+		sourcemap = ""
+		if value.Bool() {
+			sourcemap = "both"
+		}
 	}
 
-	projectRoot := jsString(options.Get("projectRoot"))
-	if projectRoot == "" {
-		projectRoot = "."
+	directives := make([]service.Directive, 0)
+	if value := options.Get("directives"); value.Type() == js.TypeObject {
+		for i := 0; i < value.Length(); i++ {
+			directive := value.Index(i)
+			directives = append(directives, service.Directive{
+				Namespace: jsString(directive.Get("namespace")),
+				Specifier: jsString(directive.Get("specifier")),
+				Export:    jsString(directive.Get("export")),
+				Target:    jsString(directive.Get("target")),
+			})
+		}
 	}
 
-	staticExtraction := false
-	if jsBool(options.Get("experimentalStaticExtraction")) {
-		staticExtraction = true
+	var clientDirectives []string
+	if value := options.Get("clientDirectives"); value.Type() == js.TypeObject {
+		clientDirectives = make([]string, 0)
+		for i := 0; i < value.Length(); i++ {
+			clientDirectives = append(clientDirectives, jsString(value.Index(i)))
+		}
 	}
 
-	preprocessStyle := options.Get("preprocessStyle")
-
-	return transform.TransformOptions{
-		Scope:            hash,
-		Filename:         filename,
-		Pathname:         pathname,
-		InternalURL:      internalURL,
+	transformOptions := service.Options{
+		Sourcefile:       jsString(options.Get("sourcefile")),
+		Pathname:         jsString(options.Get("pathname")),
+		InternalURL:      jsString(options.Get("internalURL")),
 		SourceMap:        sourcemap,
-		Site:             site,
-		ProjectRoot:      projectRoot,
-		PreprocessStyle:  preprocessStyle,
-		StaticExtraction: staticExtraction,
-	}
-}
-
-type RawSourceMap struct {
-	File           string   `js:"file"`
-	Mappings       string   `js:"mappings"`
-	Names          []string `js:"names"`
-	Sources        []string `js:"sources"`
-	SourcesContent []string `js:"sourcesContent"`
-	Version        int      `js:"version"`
-}
-
-type HoistedScript struct {
-	Code string `js:"code"`
-	Src  string `js:"src"`
-	Type string `js:"type"`
-}
-
-type ParseResult struct {
-	AST string `js:"ast"`
+		Site:             jsString(options.Get("site")),
+		ProjectRoot:      jsString(options.Get("projectRoot")),
+		StaticExtraction: jsBool(options.Get("experimentalStaticExtraction")),
+		Directives:       directives,
+		ClientDirectives: clientDirectives,
+	}.TransformOptions(hash)
+	// JS preprocessors are run by Transform, because they return promises
+	transformOptions.PreprocessStyle = options.Get("preprocessStyle")
+	transformOptions.PreprocessScript = options.Get("preprocessScript")
+	return transformOptions
 }
 
-type TransformResult struct {
-	Code    string          `js:"code"`
-	Map     string          `js:"map"`
-	CSS     []string        `js:"css"`
-	Scripts []HoistedScript `js:"scripts"`
+type ErrorLocation struct {
+	File   string `js:"file"`
+	Line   int    `js:"line"`
+	Column int    `js:"column"`
 }
 
-// This is spawned as a goroutine to preprocess style nodes using an async function passed from JS
-func preprocessStyle(i int, style *astro.Node, transformOptions transform.TransformOptions, cb func()) {
+// This is spawned as a goroutine to preprocess style and script nodes using an async function passed from JS
+func preprocessNode(preprocessor js.Value, n *astro.Node, sourcemap *string, err *error, cb func()) {
 	defer cb()
-	if style.FirstChild == nil {
+	defer func() {
+		if r := recover(); r != nil {
+			*err = &transform.PreprocessorError{Err: recoveredError(r), Element: n.Data, Loc: n.Loc[0]}
+		}
+	}()
+	if n.FirstChild == nil {
+		return
+	}
+	attrs := wasm_utils.GetAttrs(n)
+	data, rejected := wasm_utils.Await(preprocessor.Invoke(n.FirstChild.Data, attrs))
+	if rejected != nil {
+		*err = &transform.PreprocessorError{Err: jsError(rejected[0]), Element: n.Data, Loc: n.Loc[0]}
 		return
 	}
-	attrs := wasm_utils.GetAttrs(style)
-	data, _ := wasm_utils.Await(transformOptions.PreprocessStyle.(js.Value).Invoke(style.FirstChild.Data, attrs))
 	// note: Rollup (and by extension our Astro Vite plugin) allows for "undefined" and "null" responses if a transform wishes to skip this occurrence
 	if data[0].Equal(js.Undefined()) || data[0].Equal(js.Null()) {
 		return
@@ -150,24 +137,161 @@ func preprocessStyle(i int, style *astro.Node, transformOptions transform.Transf
 	if str == "" {
 		return
 	}
-	style.FirstChild.Data = str
+	n.FirstChild.Data = str
+	// Some preprocessors return maps as objects or buffers, which aren't supported
+	if value := data[0].Get("map"); value.Type() == js.TypeString {
+		*sourcemap = value.String()
+	}
+}
+
+// jsError converts a value thrown by JS into an error
+func jsError(value js.Value) error {
+	if value.Type() == js.TypeObject && value.Get("message").Type() == js.TypeString {
+		return errors.New(value.Get("message").String())
+	}
+	return errors.New(jsString(value))
+}
+
+// recoveredError converts a value recovered from a panic into an error
+func recoveredError(r interface{}) error {
+	if err, ok := r.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", r)
+}
+
+// makeError converts err into a JS Error for rejecting promises, with the
+// `loc` and `frame` properties that Vite uses to display errors
+func makeError(source string, filename string, err error) js.Value {
+	e := printer.PrintError(source, filename, err)
+	value := js.Global().Get("Error").New(e.Message)
+	value.Set("code", e.Code)
+	if e.Location != nil {
+		value.Set("loc", vert.ValueOf(ErrorLocation{
+			File:   e.Location.File,
+			Line:   e.Location.Line,
+			Column: e.Location.Column,
+		}))
+		value.Set("frame", e.Frame)
+	}
+	return value
 }
 
 func Parse() interface{} {
 	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		source := jsString(args[0])
 		parseOptions := makeParseOptions(js.Value(args[1]))
+		filename := jsString(js.Value(args[1]).Get("sourcefile"))
+		if filename == "" {
+			filename = "<stdin>"
+		}
 
-		var doc *astro.Node
-		doc, err := astro.Parse(strings.NewReader(source))
+		handler := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			resolve := args[0]
+			reject := args[1]
+
+			defer func() {
+				if r := recover(); r != nil {
+					reject.Invoke(makeError(source, filename, recoveredError(r)))
+				}
+			}()
+
+			value, err := parseSource(source, parseOptions)
+			if err != nil {
+				reject.Invoke(makeError(source, filename, err))
+				return nil
+			}
+			resolve.Invoke(value)
+			return nil
+		})
+		defer handler.Release()
+
+		// Create and return the Promise object
+		promiseConstructor := js.Global().Get("Promise")
+		return promiseConstructor.New(handler)
+	})
+}
+
+// parseSource parses source and prints its AST as a ParseResult
+func parseSource(source string, parseOptions t.ParseOptions) (interface{}, error) {
+	result, err := service.Parse(source, parseOptions)
+	if err != nil {
+		return nil, err
+	}
+	return vert.ValueOf(result), nil
+}
+
+// ParseSync is like Parse, but returns the ParseResult directly.
+// Errors are returned as JS Error objects, which the JS wrapper throws.
+func ParseSync() interface{} {
+	return js.FuncOf(func(this js.Value, args []js.Value) (value interface{}) {
+		source := jsString(args[0])
+		parseOptions := makeParseOptions(js.Value(args[1]))
+		filename := jsString(js.Value(args[1]).Get("sourcefile"))
+		if filename == "" {
+			filename = "<stdin>"
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				value = makeError(source, filename, recoveredError(r))
+			}
+		}()
+
+		value, err := parseSource(source, parseOptions)
 		if err != nil {
-			fmt.Println(err)
+			return makeError(source, filename, err)
 		}
-		result := printer.PrintToJSON(source, doc, parseOptions)
+		return value
+	})
+}
+
+func FromJSON() interface{} {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		ast := jsString(args[0])
+
+		handler := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			resolve := args[0]
+			reject := args[1]
+
+			defer func() {
+				if r := recover(); r != nil {
+					reject.Invoke(makeError(ast, "<stdin>", recoveredError(r)))
+				}
+			}()
 
-		return vert.ValueOf(ParseResult{
-			AST: string(result.Output),
+			result, err := service.FromJSON(ast)
+			if err != nil {
+				reject.Invoke(makeError(ast, "<stdin>", err))
+				return nil
+			}
+			resolve.Invoke(vert.ValueOf(result))
+			return nil
 		})
+		defer handler.Release()
+
+		// Create and return the Promise object
+		promiseConstructor := js.Global().Get("Promise")
+		return promiseConstructor.New(handler)
+	})
+}
+
+// FromJSONSync is like FromJSON, but returns the FromJSONResult directly
+func FromJSONSync() interface{} {
+	return js.FuncOf(func(this js.Value, args []js.Value) (value interface{}) {
+		ast := jsString(args[0])
+
+		defer func() {
+			if r := recover(); r != nil {
+				value = makeError(ast, "<stdin>", recoveredError(r))
+			}
+		}()
+
+		result, err := service.FromJSON(ast)
+		if err != nil {
+			return makeError(ast, "<stdin>", err)
+		}
+		return vert.ValueOf(result)
 	})
 }
 
@@ -179,13 +303,20 @@ func Transform() interface{} {
 
 		handler := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 			resolve := args[0]
+			reject := args[1]
 
 			go func() {
-				var doc *astro.Node
+				// A panic would otherwise kill the WASM instance without settling the promise
+				defer func() {
+					if r := recover(); r != nil {
+						reject.Invoke(makeError(source, transformOptions.Filename, recoveredError(r)))
+					}
+				}()
 
 				doc, err := astro.Parse(strings.NewReader(source))
 				if err != nil {
-					fmt.Println(err)
+					reject.Invoke(makeError(source, transformOptions.Filename, err))
+					return
 				}
 
 				// Hoist styles and scripts to the top-level
@@ -194,69 +325,51 @@ func Transform() interface{} {
 				// Pre-process styles
 				// Important! These goroutines need to be spawned from this file or they don't work
 				var wg sync.WaitGroup
+				sourcemaps := make([]string, len(doc.Styles))
+				styleErrors := make([]error, len(doc.Styles))
 				if len(doc.Styles) > 0 {
 					if transformOptions.PreprocessStyle.(js.Value).Type() == js.TypeFunction {
 						for i, style := range doc.Styles {
 							wg.Add(1)
-							i := i
-							go preprocessStyle(i, style, transformOptions, wg.Done)
+							go preprocessNode(transformOptions.PreprocessStyle.(js.Value), style, &sourcemaps[i], &styleErrors[i], wg.Done)
 						}
 					}
 				}
-				// Wait for all the style goroutines to finish
+				// Pre-process scripts before they are hoisted by transform.Transform
+				var scriptErrors []error
+				if transformOptions.PreprocessScript.(js.Value).Type() == js.TypeFunction {
+					scripts := transform.GetPreprocessableScripts(doc)
+					// Script source maps can't be used, because scripts are printed as strings
+					unused := make([]string, len(scripts))
+					scriptErrors = make([]error, len(scripts))
+					for i, script := range scripts {
+						wg.Add(1)
+						go preprocessNode(transformOptions.PreprocessScript.(js.Value), script, &unused[i], &scriptErrors[i], wg.Done)
+					}
+				}
+				// Wait for all the style and script goroutines to finish
 				wg.Wait()
-
-				// Perform CSS and element scoping as needed
-				transform.Transform(doc, transformOptions)
-
-				css := []string{}
-				scripts := []HoistedScript{}
-				// Only perform static CSS extraction if the flag is passed in.
-				if transformOptions.StaticExtraction {
-					css_result := printer.PrintCSS(source, doc, transformOptions)
-					for _, bytes := range css_result.Output {
-						css = append(css, string(bytes))
+				var preprocessErr *transform.PreprocessorError
+				for _, err := range append(styleErrors, scriptErrors...) {
+					// Report the error that comes first in the source, because doc.Styles isn't in document order
+					if err, ok := err.(*transform.PreprocessorError); ok && (preprocessErr == nil || err.Loc.Start < preprocessErr.Loc.Start) {
+						preprocessErr = err
 					}
-
-					// Append hoisted scripts
-					for _, node := range doc.Scripts {
-						src := astro.GetAttribute(node, "src")
-						script := HoistedScript{
-							Src:  "",
-							Code: "",
-							Type: "",
-						}
-						if src != nil {
-							script.Type = "external"
-							script.Src = src.Val
-						} else if node.FirstChild != nil {
-							script.Type = "inline"
-							script.Code = node.FirstChild.Data
+				}
+				if preprocessErr != nil {
+					reject.Invoke(makeError(source, transformOptions.Filename, preprocessErr))
+					return
+				}
+				for i, sourcemap := range sourcemaps {
+					if sourcemap != "" {
+						if doc.StyleSourceMaps == nil {
+							doc.StyleSourceMaps = make(map[*astro.Node]string)
 						}
-						scripts = append(scripts, script)
+						doc.StyleSourceMaps[doc.Styles[i]] = sourcemap
 					}
 				}
 
-				result := printer.PrintToJS(source, doc, len(css), transformOptions)
-
-				var value interface{}
-				switch transformOptions.SourceMap {
-				case "external":
-					value = createExternalSourceMap(source, result, css, &scripts, transformOptions)
-				case "both":
-					value = createBothSourceMap(source, result, css, &scripts, transformOptions)
-				case "inline":
-					value = createInlineSourceMap(source, result, css, &scripts, transformOptions)
-				default:
-					value = vert.ValueOf(TransformResult{
-						CSS:     css,
-						Code:    string(result.Output),
-						Map:     "",
-						Scripts: scripts,
-					})
-				}
-
-				resolve.Invoke(value)
+				resolve.Invoke(transformDocument(source, doc, transformOptions))
 			}()
 
 			return nil
@@ -269,50 +382,41 @@ func Transform() interface{} {
 	})
 }
 
-func createSourceMapString(source string, result printer.PrintResult, transformOptions transform.TransformOptions) string {
-	sourcesContent, _ := json.Marshal(source)
-	sourcemap := RawSourceMap{
-		Version:        3,
-		Sources:        []string{transformOptions.Filename},
-		SourcesContent: []string{string(sourcesContent)},
-		Mappings:       string(result.SourceMapChunk.Buffer),
-	}
-	return fmt.Sprintf(`{
-  "version": 3,
-  "sources": ["%s"],
-  "sourcesContent": [%s],
-  "mappings": "%s",
-  "names": []
-}`, sourcemap.Sources[0], sourcemap.SourcesContent[0], sourcemap.Mappings)
-}
+// TransformSync is like Transform, but runs inline and returns the
+// TransformResult directly. JS preprocessors return promises, which can't be
+// awaited here, so they are rejected with an error.
+func TransformSync() interface{} {
+	return js.FuncOf(func(this js.Value, args []js.Value) (value interface{}) {
+		source := jsString(args[0])
+		hash := astro.HashFromSource(source)
+		transformOptions := makeTransformOptions(js.Value(args[1]), hash)
 
-func createExternalSourceMap(source string, result printer.PrintResult, css []string, scripts *[]HoistedScript, transformOptions transform.TransformOptions) interface{} {
-	return vert.ValueOf(TransformResult{
-		CSS:     css,
-		Code:    string(result.Output),
-		Map:     createSourceMapString(source, result, transformOptions),
-		Scripts: *scripts,
-	})
-}
+		defer func() {
+			if r := recover(); r != nil {
+				value = makeError(source, transformOptions.Filename, recoveredError(r))
+			}
+		}()
+
+		if transformOptions.PreprocessStyle.(js.Value).Type() == js.TypeFunction {
+			return makeError(source, transformOptions.Filename, errors.New("transformSync does not support the async preprocessStyle option, use transform instead"))
+		}
+		if transformOptions.PreprocessScript.(js.Value).Type() == js.TypeFunction {
+			return makeError(source, transformOptions.Filename, errors.New("transformSync does not support the async preprocessScript option, use transform instead"))
+		}
 
-func createInlineSourceMap(source string, result printer.PrintResult, css []string, scripts *[]HoistedScript, transformOptions transform.TransformOptions) interface{} {
-	sourcemapString := createSourceMapString(source, result, transformOptions)
-	inlineSourcemap := `//# sourceMappingURL=data:application/json;charset=utf-8;base64,` + base64.StdEncoding.EncodeToString([]byte(sourcemapString))
-	return vert.ValueOf(TransformResult{
-		CSS:     css,
-		Code:    string(result.Output) + "\n" + inlineSourcemap,
-		Map:     "",
-		Scripts: *scripts,
+		doc, err := astro.Parse(strings.NewReader(source))
+		if err != nil {
+			return makeError(source, transformOptions.Filename, err)
+		}
+
+		// Hoist styles and scripts to the top-level
+		transform.ExtractStyles(doc)
+
+		return transformDocument(source, doc, transformOptions)
 	})
 }
 
-func createBothSourceMap(source string, result printer.PrintResult, css []string, scripts *[]HoistedScript, transformOptions transform.TransformOptions) interface{} {
-	sourcemapString := createSourceMapString(source, result, transformOptions)
-	inlineSourcemap := `//# sourceMappingURL=data:application/json;charset=utf-8;base64,` + base64.StdEncoding.EncodeToString([]byte(sourcemapString))
-	return vert.ValueOf(TransformResult{
-		CSS:     css,
-		Code:    string(result.Output) + "\n" + inlineSourcemap,
-		Map:     sourcemapString,
-		Scripts: *scripts,
-	})
+// transformDocument transforms and prints a parsed and preprocessed doc as a TransformResult
+func transformDocument(source string, doc *astro.Node, transformOptions transform.TransformOptions) interface{} {
+	return vert.ValueOf(service.TransformDocument(source, doc, transformOptions))
 }
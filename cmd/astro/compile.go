@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/withastro/compiler/internal/loc"
+	"github.com/withastro/compiler/internal/printer"
+	"github.com/withastro/compiler/internal/service"
+)
+
+const compileUsage = `Usage: astro compile [flags] <file or directory>...
+
+Compiles .astro files in parallel. Directories are searched for .astro files.
+
+Without --outdir, one JSON object is written to stdout for each file, in the
+same order as the inputs: {"path": string, "result": TransformResult} or
+{"path": string, "error": CompileError}.
+
+With --outdir, each file is written to <outdir>/<path>.ts, where <path> is
+relative to the input directory, and diagnostics are printed to stderr. Files
+passed directly are written to <outdir>/<name>.ts. It's an error for two inputs
+to have the same output path.
+
+Results are cached in --cache-dir, so unchanged files aren't compiled again.
+
+With --watch, the inputs are polled for changes after the first compile.
+Changed files are compiled again, and the output of deleted files is removed.
+--watch requires --outdir.
+
+Flags:
+`
+
+// errCompileFailed is returned when at least one file has errors, which have already been reported
+var errCompileFailed = errors.New("compilation failed")
+
+// An inputFile is a file to compile
+type inputFile struct {
+	Path string
+	// Rel is the path of the file relative to its input directory, which is used for output paths
+	Rel string
+}
+
+type compileFlags struct {
+	outdir      string
+	concurrency int
+	options     service.Options
+	cache       *service.Cache
+}
+
+func compile(args []string) error {
+	flags := flag.NewFlagSet("compile", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), compileUsage)
+		flags.PrintDefaults()
+	}
+	var cf compileFlags
+	var sourcemap string
+	flags.StringVar(&cf.outdir, "outdir", "", "write compiled files to this directory instead of stdout")
+	flags.IntVar(&cf.concurrency, "concurrency", 0, "number of files to compile at once (default GOMAXPROCS)")
+	flags.StringVar(&sourcemap, "sourcemap", "", `generate source maps: "external", "inline" or "both"`)
+	flags.StringVar(&cf.options.Site, "site", "", "the site option passed to Astro.site")
+	flags.StringVar(&cf.options.InternalURL, "internal-url", "", `the module that runtime helpers are imported from (default "astro/internal")`)
+	flags.StringVar(&cf.options.ProjectRoot, "project-root", "", "the project root used for component metadata")
+	flags.BoolVar(&cf.options.StaticExtraction, "static-extraction", false, "extract styles and hoisted scripts")
+	noCache := flags.Bool("no-cache", false, "don't read or write the compile cache")
+	cacheDir := flags.String("cache-dir", defaultCacheDir(), "the directory of the compile cache")
+	cacheMaxSize := flags.Int64("cache-max-size", service.DefaultCacheMaxSize, "the size limit of the compile cache in bytes")
+	watchMode := flags.Bool("watch", false, "compile files again when they change")
+	pollInterval := flags.Duration("poll-interval", 250*time.Millisecond, "how often to check for changes in watch mode")
+	flags.Parse(args)
+	cf.options.SourceMap = service.SourceMapOption(sourcemap)
+
+	if !*noCache && *cacheDir != "" {
+		cache, err := service.OpenCache(service.CacheOptions{Dir: *cacheDir, MaxSize: *cacheMaxSize})
+		if err != nil {
+			// Compiling without a cache is slower, but still works
+			fmt.Fprintf(os.Stderr, "warning: unable to open the compile cache: %s\n", err)
+		}
+		cf.cache = cache
+	}
+
+	if flags.NArg() == 0 || (*watchMode && cf.outdir == "") {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *watchMode {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return watch(ctx, flags.Args(), cf, *pollInterval, os.Stderr)
+	}
+	files, err := collectFiles(flags.Args(), false)
+	if err != nil {
+		return err
+	}
+	if cf.outdir != "" {
+		if err := checkOutputPaths(files); err != nil {
+			return err
+		}
+	}
+	return compileFiles(context.Background(), files, cf, os.Stdout, os.Stderr)
+}
+
+// defaultCacheDir returns the directory of the compile cache in the user's
+// cache directory, or an empty string if there isn't one
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "astro-compiler")
+}
+
+// collectFiles returns every .astro file in inputs, in order. Directories are
+// searched recursively, skipping node_modules and hidden directories. If
+// allowMissing is true, files that don't exist are skipped instead of
+// returning an error, because they may be removed while they're searched.
+func collectFiles(inputs []string, allowMissing bool) ([]inputFile, error) {
+	files := make([]inputFile, 0)
+	for _, input := range inputs {
+		info, err := os.Stat(input)
+		if allowMissing && os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, inputFile{Path: input, Rel: filepath.Base(input)})
+			continue
+		}
+		err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if allowMissing && os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != input && (d.Name() == "node_modules" || strings.HasPrefix(d.Name(), ".")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if filepath.Ext(path) != ".astro" {
+				return nil
+			}
+			rel, err := filepath.Rel(input, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, inputFile{Path: path, Rel: rel})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// checkOutputPaths returns an error if two different files would be written
+// to the same output path, because only one of them would survive
+func checkOutputPaths(files []inputFile) error {
+	seen := make(map[string]string, len(files))
+	for _, file := range files {
+		rel := filepath.Clean(file.Rel)
+		if other, ok := seen[rel]; ok && filepath.Clean(other) != filepath.Clean(file.Path) {
+			return fmt.Errorf("%s and %s would both be written to %s; compile them separately or pass their common parent directory", other, file.Path, rel+".ts")
+		}
+		seen[rel] = file.Path
+	}
+	return nil
+}
+
+type compileRecord struct {
+	Path   string                   `json:"path"`
+	Result *service.TransformResult `json:"result,omitempty"`
+	Error  *printer.CompileError    `json:"error,omitempty"`
+}
+
+// compileFiles compiles files and writes them to stdout or cf.outdir. It
+// returns errCompileFailed if any file has errors.
+func compileFiles(ctx context.Context, files []inputFile, cf compileFlags, stdout io.Writer, stderr io.Writer) error {
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	encoder := json.NewEncoder(stdout)
+	failed := false
+	i := 0
+	err := service.TransformBatch(ctx, paths, service.BatchOptions{Options: cf.options, Concurrency: cf.concurrency, Cache: cf.cache}, func(r service.BatchResult) error {
+		file := files[i]
+		i++
+		if cf.outdir == "" {
+			record := compileRecord{Path: r.Path}
+			if r.Err != nil {
+				e := printer.PrintError(r.Source, r.Path, r.Err)
+				record.Error = &e
+				failed = true
+			} else {
+				record.Result = &r.Result
+				failed = failed || hasErrors(r.Result.Diagnostics)
+			}
+			return encoder.Encode(record)
+		}
+
+		if !reportResult(stderr, r) {
+			failed = true
+		}
+		if r.Err != nil {
+			return nil
+		}
+		return writeOutput(cf.outdir, file.Rel, r.Result)
+	})
+	if err != nil {
+		return err
+	}
+	if failed {
+		return errCompileFailed
+	}
+	return nil
+}
+
+// reportResult prints the diagnostics for r, and returns false if r has errors
+func reportResult(w io.Writer, r service.BatchResult) bool {
+	if r.Err != nil {
+		e := printer.PrintError(r.Source, r.Path, r.Err)
+		if e.Location != nil {
+			fmt.Fprintf(w, "%s:%d:%d: error: %s\n%s\n", r.Path, e.Location.Line, e.Location.Column, e.Message, e.Frame)
+		} else {
+			fmt.Fprintf(w, "%s: error: %s\n", r.Path, e.Message)
+		}
+		return false
+	}
+	for _, d := range r.Result.Diagnostics {
+		fmt.Fprintf(w, "%s:%d:%d: %s: %s\n", r.Path, d.Location.Line, d.Location.Column, severityName(d.Severity), d.Text)
+	}
+	return !hasErrors(r.Result.Diagnostics)
+}
+
+func hasErrors(diagnostics []service.DiagnosticMessage) bool {
+	for _, d := range diagnostics {
+		if d.Severity == int(loc.ErrorType) {
+			return true
+		}
+	}
+	return false
+}
+
+func severityName(severity int) string {
+	switch loc.DiagnosticSeverity(severity) {
+	case loc.ErrorType:
+		return "error"
+	case loc.WarningType:
+		return "warning"
+	case loc.InformationType:
+		return "info"
+	}
+	return "hint"
+}
+
+// outputPath returns the path of the compiled file for rel, which is the path
+// of the .astro file relative to its input directory
+func outputPath(outdir string, rel string) string {
+	return filepath.Join(outdir, rel+".ts")
+}
+
+// writeOutput writes the compiled code for rel, along with its source map and
+// extracted CSS if there are any
+func writeOutput(outdir string, rel string, result service.TransformResult) error {
+	out := outputPath(outdir, rel)
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, []byte(result.Code), 0644); err != nil {
+		return err
+	}
+	// Remove the source map and CSS if they aren't generated anymore, so they don't go stale
+	if err := writeOrRemove(out+".map", result.Map); err != nil {
+		return err
+	}
+	return writeOrRemove(strings.TrimSuffix(out, ".ts")+".css", strings.Join(result.CSS, "\n"))
+}
+
+// writeOrRemove writes content to path, or removes path if content is empty
+func writeOrRemove(path string, content string) error {
+	if content == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// removeOutput removes every file written by writeOutput for rel
+func removeOutput(outdir string, rel string) error {
+	out := outputPath(outdir, rel)
+	for _, path := range []string{out, out + ".map", strings.TrimSuffix(out, ".ts") + ".css"} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckOutputPaths(t *testing.T) {
+	src := t.TempDir()
+	for _, name := range []string{"a/Index.astro", "b/Index.astro", "b/pages/About.astro"} {
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("<h1>Hello</h1>\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := filepath.Join(src, "a", "Index.astro")
+	b := filepath.Join(src, "b", "Index.astro")
+
+	tests := []struct {
+		name    string
+		inputs  []string
+		wantErr bool
+	}{
+		{"same name in two files", []string{a, b}, true},
+		{"same name in two directories", []string{filepath.Join(src, "a"), filepath.Join(src, "b")}, true},
+		{"common parent directory", []string{src}, false},
+		{"same file twice", []string{a, a}, false},
+		{"different directories", []string{filepath.Join(src, "a"), filepath.Join(src, "b", "pages")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := collectFiles(tt.inputs, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = checkOutputPaths(files)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("wantErr %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !strings.Contains(err.Error(), "Index.astro.ts") {
+				t.Errorf("the error doesn't name the output path: %s", err)
+			}
+		})
+	}
+
+	outdir := t.TempDir()
+	files, _ := collectFiles([]string{src}, false)
+	if err := compileFiles(context.Background(), files, compileFlags{outdir: outdir}, io.Discard, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a/Index.astro.ts", "b/Index.astro.ts", "b/pages/About.astro.ts"} {
+		if _, err := os.Stat(filepath.Join(outdir, name)); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCompileStdoutIsJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Global.astro")
+	if err := os.WriteFile(path, []byte("<style global>h1 { color: red; }</style>\n<h1>Hello</h1>\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	files, err := collectFiles([]string{path}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Warnings used to be printed to stdout, between the JSON records
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+	compileErr := compileFiles(context.Background(), files, compileFlags{}, w, io.Discard)
+	w.Close()
+	os.Stdout = stdout
+	data := <-out
+	if compileErr != nil {
+		t.Fatal(compileErr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected a single line, got %d: %s", len(lines), data)
+	}
+	var record compileRecord
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("stdout is not JSON %s: %v", lines[0], err)
+	}
+	if record.Result == nil || len(record.Result.Diagnostics) != 1 {
+		t.Errorf("expected the <style global> warning as a diagnostic: %s", lines[0])
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/withastro/compiler/internal/service"
+)
+
+const serveUsage = `Usage: astro serve
+
+Reads newline-delimited JSON-RPC 2.0 requests from stdin and writes a response
+for each one to stdout. Requests are handled concurrently, so responses must be
+matched to requests by their id.
+
+Methods:
+  transform  {"source": string, "options": TransformOptions}
+  parse      {"source": string, "options": ParseOptions}
+  tokenize   {"source": string}
+  fromJSON   {"ast": string}
+`
+
+func serve(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), serveUsage)
+	}
+	flags.Parse(args)
+	if flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	return service.Serve(context.Background(), os.Stdin, os.Stdout)
+}
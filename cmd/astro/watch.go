@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// fileState is used to tell if a file has changed between polls
+type fileState struct {
+	modTime time.Time
+	size    int64
+	rel     string
+}
+
+// watch compiles the files in inputs, then polls them every interval and
+// compiles them again when they change, until ctx is canceled. The output of
+// files that are removed is removed too. Diagnostics are printed to stderr as
+// files are compiled.
+func watch(ctx context.Context, inputs []string, cf compileFlags, interval time.Duration, stderr io.Writer) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	previous := make(map[string]fileState)
+	for {
+		files, err := collectFiles(inputs, true)
+		if err != nil {
+			return err
+		}
+		if err := checkOutputPaths(files); err != nil {
+			return err
+		}
+
+		current := make(map[string]fileState, len(files))
+		changed := make([]inputFile, 0)
+		for _, file := range files {
+			info, err := os.Stat(file.Path)
+			if err != nil {
+				// The file was removed after it was found, so it's handled by the next poll
+				continue
+			}
+			state := fileState{modTime: info.ModTime(), size: info.Size(), rel: file.Rel}
+			current[file.Path] = state
+			if state != previous[file.Path] {
+				changed = append(changed, file)
+			}
+		}
+
+		removed := make([]string, 0)
+		for path := range previous {
+			if _, ok := current[path]; !ok {
+				removed = append(removed, path)
+			}
+		}
+		sort.Strings(removed)
+		for _, path := range removed {
+			if err := removeOutput(cf.outdir, previous[path].rel); err != nil {
+				return err
+			}
+			fmt.Fprintf(stderr, "%s: removed\n", path)
+		}
+
+		if len(changed) > 0 {
+			start := time.Now()
+			err := compileFiles(ctx, changed, cf, io.Discard, stderr)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if err != nil && err != errCompileFailed {
+				return err
+			}
+			fmt.Fprintf(stderr, "compiled %d %s in %s\n", len(changed), plural(len(changed), "file", "files"), time.Since(start).Round(time.Millisecond))
+		}
+		previous = current
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func plural(n int, singular string, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
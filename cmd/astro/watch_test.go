@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer that can be written by watch while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatch(t *testing.T) {
+	src := t.TempDir()
+	outdir := t.TempDir()
+	write := func(name string, content string) {
+		t.Helper()
+		path := filepath.Join(src, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stderr syncBuffer
+	waitFor := func(description string, check func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !check() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s, got:\n%s", description, stderr.String())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	output := func(rel string) string {
+		data, _ := os.ReadFile(filepath.Join(outdir, rel+".ts"))
+		return string(data)
+	}
+
+	write("index.astro", "<h1>first</h1>")
+	write("pages/about.astro", "<style>h1 { color: red; }</style><h1>about</h1>")
+	write("notes.md", "not compiled")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	cf := compileFlags{outdir: outdir}
+	cf.options.StaticExtraction = true
+	go func() {
+		done <- watch(ctx, []string{src}, cf, 10*time.Millisecond, &stderr)
+	}()
+
+	waitFor("the first compile", func() bool {
+		return strings.Contains(output("index.astro"), "first") && strings.Contains(output("pages/about.astro"), "about")
+	})
+	if _, err := os.Stat(filepath.Join(outdir, "pages/about.astro.css")); err != nil {
+		t.Errorf("expected the extracted CSS to be written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outdir, "notes.md.ts")); err == nil {
+		t.Error("expected only .astro files to be compiled")
+	}
+
+	// Size changes are detected even if the modification time is the same
+	write("index.astro", "<h1>second {missing}</h1>")
+	waitFor("the changed file to be compiled", func() bool {
+		return strings.Contains(output("index.astro"), "second")
+	})
+	waitFor("the diagnostic to be printed", func() bool {
+		return strings.Contains(stderr.String(), `index.astro:1:13: warning: "missing" is not defined`)
+	})
+
+	// Output that is no longer generated is removed
+	write("pages/about.astro", "<h1>about without styles</h1>")
+	waitFor("the stale CSS to be removed", func() bool {
+		_, err := os.Stat(filepath.Join(outdir, "pages/about.astro.css"))
+		return os.IsNotExist(err) && strings.Contains(output("pages/about.astro"), "without styles")
+	})
+
+	write("new.astro", "<h1>new</h1>")
+	waitFor("the new file to be compiled", func() bool {
+		return strings.Contains(output("new.astro"), "new")
+	})
+
+	if err := os.RemoveAll(filepath.Join(src, "pages")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the output of the removed file to be removed", func() bool {
+		_, err := os.Stat(filepath.Join(outdir, "pages/about.astro.ts"))
+		return os.IsNotExist(err)
+	})
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "about.astro: removed") {
+		t.Errorf("expected the removal to be reported, got:\n%s", stderr.String())
+	}
+}
@@ -134,3 +134,23 @@ func IsKnownDirective(element *Node, attr *Attribute) bool {
 	}
 	return false
 }
+
+// Section 12.1.2, "Elements", gives this list of void elements. Void elements
+// are those that can't have any contents, or a closing tag.
+var VoidElements = map[string]bool{
+	"area":   true,
+	"base":   true,
+	"br":     true,
+	"col":    true,
+	"embed":  true,
+	"hr":     true,
+	"img":    true,
+	"input":  true,
+	"keygen": true, // "keygen" has been removed from the spec, but are kept here for backwards compatibility.
+	"link":   true,
+	"meta":   true,
+	"param":  true,
+	"source": true,
+	"track":  true,
+	"wbr":    true,
+}
@@ -0,0 +1,517 @@
+package astro
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/withastro/compiler/internal/loc"
+)
+
+// A TextEdit replaces the source between the byte offsets Start and End with Text
+type TextEdit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// ParseOptionIncremental records the state of the parser at each element, so
+// the document can be updated with Reparse when its source is edited.
+func ParseOptionIncremental() ParseOption {
+	return func(p *parser) {
+		p.checkpoints = make(map[*Node]*checkpoint)
+	}
+}
+
+// Reparse applies edits to source, the text that doc was parsed from, and
+// returns the new document and source. The offsets of each edit are in the
+// source left by the edits before it.
+//
+// If doc was returned by Parse with ParseOptionIncremental, or by Reparse,
+// only the content of the smallest element around each edit is parsed again
+// and doc is updated in place, so it shouldn't be used after Reparse returns.
+// Edits that could change how the rest of the document is parsed, like
+// removing an end tag, cause the whole source to be parsed again. Either way,
+// the document is the same as the one Parse returns for the new source.
+func Reparse(doc *Node, source string, edits []TextEdit) (*Node, string, error) {
+	buf := []byte(source)
+	for _, edit := range edits {
+		if edit.Start < 0 || edit.End < edit.Start || edit.End > len(buf) {
+			return nil, "", fmt.Errorf("invalid edit from %d to %d in a source of %d bytes", edit.Start, edit.End, len(buf))
+		}
+		edited := make([]byte, 0, len(buf)+len(edit.Text)-(edit.End-edit.Start))
+		edited = append(edited, buf[:edit.Start]...)
+		edited = append(edited, edit.Text...)
+		edited = append(edited, buf[edit.End:]...)
+		if doc != nil && !doc.reparse(buf, edited, edit) {
+			// The whole document is parsed again once the rest of the edits are applied
+			doc = nil
+		}
+		buf = edited
+	}
+	if doc == nil {
+		var err error
+		doc, err = ParseWithOptions(bytes.NewReader(buf), ParseOptionIncremental())
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return doc, string(buf), nil
+}
+
+// A checkpoint is the state of the parser right after an element's start
+// tag, which is everything needed to parse the element's content again
+type checkpoint struct {
+	// offset is where the element's content starts
+	offset int
+	// end is the span of the end tag that closed the element. It's empty if
+	// the element wasn't closed by its own end tag.
+	end       loc.Span
+	parser    parserState
+	tokenizer tokenizerState
+}
+
+type parserState struct {
+	tok                   Token
+	frontmatterState      FrontmatterState
+	fm, head, form        *Node
+	oe, afe               nodeStack
+	scripting, framesetOK bool
+	templateStack         insertionModeStack
+	im, originalIM        insertionMode
+	fosterParenting       bool
+	quirks                bool
+}
+
+type tokenizerState struct {
+	tt, prevTokenType          TokenType
+	fm                         FrontmatterState
+	m                          MarkdownState
+	attrExpressionStack        int
+	attrTemplateLiteralStack   []int
+	dashCount                  int
+	expressionStack            []int
+	openBraceIsExpressionStart bool
+	rawTag, noExpressionTag    string
+	allowCDATA                 bool
+}
+
+// parseCurrentTokenWithCheckpoints is like parseCurrentToken, but records a
+// checkpoint for the element opened by a start tag, and where an element is
+// closed by its end tag
+func (p *parser) parseCurrentTokenWithCheckpoints() {
+	top := p.oe.top()
+	var closing *Node
+	if p.tok.Type == EndTagToken {
+		for i := len(p.oe) - 1; i >= 0; i-- {
+			if p.oe[i].Type == ElementNode && p.oe[i].Data == p.tok.Data {
+				closing = p.oe[i]
+				break
+			}
+		}
+	}
+	p.parseCurrentToken()
+
+	switch p.tok.Type {
+	case StartTagToken:
+		n := p.oe.top()
+		if n != nil && n != top && n.Type == ElementNode && n.FirstChild == nil && len(n.Loc) == 1 && n.Loc[0] == p.tok.Loc {
+			p.checkpoints[n] = &checkpoint{
+				offset:    p.tokenizer.raw.End,
+				parser:    p.saveState(),
+				tokenizer: p.tokenizer.saveState(),
+			}
+		}
+	case EndTagToken:
+		if cp := p.checkpoints[closing]; cp != nil && p.oe.index(closing) == -1 {
+			cp.end = p.tokenizer.raw
+		}
+	}
+}
+
+func (p *parser) saveState() parserState {
+	return parserState{
+		tok:              p.tok,
+		frontmatterState: p.frontmatterState,
+		fm:               p.fm,
+		head:             p.head,
+		form:             p.form,
+		oe:               append(nodeStack(nil), p.oe...),
+		afe:              append(nodeStack(nil), p.afe...),
+		scripting:        p.scripting,
+		framesetOK:       p.framesetOK,
+		templateStack:    append(insertionModeStack(nil), p.templateStack...),
+		im:               p.im,
+		originalIM:       p.originalIM,
+		fosterParenting:  p.fosterParenting,
+		quirks:           p.quirks,
+	}
+}
+
+func (p *parser) restoreState(s parserState) {
+	p.tok = s.tok
+	p.frontmatterState = s.frontmatterState
+	p.fm = s.fm
+	p.head = s.head
+	p.form = s.form
+	p.oe = append(nodeStack(nil), s.oe...)
+	p.afe = append(nodeStack(nil), s.afe...)
+	p.scripting = s.scripting
+	p.framesetOK = s.framesetOK
+	p.templateStack = append(insertionModeStack(nil), s.templateStack...)
+	p.im = s.im
+	p.originalIM = s.originalIM
+	p.fosterParenting = s.fosterParenting
+	p.quirks = s.quirks
+}
+
+// mapNodes returns a copy of s with each node replaced by f(node)
+func (s parserState) mapNodes(f func(*Node) *Node) parserState {
+	s.fm = f(s.fm)
+	s.head = f(s.head)
+	s.form = f(s.form)
+	oe := make(nodeStack, len(s.oe))
+	for i, n := range s.oe {
+		oe[i] = f(n)
+	}
+	afe := make(nodeStack, len(s.afe))
+	for i, n := range s.afe {
+		afe[i] = f(n)
+	}
+	s.oe, s.afe = oe, afe
+	return s
+}
+
+// equal returns whether the parser would parse the rest of a document the
+// same way from s and t
+func (s parserState) equal(t parserState) bool {
+	if s.tok.Type != t.tok.Type || s.tok.Data != t.tok.Data ||
+		s.frontmatterState != t.frontmatterState ||
+		s.fm != t.fm || s.head != t.head || s.form != t.form ||
+		s.scripting != t.scripting || s.framesetOK != t.framesetOK ||
+		s.fosterParenting != t.fosterParenting || s.quirks != t.quirks ||
+		!sameInsertionMode(s.im, t.im) || !sameInsertionMode(s.originalIM, t.originalIM) ||
+		len(s.oe) != len(t.oe) || len(s.afe) != len(t.afe) || len(s.templateStack) != len(t.templateStack) {
+		return false
+	}
+	for i := range s.oe {
+		if s.oe[i] != t.oe[i] {
+			return false
+		}
+	}
+	for i := range s.afe {
+		if s.afe[i] != t.afe[i] {
+			return false
+		}
+	}
+	for i := range s.templateStack {
+		if !sameInsertionMode(s.templateStack[i], t.templateStack[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameInsertionMode(a, b insertionMode) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+func (z *Tokenizer) saveState() tokenizerState {
+	return tokenizerState{
+		tt:                         z.tt,
+		prevTokenType:              z.prevTokenType,
+		fm:                         z.fm,
+		m:                          z.m,
+		attrExpressionStack:        z.attrExpressionStack,
+		attrTemplateLiteralStack:   append([]int(nil), z.attrTemplateLiteralStack...),
+		dashCount:                  z.dashCount,
+		expressionStack:            append([]int(nil), z.expressionStack...),
+		openBraceIsExpressionStart: z.openBraceIsExpressionStart,
+		rawTag:                     z.rawTag,
+		noExpressionTag:            z.noExpressionTag,
+		allowCDATA:                 z.allowCDATA,
+	}
+}
+
+func (z *Tokenizer) restoreState(s tokenizerState) {
+	z.tt = s.tt
+	z.prevTokenType = s.prevTokenType
+	z.fm = s.fm
+	z.m = s.m
+	z.attrExpressionStack = s.attrExpressionStack
+	z.attrTemplateLiteralStack = append([]int(nil), s.attrTemplateLiteralStack...)
+	z.dashCount = s.dashCount
+	z.expressionStack = append([]int(nil), s.expressionStack...)
+	z.openBraceIsExpressionStart = s.openBraceIsExpressionStart
+	z.rawTag = s.rawTag
+	z.noExpressionTag = s.noExpressionTag
+	z.allowCDATA = s.allowCDATA
+}
+
+// reparse updates doc for an edit that turned source into edited, by parsing
+// the content of the innermost element around the edit again. It returns false
+// if doc can't be updated that way, and needs to be parsed from scratch.
+func (doc *Node) reparse(source []byte, edited []byte, edit TextEdit) bool {
+	if doc.checkpoints == nil {
+		return false
+	}
+	delta := len(edit.Text) - (edit.End - edit.Start)
+
+	candidates := make([]*Node, 0)
+	for n, cp := range doc.checkpoints {
+		if cp.end.End != 0 && cp.offset <= edit.Start && edit.End <= cp.end.Start {
+			candidates = append(candidates, n)
+		}
+	}
+	// Elements that contain each other start in order, so sorting by the largest
+	// offset first tries the innermost element first
+	sort.Slice(candidates, func(i, j int) bool {
+		return doc.checkpoints[candidates[i]].offset > doc.checkpoints[candidates[j]].offset
+	})
+
+	for _, n := range candidates {
+		cp := doc.checkpoints[n]
+		// Parsing the content of a big element twice is slower than parsing the whole document once
+		if 2*(cp.end.End-cp.offset) > len(source) {
+			return false
+		}
+		// The old content is parsed again too, to check that it didn't change
+		// anything outside of n, and to get the state the rest of the document
+		// was parsed with
+		before, ok := doc.replay(n, cp, source, cp.end, false)
+		if !ok {
+			continue
+		}
+		after, ok := doc.replay(n, cp, edited, loc.Span{Start: cp.end.Start + delta, End: cp.end.End + delta}, true)
+		if !ok || !before.parser.equal(after.parser) || !reflect.DeepEqual(before.tokenizer, after.tokenizer) {
+			continue
+		}
+		doc.splice(n, after, edit.End, delta)
+		return true
+	}
+	return false
+}
+
+// A replay is the result of parsing an element's content again
+type replay struct {
+	// n is the copy of the element that the content was parsed into
+	n *Node
+	// end is the span of the end tag that closed n
+	end       loc.Span
+	parser    parserState
+	tokenizer tokenizerState
+	// checkpoints are the checkpoints of the elements in n, if they were recorded
+	checkpoints map[*Node]*checkpoint
+}
+
+// replay parses the content of n in source again, from its checkpoint. The
+// parser works on copies of the nodes in the checkpoint, so doc isn't changed.
+// ok is false unless the content is closed by n's own end tag at end, without
+// changing anything outside of n.
+func (doc *Node) replay(n *Node, cp *checkpoint, source []byte, end loc.Span, record bool) (r replay, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+
+	// Only elements whose open elements are their ancestors can be parsed
+	// again, so that every node the parser adds ends up inside n
+	oe := cp.parser.oe
+	if len(oe) == 0 || oe[len(oe)-1] != n {
+		return r, false
+	}
+	for i, e := range oe {
+		parent := doc
+		if i > 0 {
+			parent = oe[i-1]
+		}
+		if e.Parent != parent {
+			return r, false
+		}
+	}
+
+	root := &Node{Type: DocumentNode}
+	copies := map[*Node]*Node{doc: root}
+	originals := map[*Node]*Node{root: doc}
+	copyOf := func(o *Node) *Node {
+		if o == nil || o.Type == scopeMarkerNode {
+			return o
+		}
+		if c, ok := copies[o]; ok {
+			return c
+		}
+		c := &Node{
+			Type:          o.Type,
+			DataAtom:      o.DataAtom,
+			Data:          o.Data,
+			Namespace:     o.Namespace,
+			Attr:          append([]Attribute(nil), o.Attr...),
+			Loc:           append([]loc.Loc(nil), o.Loc...),
+			Fragment:      o.Fragment,
+			CustomElement: o.CustomElement,
+			Component:     o.Component,
+			Expression:    o.Expression,
+		}
+		copies[o] = c
+		originals[c] = o
+		return c
+	}
+	// Each copy of an open element has the next one as its only child, and
+	// every other copy has no children
+	children := map[*Node]*Node{}
+	for _, e := range oe {
+		c := copyOf(e)
+		copies[e.Parent].AppendChild(c)
+		children[copies[e.Parent]] = c
+	}
+	r.n = copies[n]
+	r.n.Loc = r.n.Loc[:1]
+	state := cp.parser.mapNodes(copyOf)
+
+	// The tokenizer changes its buffer, for example to normalize newlines
+	z := &Tokenizer{
+		buf:  append([]byte(nil), source...),
+		raw:  loc.Span{Start: cp.offset, End: cp.offset},
+		data: loc.Span{Start: cp.offset, End: cp.offset},
+	}
+	z.restoreState(cp.tokenizer)
+	p := &parser{tokenizer: z, doc: root}
+	p.restoreState(state)
+	if record {
+		p.checkpoints = make(map[*Node]*checkpoint)
+	}
+
+	for p.oe.index(r.n) != -1 {
+		if z.raw.End >= end.End {
+			return r, false
+		}
+		if err := p.parseNextToken(); err != nil {
+			return r, false
+		}
+	}
+	if p.tok.Type != EndTagToken || p.tok.Data != n.Data || z.raw != end || len(p.oe) != len(oe)-1 {
+		return r, false
+	}
+	for i := range p.oe {
+		if p.oe[i] != copies[oe[i]] {
+			return r, false
+		}
+	}
+	for o, c := range copies {
+		if c == r.n {
+			continue
+		}
+		if c.FirstChild != children[c] || c.LastChild != children[c] || len(c.Attr) != len(o.Attr) || len(c.Loc) != len(o.Loc) {
+			return r, false
+		}
+	}
+
+	original := func(c *Node) *Node {
+		if o, ok := originals[c]; ok {
+			return o
+		}
+		return c
+	}
+	r.end = z.raw
+	r.parser = p.saveState().mapNodes(original)
+	r.tokenizer = z.saveState()
+	if record {
+		r.checkpoints = make(map[*Node]*checkpoint, len(p.checkpoints))
+		for e, c := range p.checkpoints {
+			c.parser = c.parser.mapNodes(original)
+			r.checkpoints[e] = c
+		}
+	}
+	return r, true
+}
+
+// splice replaces the content of n with the content of r.n, and shifts every
+// location after the edit that ended at from by delta
+func (doc *Node) splice(n *Node, r replay, from int, delta int) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		doc.forgetCheckpoints(c)
+	}
+	if delta != 0 {
+		shiftLocs(doc, n, from, delta)
+		for e, cp := range doc.checkpoints {
+			// An edit can start right where n's content does, but n's own
+			// checkpoint doesn't move and its end is replaced below
+			if e == n {
+				continue
+			}
+			if cp.offset >= from {
+				cp.offset += delta
+			}
+			if cp.end.Start >= from {
+				cp.end.Start += delta
+				cp.end.End += delta
+			}
+			if cp.parser.tok.Loc.Start >= from {
+				cp.parser.tok.Loc.Start += delta
+			}
+		}
+	}
+
+	for n.FirstChild != nil {
+		n.RemoveChild(n.FirstChild)
+	}
+	for r.n.FirstChild != nil {
+		c := r.n.FirstChild
+		r.n.RemoveChild(c)
+		n.AppendChild(c)
+	}
+	n.Loc = r.n.Loc
+	doc.checkpoints[n].end = r.end
+	for e, cp := range r.checkpoints {
+		doc.checkpoints[e] = cp
+	}
+}
+
+func (doc *Node) forgetCheckpoints(n *Node) {
+	delete(doc.checkpoints, n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		doc.forgetCheckpoints(c)
+	}
+}
+
+// shiftLocs shifts the locations in n and its descendants, except skip, that
+// are at or after from by delta
+func shiftLocs(n *Node, skip *Node, from int, delta int) {
+	for i := range n.Loc {
+		if n.Loc[i].Start >= from {
+			// Reconstructed formatting elements share their locations with
+			// the original element, so they're copied before being changed
+			locs := append([]loc.Loc(nil), n.Loc...)
+			for j := i; j < len(locs); j++ {
+				if locs[j].Start >= from {
+					locs[j].Start += delta
+				}
+			}
+			n.Loc = locs
+			break
+		}
+	}
+	for i := range n.Attr {
+		if n.Attr[i].KeyLoc.Start >= from || n.Attr[i].ValLoc.Start >= from {
+			// Attributes can be shared with the token they were parsed from
+			attrs := append([]Attribute(nil), n.Attr...)
+			for j := i; j < len(attrs); j++ {
+				if attrs[j].KeyLoc.Start >= from {
+					attrs[j].KeyLoc.Start += delta
+				}
+				if attrs[j].ValLoc.Start >= from {
+					attrs[j].ValLoc.Start += delta
+				}
+			}
+			n.Attr = attrs
+			break
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c != skip {
+			shiftLocs(c, skip, from, delta)
+		}
+	}
+}
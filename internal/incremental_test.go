@@ -0,0 +1,231 @@
+package astro
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+var reparseSources = []string{
+	`---
+import Layout from '../layouts/Layout.astro';
+const items = ['a', 'b', 'c'];
+---
+<Layout title="Home">
+	<main class="main">
+		<h1>Hello <b>world</b></h1>
+		<p>Some <em>text</em> and {items.length} items.
+		<ul>
+			{items.map(item => <li class={item}>{item}</li>)}
+		</ul>
+		<div id="a"><span>one</span><span>two</span></div>
+		<section><p>unclosed<div>block</div></section>
+	</main>
+</Layout>`,
+	`<html>
+	<head>
+		<title>Page</title>
+		<style>h1 { color: red; }</style>
+	</head>
+	<body>
+		<table><tr><td>cell</td><td>other</td></tr></table>
+		<p><b>bold <i>both</b> italic</i></p>
+		<select><option>one<option>two</select>
+		<div><textarea>raw <b>text</b></textarea></div>
+		<svg><path d="M0 0" /><text>svg</text></svg>
+		<script>const a = "<div>";</script>
+	</body>
+</html>`,
+	`<div>
+	<Component client:load a={1} {...props}>
+		<Fragment slot="x"><span>{a ? <b>yes</b> : <i>no</i>}</span></Fragment>
+	</Component>
+	<article>
+		<header><h2>Title</h2></header>
+		<p>First</p>
+		<p>Second <a href="/">link</a></p>
+		<footer>{note}<small>fine</small></footer>
+	</article>
+	<form><input name="a"><button>Go</button></form>
+</div>`,
+}
+
+var reparseSnippets = []string{
+	"a", "b c", " ", "\n", "<", ">", "/", "</", "{", "}", "=", "\"", "'", "-",
+	"<div>", "</div>", "<p>", "</p>", "<b>", "</b>", "<span class=\"x\">", "</span>",
+	"<li>", "{x}", "{a && <i>b</i>}", "<br>", "<Comp />", "&amp;",
+}
+
+func TestReparse(t *testing.T) {
+	incremental, total := 0, 0
+	for i, base := range reparseSources {
+		r := rand.New(rand.NewSource(int64(i)))
+		var doc *Node
+		var source string
+		for step := 0; step < 1000; step++ {
+			// Start again every few edits, before the markup is completely broken
+			if step%10 == 0 {
+				source = base
+				var err error
+				doc, err = ParseWithOptions(strings.NewReader(source), ParseOptionIncremental())
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			edits := make([]TextEdit, 1+r.Intn(2))
+			edited := source
+			for j := range edits {
+				start := r.Intn(len(edited) + 1)
+				end := start
+				text := ""
+				switch r.Intn(4) {
+				case 0:
+					// Typing
+					text = string(rune('a' + r.Intn(26)))
+				case 1:
+					// Deleting
+					end += 1 + r.Intn(3)
+				default:
+					end += r.Intn(3)
+					text = reparseSnippets[r.Intn(len(reparseSnippets))]
+				}
+				if end > len(edited) {
+					end = len(edited)
+				}
+				edits[j] = TextEdit{Start: start, End: end, Text: text}
+				edited = edited[:start] + text + edited[end:]
+			}
+
+			expected, expectedErr := Parse(strings.NewReader(edited))
+			updated, updatedSource, err := Reparse(doc, source, edits)
+			if expectedErr != nil {
+				if err == nil {
+					t.Fatalf("source %d, step %d: expected an error for %q", i, step, edited)
+				}
+				// Start again from the last source that could be parsed
+				doc, err = ParseWithOptions(strings.NewReader(source), ParseOptionIncremental())
+				if err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("source %d, step %d: %v", i, step, err)
+			}
+			if updatedSource != edited {
+				t.Fatalf("source %d, step %d: expected the source %q, got %q", i, step, edited, updatedSource)
+			}
+			if diff := diffNodes(expected, updated, "root"); diff != "" {
+				t.Fatalf("source %d, step %d: %s\nedits: %+v\nbefore: %q\nafter:  %q", i, step, diff, edits, source, edited)
+			}
+			total++
+			if updated == doc {
+				incremental++
+			}
+			doc, source = updated, edited
+		}
+	}
+	// Random edits often break the markup around them, or land in an element
+	// that's most of these small documents, but plenty should still only need
+	// the element around them parsed again
+	if incremental < total/10 {
+		t.Errorf("expected at least a tenth of %d edits to be reparsed incrementally, got %d", total, incremental)
+	}
+}
+
+func TestReparseTyping(t *testing.T) {
+	source := "<main>\n" + strings.Repeat("<article><h2>Title</h2><p>Some text</p></article>\n", 20) + "</main>"
+	doc, err := ParseWithOptions(strings.NewReader(source), ParseOptionIncremental())
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := lastElement(doc, "article")
+	offset := strings.Index(source, "Some text") + len("Some")
+	for i, c := range " more" {
+		updated, updatedSource, err := Reparse(doc, source, []TextEdit{{Start: offset + i, End: offset + i, Text: string(c)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated != doc {
+			t.Fatalf("expected %q to be reparsed incrementally", string(c))
+		}
+		doc, source = updated, updatedSource
+	}
+	expected, _ := Parse(strings.NewReader(source))
+	if diff := diffNodes(expected, doc, "root"); diff != "" {
+		t.Fatal(diff)
+	}
+	if lastElement(doc, "article") != last {
+		t.Error("expected the elements after the edits to be reused")
+	}
+}
+
+func TestReparseWithoutCheckpoints(t *testing.T) {
+	source := "<div><p>Hello</p></div>"
+	doc, err := Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated, updatedSource, err := Reparse(doc, source, []TextEdit{{Start: 8, End: 13, Text: "Goodbye"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updatedSource != "<div><p>Goodbye</p></div>" {
+		t.Errorf("unexpected source %q", updatedSource)
+	}
+	expected, _ := Parse(strings.NewReader(updatedSource))
+	if diff := diffNodes(expected, updated, "root"); diff != "" {
+		t.Error(diff)
+	}
+
+	if _, _, err := Reparse(doc, source, []TextEdit{{Start: 20, End: 30}}); err == nil {
+		t.Error("expected an error for an edit outside of the source")
+	}
+}
+
+func lastElement(n *Node, data string) (last *Node) {
+	if n.Type == ElementNode && n.Data == data {
+		last = n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if l := lastElement(c, data); l != nil {
+			last = l
+		}
+	}
+	return last
+}
+
+// diffNodes describes the first difference between the trees a and b
+func diffNodes(a *Node, b *Node, path string) string {
+	if a.Type != b.Type || a.Data != b.Data || a.DataAtom != b.DataAtom || a.Namespace != b.Namespace ||
+		a.Fragment != b.Fragment || a.CustomElement != b.CustomElement || a.Component != b.Component || a.Expression != b.Expression {
+		return fmt.Sprintf("%s: expected %v %q, got %v %q", path, a.Type, a.Data, b.Type, b.Data)
+	}
+	if fmt.Sprint(a.Loc) != fmt.Sprint(b.Loc) {
+		return fmt.Sprintf("%s: expected the locations %v, got %v", path, a.Loc, b.Loc)
+	}
+	if len(a.Attr) != len(b.Attr) {
+		return fmt.Sprintf("%s: expected %d attributes, got %d", path, len(a.Attr), len(b.Attr))
+	}
+	for i := range a.Attr {
+		x, y := a.Attr[i], b.Attr[i]
+		if x.Namespace != y.Namespace || x.Key != y.Key || x.KeyLoc != y.KeyLoc || x.Val != y.Val || x.ValLoc != y.ValLoc || x.Type != y.Type {
+			return fmt.Sprintf("%s: expected the attribute %+v, got %+v", path, x, y)
+		}
+	}
+	ac, bc := a.FirstChild, b.FirstChild
+	for i := 0; ac != nil || bc != nil; i++ {
+		if ac == nil || bc == nil {
+			return fmt.Sprintf("%s: expected %d children, got a different number", path, i)
+		}
+		if bc.Parent != b {
+			return fmt.Sprintf("%s/%d: wrong parent", path, i)
+		}
+		if diff := diffNodes(ac, bc, fmt.Sprintf("%s/%d:%s", path, i, ac.Data)); diff != "" {
+			return diff
+		}
+		ac, bc = ac.NextSibling, bc.NextSibling
+	}
+	return ""
+}
@@ -1,8 +1,10 @@
 package js_scanner
 
 import (
-	"fmt"
+	"bytes"
 	"io"
+	"strconv"
+	"strings"
 
 	"github.com/tdewolff/parse/v2"
 	"github.com/tdewolff/parse/v2/js"
@@ -146,7 +148,6 @@ func FindRenderBody(source []byte) int {
 
 func HasExports(source []byte) bool {
 	l := js.NewLexer(parse.NewInputBytes(source))
-	var prevToken js.TokenType
 	for {
 		token, _ := l.Next()
 		if token == js.ErrorToken {
@@ -154,10 +155,8 @@ func HasExports(source []byte) bool {
 			return false
 		}
 		if token == js.ExportToken {
-			fmt.Println(prevToken, token)
 			return true
 		}
-		prevToken = token
 	}
 }
 
@@ -303,12 +302,17 @@ type Import struct {
 	ExportName string
 	LocalName  string
 	Assertions string
+	// IsType is true for `import type { A }` and `import { type A }`
+	IsType bool
 }
 
 type ImportStatement struct {
+	// Start is the offset of the `import` keyword
+	Start      int
 	Imports    []Import
 	Specifier  string
 	Assertions string
+	IsType     bool
 }
 
 type ImportState uint32
@@ -330,6 +334,7 @@ func NextImportStatement(source []byte, pos int) (int, ImportStatement) {
 		// Imports should be consumed up until we find a specifier,
 		// then we can exit after the following line terminator or semicolon
 		if token == js.ImportToken {
+			start := i
 			i += len(value)
 			specifier := ""
 			assertion := ""
@@ -338,6 +343,8 @@ func NextImportStatement(source []byte, pos int) (int, ImportStatement) {
 			imports := make([]Import, 0)
 			importState := ImportDefault
 			currImport := Import{}
+			isType := false
+			maybeType := false
 			pairs := make(map[byte]int)
 			for {
 				next, nextValue := l.Next()
@@ -356,10 +363,17 @@ func NextImportStatement(source []byte, pos int) (int, ImportStatement) {
 						}
 						imports = append(imports, currImport)
 					}
+					if isType {
+						for j := range imports {
+							imports[j].IsType = true
+						}
+					}
 					return i, ImportStatement{
+						Start:      start,
 						Imports:    imports,
 						Specifier:  specifier,
 						Assertions: assertion,
+						IsType:     isType,
 					}
 				}
 
@@ -367,6 +381,33 @@ func NextImportStatement(source []byte, pos int) (int, ImportStatement) {
 					continue
 				}
 
+				// `import type A from` and `import type { A } from` are type-only,
+				// but `import type from` is a default import named `type`
+				if maybeType {
+					maybeType = false
+					if next == js.IdentifierToken || next == js.OpenBraceToken || next == js.MulToken {
+						isType = true
+					} else {
+						currImport.ExportName = "default"
+						currImport.LocalName = "type"
+					}
+				}
+				if !foundAssertion && !foundSpecifier && next == js.IdentifierToken && string(nextValue) == "type" && currImport.ExportName == "" && !currImport.IsType {
+					if importState == ImportDefault && len(imports) == 0 && !isType {
+						maybeType = true
+						continue
+					}
+					if importState == ImportNamed {
+						currImport.IsType = true
+						continue
+					}
+				}
+				// `{ type }` and `{ type as A }` import a binding named `type`
+				if currImport.IsType && currImport.ExportName == "" && (next == js.CommaToken || next == js.CloseBraceToken || next == js.AsToken) {
+					currImport.ExportName = "type"
+					currImport.IsType = false
+				}
+
 				if foundAssertion {
 					assertion += string(nextValue)
 				}
@@ -431,3 +472,1343 @@ func NextImportStatement(source []byte, pos int) (int, ImportStatement) {
 		i += len(value)
 	}
 }
+
+type Export struct {
+	// ExportName is the name other modules import, which is `default` for
+	// default exports and `*` for `export * from`
+	ExportName string
+	// LocalName is the exported binding, or its name in the other module for
+	// re-exports. It's empty for expressions like `export default {}`.
+	LocalName string
+	// IsType is true for `export type { A }`, `export { type A }` and type declarations
+	IsType bool
+}
+
+type ExportStatement struct {
+	// Start and End are the offsets of the statement, including its semicolon
+	Start   int
+	End     int
+	Exports []Export
+	// Specifier is the module of a re-export like `export { a } from 'b'`
+	Specifier  string
+	Assertions string
+	IsType     bool
+}
+
+// GetExportStatements returns the top-level export statements of source, in authored order
+func GetExportStatements(source []byte) []ExportStatement {
+	statements := make([]ExportStatement, 0)
+	tokens, _ := tokenize(source)
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if isOpenToken(t.Type) {
+			depth++
+			continue
+		} else if isCloseToken(t.Type) {
+			depth--
+			continue
+		}
+		if depth != 0 || t.Type != js.ExportToken || (i > 0 && tokens[i-1].Type == js.DotToken) {
+			continue
+		}
+		var end int
+		statement := ExportStatement{Start: t.Start}
+		statement.Exports, end = readExports(source, tokens, i+1, &statement)
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		// Include the semicolon
+		if end < len(tokens) && tokens[end].Type == js.SemicolonToken {
+			end++
+		}
+		statement.End = tokens[end-1].End()
+		statements = append(statements, statement)
+		i = end - 1
+	}
+	return statements
+}
+
+// readExports reads the statement following `export` at tokens[i] and returns
+// its exports and the index of the first token after it
+func readExports(source []byte, tokens []token, i int, statement *ExportStatement) ([]Export, int) {
+	exports := make([]Export, 0)
+	if i >= len(tokens) {
+		return exports, i
+	}
+	if tokens[i].Type == js.IdentifierToken && string(tokens[i].Value) == "type" && i+1 < len(tokens) && (tokens[i+1].Type == js.OpenBraceToken || tokens[i+1].Type == js.MulToken) {
+		statement.IsType = true
+		i++
+	}
+	switch t := tokens[i]; {
+	case t.Type == js.OpenBraceToken:
+		end := matchingToken(tokens, i)
+		if end == -1 {
+			return exports, len(tokens)
+		}
+		exports = append(exports, readExportList(tokens[i+1:end], statement.IsType)...)
+		return exports, readExportSource(tokens, end+1, statement)
+	case t.Type == js.MulToken:
+		export := Export{ExportName: "*", IsType: statement.IsType}
+		i++
+		if i+1 < len(tokens) && tokens[i].Type == js.AsToken {
+			export.ExportName = string(tokens[i+1].Value)
+			export.LocalName = "*"
+			i += 2
+		}
+		return append(exports, export), readExportSource(tokens, i, statement)
+	case t.Type == js.DefaultToken:
+		export := Export{ExportName: "default"}
+		j := i + 1
+		if j < len(tokens) && tokens[j].Type == js.AsyncToken {
+			j++
+		}
+		if j < len(tokens) && (tokens[j].Type == js.FunctionToken || tokens[j].Type == js.ClassToken) {
+			name, end := readDeclarationWithBody(tokens, j)
+			export.LocalName = name
+			return append(exports, export), end
+		}
+		if j < len(tokens) && tokens[j].Type == js.IdentifierToken && j+1 < len(tokens) && statementEnds(tokens, j+1) {
+			export.LocalName = string(tokens[j].Value)
+		}
+		return append(exports, export), statementEnd(tokens, i+1)
+	case t.Type == js.VarToken || t.Type == js.LetToken || t.Type == js.ConstToken:
+		if i+1 < len(tokens) && tokens[i+1].Type == js.EnumToken {
+			return readExports(source, tokens, i+1, statement)
+		}
+		declarations := make([]Declaration, 0)
+		end := readVariableDeclarations(source, tokens, i+1, &declarations)
+		for _, declaration := range declarations {
+			exports = append(exports, Export{ExportName: declaration.Name, LocalName: declaration.Name})
+		}
+		return exports, end
+	case t.Type == js.AsyncToken || t.Type == js.FunctionToken || t.Type == js.ClassToken:
+		if t.Type == js.AsyncToken {
+			i++
+		}
+		name, end := readDeclarationWithBody(tokens, i)
+		if name != "" {
+			exports = append(exports, Export{ExportName: name, LocalName: name})
+		}
+		return exports, end
+	case t.Type == js.IdentifierToken && (string(t.Value) == "declare" || string(t.Value) == "abstract"):
+		return readExports(source, tokens, i+1, statement)
+	case t.Type == js.EnumToken || (t.Type == js.IdentifierToken && (string(t.Value) == "namespace" || string(t.Value) == "module")):
+		if i+1 < len(tokens) && js.IsIdentifier(tokens[i+1].Type) {
+			name := string(tokens[i+1].Value)
+			exports = append(exports, Export{ExportName: name, LocalName: name})
+		}
+		return exports, readBody(tokens, i+1)
+	case t.Type == js.InterfaceToken || (t.Type == js.IdentifierToken && string(t.Value) == "type"):
+		if i+1 < len(tokens) && js.IsIdentifierName(tokens[i+1].Type) {
+			statement.IsType = true
+			name := string(tokens[i+1].Value)
+			exports = append(exports, Export{ExportName: name, LocalName: name, IsType: true})
+		}
+		if t.Type == js.InterfaceToken {
+			return exports, readBody(tokens, i+1)
+		}
+		return exports, statementEnd(tokens, i)
+	}
+	return exports, statementEnd(tokens, i)
+}
+
+// readExportList reads the contents of `export { a, b as c }`
+func readExportList(tokens []token, isType bool) []Export {
+	exports := make([]Export, 0)
+	export := Export{IsType: isType}
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case t.Type == js.CommaToken:
+			if export.LocalName != "" {
+				exports = append(exports, export)
+			}
+			export = Export{IsType: isType}
+		case t.Type == js.AsToken && export.LocalName != "":
+			if i+1 < len(tokens) {
+				export.ExportName = exportName(tokens[i+1])
+				i++
+			}
+		case t.Type == js.IdentifierToken && string(t.Value) == "type" && export.LocalName == "" && i+1 < len(tokens) && tokens[i+1].Type != js.CommaToken && tokens[i+1].Type != js.AsToken:
+			export.IsType = true
+		case export.LocalName == "":
+			export.LocalName = exportName(t)
+			export.ExportName = export.LocalName
+		}
+	}
+	if export.LocalName != "" {
+		exports = append(exports, export)
+	}
+	return exports
+}
+
+// exportName returns the name of an identifier, or the value of a string
+// literal for names like `export { a as "b-c" }`
+func exportName(t token) string {
+	if t.Type == js.StringToken {
+		return string(t.Value[1 : len(t.Value)-1])
+	}
+	return string(t.Value)
+}
+
+// readExportSource reads `from 'a'` and any assertions after a re-export, and
+// returns the index of the first token after the statement
+func readExportSource(tokens []token, i int, statement *ExportStatement) int {
+	if i+1 >= len(tokens) || tokens[i].Type != js.FromToken || tokens[i+1].Type != js.StringToken {
+		return i
+	}
+	statement.Specifier = exportName(tokens[i+1])
+	i += 2
+	if i+1 < len(tokens) && string(tokens[i].Value) == "assert" && tokens[i+1].Type == js.OpenBraceToken && !tokens[i].NewlineBefore {
+		end := matchingToken(tokens, i+1)
+		if end == -1 {
+			return len(tokens)
+		}
+		for j := i + 1; j <= end; j++ {
+			statement.Assertions += string(tokens[j].Value)
+		}
+		i = end + 1
+	}
+	return i
+}
+
+// readDeclarationWithBody reads a function or class declaration at tokens[i]
+// and returns its name and the index of the first token after its body
+func readDeclarationWithBody(tokens []token, i int) (string, int) {
+	isFunction := tokens[i].Type == js.FunctionToken
+	i++
+	if i < len(tokens) && tokens[i].Type == js.MulToken {
+		i++
+	}
+	name := ""
+	if i < len(tokens) && js.IsIdentifier(tokens[i].Type) {
+		name = string(tokens[i].Value)
+		i++
+	}
+	if isFunction {
+		// Skip the parameters, which may contain braces
+		for i < len(tokens) && tokens[i].Type != js.OpenParenToken {
+			i++
+		}
+		if end := matchingToken(tokens, i); end != -1 {
+			i = end + 1
+		}
+	}
+	return name, readBody(tokens, i)
+}
+
+// readBody returns the index of the first token after the block that starts
+// at or after tokens[i], like the body of a class or interface
+func readBody(tokens []token, i int) int {
+	for depth := 0; i < len(tokens); i++ {
+		tt := tokens[i].Type
+		if depth == 0 && tt == js.OpenBraceToken {
+			if end := matchingToken(tokens, i); end != -1 {
+				return end + 1
+			}
+			return len(tokens)
+		}
+		if isOpenToken(tt) {
+			depth++
+		} else if isCloseToken(tt) {
+			depth--
+		}
+	}
+	return i
+}
+
+// statementEnd returns the index of the first token after the statement
+// that continues at tokens[i], using the same automatic semicolon insertion
+// rules as variable initializers
+func statementEnd(tokens []token, i int) int {
+	start := i
+	for depth := 0; i < len(tokens); i++ {
+		tt := tokens[i].Type
+		if depth == 0 && i > start && statementEnds(tokens, i) {
+			break
+		}
+		if isOpenToken(tt) {
+			depth++
+		} else if isCloseToken(tt) {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+	}
+	return i
+}
+
+// statementEnds returns true if a statement ends before tokens[i]
+func statementEnds(tokens []token, i int) bool {
+	if i >= len(tokens) {
+		return true
+	}
+	tt := tokens[i].Type
+	if tt == js.SemicolonToken {
+		return true
+	}
+	return tokens[i].NewlineBefore && isOperand(tokens[i-1].Type) && (js.IsIdentifierName(tt) || js.IsNumeric(tt) || tt == js.StringToken || tt == js.OpenBraceToken)
+}
+
+// GetArrayElements returns the source of each top-level element of an array
+// literal like `['a', { b: c }, d]`. The second return value is false if the
+// source is anything other than a single array literal.
+func GetArrayElements(source []byte) ([][]byte, bool) {
+	return getListItems(source, js.OpenBracketToken, js.CloseBracketToken)
+}
+
+// GetObjectProperties returns the source of each top-level property of an
+// object literal like `{ a: 'b', [c]: d }`. The second return value is false
+// if the source is anything other than a single object literal.
+func GetObjectProperties(source []byte) ([][]byte, bool) {
+	return getListItems(source, js.OpenBraceToken, js.CloseBraceToken)
+}
+
+func getListItems(source []byte, open js.TokenType, close js.TokenType) ([][]byte, bool) {
+	l := js.NewLexer(parse.NewInputBytes(source))
+	items := make([][]byte, 0)
+	i := 0
+	// start and end of the current item, ignoring surrounding whitespace and comments
+	start, end := -1, -1
+	depth := 0
+	closed := false
+	var prev js.TokenType
+
+	for {
+		token, value := l.Next()
+		if (token == js.DivToken || token == js.DivEqToken) && !isOperand(prev) {
+			token, value = l.RegExp()
+		}
+		if token == js.ErrorToken {
+			if l.Err() != io.EOF {
+				return nil, false
+			}
+			break
+		}
+		if token == js.WhitespaceToken || token == js.LineTerminatorToken || token == js.CommentToken {
+			i += len(value)
+			continue
+		}
+		if closed {
+			// Anything after the closing token means this isn't a single literal
+			return nil, false
+		}
+
+		if depth == 0 {
+			if token != open {
+				return nil, false
+			}
+			depth++
+		} else if depth == 1 && (token == close || token == js.CommaToken) {
+			if start != -1 {
+				items = append(items, source[start:end])
+			}
+			start, end = -1, -1
+			if token == close {
+				depth--
+				closed = true
+			}
+		} else {
+			switch token {
+			case js.OpenBraceToken, js.OpenBracketToken, js.OpenParenToken, js.TemplateStartToken:
+				depth++
+			case js.CloseBraceToken, js.CloseBracketToken, js.CloseParenToken, js.TemplateEndToken:
+				depth--
+			}
+			if start == -1 {
+				start = i
+			}
+			end = i + len(value)
+		}
+
+		prev = token
+		i += len(value)
+	}
+
+	if !closed {
+		return nil, false
+	}
+	return items, true
+}
+
+// isOperand returns true if a `/` following this token must be division
+// rather than the start of a regular expression
+func isOperand(token js.TokenType) bool {
+	return js.IsIdentifier(token) || js.IsNumeric(token) || token == js.StringToken ||
+		token == js.TemplateToken || token == js.TemplateEndToken ||
+		token == js.CloseParenToken || token == js.CloseBracketToken || token == js.CloseBraceToken
+}
+
+// GetStaticString returns the value of a string literal or a template literal
+// without substitutions. The second return value is false if the source is
+// anything else, or if the literal contains escape sequences.
+func GetStaticString(source []byte) (string, bool) {
+	source = bytes.TrimSpace(source)
+	if len(source) < 2 {
+		return "", false
+	}
+	quote := source[0]
+	if quote != '"' && quote != '\'' && quote != '`' {
+		return "", false
+	}
+	if source[len(source)-1] != quote {
+		return "", false
+	}
+	value := source[1 : len(source)-1]
+	if bytes.IndexByte(value, quote) != -1 || bytes.IndexByte(value, '\\') != -1 {
+		return "", false
+	}
+	if quote == '`' && bytes.Contains(value, []byte("${")) {
+		return "", false
+	}
+	if quote != '`' && bytes.ContainsAny(value, "\r\n") {
+		return "", false
+	}
+	return string(value), true
+}
+
+type token struct {
+	Type  js.TokenType
+	Value []byte
+	Start int
+	// NewlineBefore is true if a line terminator precedes this token
+	NewlineBefore bool
+	// Comment is the last comment between the previous token and this one
+	Comment []byte
+}
+
+func (t token) End() int {
+	return t.Start + len(t.Value)
+}
+
+// tokenize returns the significant tokens of source, skipping whitespace and comments
+func tokenize(source []byte) ([]token, bool) {
+	l := js.NewLexer(parse.NewInputBytes(source))
+	tokens := make([]token, 0)
+	i := 0
+	newline := false
+	var comment []byte
+	var prev js.TokenType
+	for {
+		tt, value := l.Next()
+		if (tt == js.DivToken || tt == js.DivEqToken) && !isOperand(prev) {
+			tt, value = l.RegExp()
+		}
+		if tt == js.ErrorToken {
+			return tokens, l.Err() == io.EOF
+		}
+		switch tt {
+		case js.CommentLineTerminatorToken:
+			newline = true
+			comment = value
+		case js.CommentToken:
+			comment = value
+		case js.LineTerminatorToken:
+			newline = true
+		case js.WhitespaceToken:
+		default:
+			tokens = append(tokens, token{Type: tt, Value: value, Start: i, NewlineBefore: newline, Comment: comment})
+			newline = false
+			comment = nil
+			prev = tt
+		}
+		i += len(value)
+	}
+}
+
+func isOpenToken(tt js.TokenType) bool {
+	return tt == js.OpenBraceToken || tt == js.OpenBracketToken || tt == js.OpenParenToken || tt == js.TemplateStartToken
+}
+
+func isCloseToken(tt js.TokenType) bool {
+	return tt == js.CloseBraceToken || tt == js.CloseBracketToken || tt == js.CloseParenToken || tt == js.TemplateEndToken
+}
+
+// matchingToken returns the index of the token that closes tokens[i]
+func matchingToken(tokens []token, i int) int {
+	depth := 0
+	for j := i; j < len(tokens); j++ {
+		if isOpenToken(tokens[j].Type) {
+			depth++
+		} else if isCloseToken(tokens[j].Type) {
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+type DeclarationKind uint32
+
+const (
+	VariableDeclaration DeclarationKind = iota
+	FunctionDeclaration
+	ClassDeclaration
+)
+
+// A Declaration is a binding declared at the top level of a module
+type Declaration struct {
+	Name string
+	Kind DeclarationKind
+	// Init is the source of a variable's initializer, if it has one
+	Init []byte
+	// Destructured is true if the variable is bound by a destructuring pattern,
+	// in which case Init is the value being destructured
+	Destructured bool
+	// Exported is true for declarations like `export const a = 1`
+	Exported bool
+}
+
+// GetTopLevelDeclarations returns the functions, classes and variables
+// declared at the top level of source, in authored order. Names bound by
+// destructuring are included and share the source of their initializer.
+func GetTopLevelDeclarations(source []byte) []Declaration {
+	declarations := make([]Declaration, 0)
+	tokens, _ := tokenize(source)
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if isOpenToken(t.Type) {
+			depth++
+			continue
+		} else if isCloseToken(t.Type) {
+			depth--
+			continue
+		}
+		if depth != 0 {
+			continue
+		}
+		switch t.Type {
+		case js.FunctionToken, js.ClassToken:
+			kind := FunctionDeclaration
+			if t.Type == js.ClassToken {
+				kind = ClassDeclaration
+			}
+			j := i + 1
+			// Skip the `*` of generator functions
+			if j < len(tokens) && tokens[j].Type == js.MulToken {
+				j++
+			}
+			if j < len(tokens) && js.IsIdentifier(tokens[j].Type) {
+				declarations = append(declarations, Declaration{Name: string(tokens[j].Value), Kind: kind, Exported: isExported(tokens, i)})
+				i = j
+			}
+		case js.VarToken, js.LetToken, js.ConstToken:
+			start := len(declarations)
+			exported := isExported(tokens, i)
+			i = readVariableDeclarations(source, tokens, i+1, &declarations) - 1
+			if exported {
+				for j := start; j < len(declarations); j++ {
+					declarations[j].Exported = true
+				}
+			}
+		}
+	}
+	return declarations
+}
+
+// isExported returns true if the declaration keyword at tokens[i] is preceded by `export`
+func isExported(tokens []token, i int) bool {
+	for j := i - 1; j >= 0; j-- {
+		switch tokens[j].Type {
+		case js.ExportToken:
+			return true
+		case js.AsyncToken, js.DefaultToken:
+			continue
+		}
+		return false
+	}
+	return false
+}
+
+// readVariableDeclarations reads the declarators following `const`, `let` or `var`
+// and returns the index of the first token after them
+func readVariableDeclarations(source []byte, tokens []token, i int, declarations *[]Declaration) int {
+	for i < len(tokens) {
+		names := make([]string, 0)
+		destructured := false
+		t := tokens[i]
+		if js.IsIdentifier(t.Type) {
+			names = append(names, string(t.Value))
+			i++
+		} else if t.Type == js.OpenBraceToken || t.Type == js.OpenBracketToken {
+			end := matchingToken(tokens, i)
+			if end == -1 {
+				return len(tokens)
+			}
+			names = append(names, getPatternNames(tokens[i+1:end])...)
+			destructured = true
+			i = end + 1
+		} else {
+			return i
+		}
+
+		// Skip type annotations
+		if i < len(tokens) && tokens[i].Type == js.ColonToken {
+			depth := 0
+			for i++; i < len(tokens); i++ {
+				t := tokens[i]
+				if depth == 0 && (t.Type == js.EqToken || t.Type == js.CommaToken || t.Type == js.SemicolonToken) {
+					break
+				}
+				if depth == 0 && t.NewlineBefore && isOperand(tokens[i-1].Type) && js.IsIdentifierName(t.Type) {
+					break
+				}
+				switch {
+				case isOpenToken(t.Type) || t.Type == js.LtToken:
+					depth++
+				case isCloseToken(t.Type) || t.Type == js.GtToken:
+					depth--
+				case t.Type == js.GtGtToken:
+					depth -= 2
+				}
+			}
+		}
+
+		var init []byte
+		if i < len(tokens) && tokens[i].Type == js.EqToken {
+			i++
+			start := i
+			for depth := 0; i < len(tokens); i++ {
+				tt := tokens[i].Type
+				if depth == 0 && i > start {
+					if tt == js.CommaToken || tt == js.SemicolonToken || isCloseToken(tt) {
+						break
+					}
+					// Automatic semicolon insertion
+					if tokens[i].NewlineBefore && isOperand(tokens[i-1].Type) && (js.IsIdentifierName(tt) || js.IsNumeric(tt) || tt == js.StringToken || tt == js.OpenBraceToken) {
+						break
+					}
+				}
+				if isOpenToken(tt) {
+					depth++
+				} else if isCloseToken(tt) {
+					depth--
+				}
+			}
+			if i > start {
+				init = source[tokens[start].Start:tokens[i-1].End()]
+			}
+		}
+		for _, name := range names {
+			*declarations = append(*declarations, Declaration{Name: name, Kind: VariableDeclaration, Init: init, Destructured: destructured})
+		}
+
+		if i < len(tokens) && tokens[i].Type == js.CommaToken {
+			i++
+			continue
+		}
+		return i
+	}
+	return i
+}
+
+// getPatternNames returns the names bound by the contents of a destructuring pattern
+func getPatternNames(tokens []token) []string {
+	names := make([]string, 0)
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		switch {
+		case isOpenToken(t.Type):
+			end := matchingToken(tokens, i)
+			if end == -1 {
+				return names
+			}
+			// Computed keys are not bindings
+			if t.Type != js.OpenBracketToken || i+1 >= len(tokens) || end+1 >= len(tokens) || tokens[end+1].Type != js.ColonToken {
+				names = append(names, getPatternNames(tokens[i+1:end])...)
+			}
+			i = end
+		case t.Type == js.EqToken:
+			// Skip default values
+			for depth := 0; i+1 < len(tokens); i++ {
+				tt := tokens[i+1].Type
+				if depth == 0 && tt == js.CommaToken {
+					break
+				}
+				if isOpenToken(tt) {
+					depth++
+				} else if isCloseToken(tt) {
+					depth--
+				}
+			}
+		case js.IsIdentifierName(t.Type) || t.Type == js.StringToken || js.IsNumeric(t.Type):
+			// Keys followed by a colon are not bindings
+			if i+1 < len(tokens) && tokens[i+1].Type == js.ColonToken {
+				i++
+				continue
+			}
+			if js.IsIdentifier(t.Type) {
+				names = append(names, string(t.Value))
+			}
+		}
+	}
+	return names
+}
+
+// IsFunctionExpression returns true if source is a function or arrow function expression
+func IsFunctionExpression(source []byte) bool {
+	tokens, ok := tokenize(source)
+	if !ok || len(tokens) == 0 {
+		return false
+	}
+	// Unwrap parenthesized expressions
+	for tokens[0].Type == js.OpenParenToken && matchingToken(tokens, 0) == len(tokens)-1 {
+		tokens = tokens[1 : len(tokens)-1]
+		if len(tokens) == 0 {
+			return false
+		}
+	}
+	first := tokens[0]
+	if first.Type == js.FunctionToken || (first.Type == js.AsyncToken && len(tokens) > 1 && tokens[1].Type == js.FunctionToken) {
+		return true
+	}
+	depth := 0
+	for _, t := range tokens {
+		if isOpenToken(t.Type) {
+			depth++
+		} else if isCloseToken(t.Type) {
+			depth--
+		} else if depth == 0 && t.Type == js.ArrowToken {
+			return true
+		}
+	}
+	return false
+}
+
+// GetNewExpression returns the constructor of a `new` expression like
+// `new Intl.DateTimeFormat()`. The second return value is false if source
+// is not a `new` expression.
+func GetNewExpression(source []byte) (string, bool) {
+	tokens, ok := tokenize(source)
+	if !ok || len(tokens) < 2 || tokens[0].Type != js.NewToken {
+		return "", false
+	}
+	constructor := ""
+	for _, t := range tokens[1:] {
+		if !js.IsIdentifierName(t.Type) && t.Type != js.DotToken {
+			break
+		}
+		constructor += string(t.Value)
+	}
+	return constructor, true
+}
+
+type PropertyKind uint32
+
+const (
+	KeyValueProperty PropertyKind = iota
+	ShorthandProperty
+	SpreadProperty
+	MethodProperty
+)
+
+// GetObjectProperty splits a property returned by GetObjectProperties into
+// its key and value. Spread properties have no key, and the value of a
+// method or shorthand property is the whole property.
+func GetObjectProperty(property []byte) (PropertyKind, []byte, []byte) {
+	tokens, _ := tokenize(property)
+	if len(tokens) == 0 {
+		return KeyValueProperty, nil, nil
+	}
+	if tokens[0].Type == js.EllipsisToken {
+		return SpreadProperty, nil, bytes.TrimSpace(property[tokens[0].End():])
+	}
+	if len(tokens) == 1 {
+		return ShorthandProperty, property, property
+	}
+	depth := 0
+	for i, t := range tokens {
+		if isOpenToken(t.Type) {
+			// Computed keys are the only brackets allowed before the colon
+			if t.Type == js.OpenParenToken && depth == 0 {
+				return MethodProperty, bytes.TrimSpace(property[:t.Start]), property
+			}
+			depth++
+		} else if isCloseToken(t.Type) {
+			depth--
+		} else if depth == 0 && t.Type == js.ColonToken {
+			return KeyValueProperty, bytes.TrimSpace(property[:t.Start]), bytes.TrimSpace(property[tokens[i].End():])
+		}
+	}
+	return ShorthandProperty, property, property
+}
+
+// A TypeMember is a property of an interface or object type, like `title?: string`
+type TypeMember struct {
+	Name string
+	// Type is the source of the type annotation. Methods are converted to function types.
+	Type     string
+	Optional bool
+	// Description is the text of the JSDoc comment preceding the member, if any
+	Description string
+}
+
+// GetPropsType returns the members of the `Props` interface or object type
+// declared at the top level of source. The second return value is false if
+// there is no `Props` declaration or if it isn't an object type.
+func GetPropsType(source []byte) ([]TypeMember, bool) {
+	tokens, _ := tokenize(source)
+	depth := 0
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if isOpenToken(t.Type) {
+			depth++
+			continue
+		} else if isCloseToken(t.Type) {
+			depth--
+			continue
+		}
+		if depth != 0 || i+1 >= len(tokens) || string(tokens[i+1].Value) != "Props" {
+			continue
+		}
+		isInterface := t.Type == js.InterfaceToken
+		isType := js.IsIdentifierName(t.Type) && string(t.Value) == "type"
+		if !isInterface && !isType {
+			continue
+		}
+		// Find the opening brace of the body
+		j := i + 2
+		for ; j < len(tokens); j++ {
+			tt := tokens[j].Type
+			if tt == js.OpenBraceToken {
+				break
+			}
+			if isType && tt != js.EqToken {
+				return nil, false
+			}
+		}
+		end := matchingToken(tokens, j)
+		if j >= len(tokens) || end == -1 {
+			return nil, false
+		}
+		return getTypeMembers(source, tokens[j+1:end]), true
+	}
+	return nil, false
+}
+
+func getTypeMembers(source []byte, tokens []token) []TypeMember {
+	members := make([]TypeMember, 0)
+	start := 0
+	depth := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) {
+			t := tokens[i]
+			isSeparator := depth == 0 && (t.Type == js.SemicolonToken || t.Type == js.CommaToken)
+			if !isSeparator && !(depth == 0 && i > start && t.NewlineBefore && isMemberEnd(tokens[start:i], t)) {
+				if isOpenToken(t.Type) || t.Type == js.LtToken {
+					depth++
+				} else if isCloseToken(t.Type) || t.Type == js.GtToken {
+					depth--
+				} else if t.Type == js.GtGtToken {
+					depth -= 2
+				}
+				continue
+			}
+		}
+		if member, ok := getTypeMember(source, tokens[start:i]); ok {
+			members = append(members, member)
+		}
+		start = i
+		if i < len(tokens) && (tokens[i].Type == js.SemicolonToken || tokens[i].Type == js.CommaToken) {
+			start++
+		}
+	}
+	return members
+}
+
+// isMemberEnd returns true if a newline before next ends the member in tokens
+func isMemberEnd(tokens []token, next token) bool {
+	last := tokens[len(tokens)-1].Type
+	switch last {
+	case js.ColonToken, js.QuestionToken, js.OrToken, js.BitOrToken, js.BitAndToken, js.ArrowToken, js.LtToken:
+		return false
+	}
+	switch next.Type {
+	case js.BitOrToken, js.BitAndToken, js.DotToken, js.ArrowToken:
+		return false
+	}
+	return true
+}
+
+func getTypeMember(source []byte, tokens []token) (TypeMember, bool) {
+	member := TypeMember{}
+	if len(tokens) == 0 {
+		return member, false
+	}
+	if comment := tokens[0].Comment; bytes.HasPrefix(comment, []byte("/**")) {
+		member.Description = getJSDocText(comment)
+	}
+	if len(tokens) > 1 && string(tokens[0].Value) == "readonly" && tokens[1].Type != js.ColonToken && tokens[1].Type != js.QuestionToken {
+		tokens = tokens[1:]
+	}
+	name := tokens[0]
+	switch {
+	case name.Type == js.StringToken:
+		member.Name = string(name.Value[1 : len(name.Value)-1])
+	case js.IsIdentifierName(name.Type) || js.IsNumeric(name.Type):
+		member.Name = string(name.Value)
+	default:
+		// Index signatures and call signatures don't describe a single prop
+		return member, false
+	}
+	i := 1
+	if i < len(tokens) && tokens[i].Type == js.QuestionToken {
+		member.Optional = true
+		i++
+	}
+	if i >= len(tokens) {
+		member.Type = "any"
+		return member, true
+	}
+	end := tokens[len(tokens)-1].End()
+	switch tokens[i].Type {
+	case js.ColonToken:
+		if i+1 >= len(tokens) {
+			return member, false
+		}
+		member.Type = string(source[tokens[i+1].Start:end])
+	case js.OpenParenToken, js.LtToken:
+		// Methods like `onClick(e: Event): void` are converted to `(e: Event) => void`
+		close := matchingToken(tokens, i)
+		if tokens[i].Type == js.LtToken || close == -1 {
+			return member, false
+		}
+		member.Type = string(source[tokens[i].Start:tokens[close].End()]) + " => "
+		if close+2 < len(tokens) && tokens[close+1].Type == js.ColonToken {
+			member.Type += string(source[tokens[close+2].Start:end])
+		} else {
+			member.Type += "any"
+		}
+	default:
+		return member, false
+	}
+	return member, true
+}
+
+// getJSDocText returns the text of a `/** ... */` comment, without the leading asterisks
+func getJSDocText(comment []byte) string {
+	comment = bytes.TrimSuffix(bytes.TrimPrefix(comment, []byte("/**")), []byte("*/"))
+	lines := make([]string, 0)
+	for _, line := range bytes.Split(comment, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		line = bytes.TrimSpace(bytes.TrimPrefix(line, []byte("*")))
+		lines = append(lines, string(line))
+	}
+	return string(bytes.TrimSpace([]byte(strings.Join(lines, "\n"))))
+}
+
+type TypeKind uint32
+
+const (
+	UnknownType TypeKind = iota
+	AnyType
+	StringType
+	NumberType
+	BooleanType
+	NullType
+	UndefinedType
+	ObjectType
+	// LiteralType is a string, number or boolean literal
+	LiteralType
+	ArrayType
+	// RecordType is `Record<string, T>` or an index signature
+	RecordType
+	UnionType
+	IntersectionType
+	FunctionType
+	// ReferenceType is a named type, like `Date` or an imported interface
+	ReferenceType
+)
+
+// A Type is a TypeScript type annotation, like `'a' | 'b'` or `{ name: string }[]`
+type Type struct {
+	Kind TypeKind
+	// Name is the name of a ReferenceType
+	Name string
+	// Literal is the value of a LiteralType: a string, float64 or bool
+	Literal interface{}
+	// Elem is the element type of an ArrayType or RecordType
+	Elem *Type
+	// Types are the members of a UnionType or IntersectionType
+	Types []*Type
+	// Members are the properties of an ObjectType
+	Members []TypeMember
+}
+
+// ParseType parses a type annotation returned by GetPropsType. Syntax that
+// isn't understood results in an UnknownType.
+func ParseType(source []byte) *Type {
+	tokens, ok := tokenize(source)
+	if !ok {
+		return &Type{Kind: UnknownType}
+	}
+	p := &typeParser{source: source, tokens: tokens}
+	t := p.parseUnion()
+	if p.i != len(p.tokens) {
+		return &Type{Kind: UnknownType}
+	}
+	return t
+}
+
+type typeParser struct {
+	source []byte
+	tokens []token
+	i      int
+}
+
+func (p *typeParser) peek() js.TokenType {
+	if p.i >= len(p.tokens) {
+		return js.ErrorToken
+	}
+	return p.tokens[p.i].Type
+}
+
+func (p *typeParser) parseUnion() *Type {
+	// Leading separators are allowed, like `| 'a' | 'b'`
+	if p.peek() == js.BitOrToken {
+		p.i++
+	}
+	types := []*Type{p.parseIntersection()}
+	for p.peek() == js.BitOrToken {
+		p.i++
+		types = append(types, p.parseIntersection())
+	}
+	if len(types) == 1 {
+		return types[0]
+	}
+	return &Type{Kind: UnionType, Types: types}
+}
+
+func (p *typeParser) parseIntersection() *Type {
+	if p.peek() == js.BitAndToken {
+		p.i++
+	}
+	types := []*Type{p.parsePostfix()}
+	for p.peek() == js.BitAndToken {
+		p.i++
+		types = append(types, p.parsePostfix())
+	}
+	if len(types) == 1 {
+		return types[0]
+	}
+	return &Type{Kind: IntersectionType, Types: types}
+}
+
+func (p *typeParser) parsePostfix() *Type {
+	t := p.parsePrimary()
+	for p.peek() == js.OpenBracketToken && p.i+1 < len(p.tokens) && p.tokens[p.i+1].Type == js.CloseBracketToken {
+		p.i += 2
+		t = &Type{Kind: ArrayType, Elem: t}
+	}
+	return t
+}
+
+func (p *typeParser) parsePrimary() *Type {
+	if p.i >= len(p.tokens) {
+		return &Type{Kind: UnknownType}
+	}
+	t := p.tokens[p.i]
+	switch {
+	case t.Type == js.StringToken:
+		p.i++
+		if str, ok := GetStaticString(t.Value); ok {
+			return &Type{Kind: LiteralType, Literal: str}
+		}
+		return &Type{Kind: StringType}
+	case js.IsNumeric(t.Type):
+		p.i++
+		if value, err := strconv.ParseFloat(string(t.Value), 64); err == nil {
+			return &Type{Kind: LiteralType, Literal: value}
+		}
+		return &Type{Kind: NumberType}
+	case t.Type == js.SubToken && p.i+1 < len(p.tokens) && js.IsNumeric(p.tokens[p.i+1].Type):
+		p.i += 2
+		if value, err := strconv.ParseFloat(string(p.tokens[p.i-1].Value), 64); err == nil {
+			return &Type{Kind: LiteralType, Literal: -value}
+		}
+		return &Type{Kind: NumberType}
+	case t.Type == js.TrueToken || t.Type == js.FalseToken:
+		p.i++
+		return &Type{Kind: LiteralType, Literal: t.Type == js.TrueToken}
+	case t.Type == js.NullToken:
+		p.i++
+		return &Type{Kind: NullType}
+	case t.Type == js.VoidToken:
+		p.i++
+		return &Type{Kind: UndefinedType}
+	case t.Type == js.OpenBraceToken:
+		end := matchingToken(p.tokens, p.i)
+		if end == -1 {
+			p.i = len(p.tokens)
+			return &Type{Kind: UnknownType}
+		}
+		members := p.tokens[p.i+1 : end]
+		p.i = end + 1
+		// `{ [key: string]: T }` is a record
+		if len(members) > 0 && members[0].Type == js.OpenBracketToken {
+			if close := matchingToken(members, 0); close != -1 && close+2 < len(members) && members[close+1].Type == js.ColonToken {
+				return &Type{Kind: RecordType, Elem: ParseType(p.source[members[close+2].Start:members[len(members)-1].End()])}
+			}
+		}
+		return &Type{Kind: ObjectType, Members: getTypeMembers(p.source, members)}
+	case t.Type == js.OpenParenToken:
+		end := matchingToken(p.tokens, p.i)
+		if end == -1 {
+			p.i = len(p.tokens)
+			return &Type{Kind: UnknownType}
+		}
+		// `(a: string) => void` is a function, `(a | b)` is grouping
+		if end+1 < len(p.tokens) && p.tokens[end+1].Type == js.ArrowToken {
+			p.i = end + 2
+			p.parseUnion()
+			return &Type{Kind: FunctionType}
+		}
+		inner := &typeParser{source: p.source, tokens: p.tokens[p.i+1 : end]}
+		p.i = end + 1
+		grouped := inner.parseUnion()
+		if inner.i != len(inner.tokens) {
+			return &Type{Kind: UnknownType}
+		}
+		return grouped
+	case js.IsIdentifierName(t.Type):
+		p.i++
+		name := string(t.Value)
+		for p.peek() == js.DotToken && p.i+1 < len(p.tokens) {
+			name += "." + string(p.tokens[p.i+1].Value)
+			p.i += 2
+		}
+		var args []*Type
+		if p.peek() == js.LtToken {
+			args = p.parseTypeArguments()
+		}
+		switch name {
+		case "string":
+			return &Type{Kind: StringType}
+		case "number":
+			return &Type{Kind: NumberType}
+		case "boolean":
+			return &Type{Kind: BooleanType}
+		case "undefined":
+			return &Type{Kind: UndefinedType}
+		case "any", "unknown":
+			return &Type{Kind: AnyType}
+		case "object":
+			return &Type{Kind: ObjectType}
+		case "Array", "ReadonlyArray":
+			if len(args) == 1 {
+				return &Type{Kind: ArrayType, Elem: args[0]}
+			}
+		case "Record":
+			if len(args) == 2 {
+				return &Type{Kind: RecordType, Elem: args[1]}
+			}
+		}
+		return &Type{Kind: ReferenceType, Name: name}
+	}
+	p.i = len(p.tokens)
+	return &Type{Kind: UnknownType}
+}
+
+// parseTypeArguments parses `<A, B>` and returns each argument
+func (p *typeParser) parseTypeArguments() []*Type {
+	args := make([]*Type, 0)
+	start := p.i + 1
+	depth := 0
+	for ; p.i < len(p.tokens); p.i++ {
+		tt := p.tokens[p.i].Type
+		closing := 0
+		switch {
+		case tt == js.LtToken || isOpenToken(tt):
+			depth++
+		case tt == js.GtToken || isCloseToken(tt):
+			closing = 1
+		case tt == js.GtGtToken:
+			closing = 2
+		}
+		// `>>` closes both a nested argument and this list, like `Array<Array<T>>`
+		if depth == 2 && closing == 2 {
+			args = append(args, ParseType(p.source[p.tokens[start].Start:p.tokens[p.i].Start+1]))
+			p.i++
+			return args
+		}
+		if depth == 1 && (tt == js.CommaToken || closing > 0) && p.i > start {
+			args = append(args, ParseType(p.source[p.tokens[start].Start:p.tokens[p.i].Start]))
+			start = p.i + 1
+		}
+		depth -= closing
+		if depth <= 0 {
+			p.i++
+			return args
+		}
+	}
+	return args
+}
+
+// matchingOpenToken returns the index of the token that opens tokens[i]
+func matchingOpenToken(tokens []token, i int) int {
+	depth := 0
+	for j := i; j >= 0; j-- {
+		if isCloseToken(tokens[j].Type) {
+			depth++
+		} else if isOpenToken(tokens[j].Type) {
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// getParamNames returns the names bound by the contents of a parameter list
+func getParamNames(tokens []token) []string {
+	names := make([]string, 0)
+	start := 0
+	depth := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) {
+			tt := tokens[i].Type
+			if isOpenToken(tt) {
+				depth++
+			} else if isCloseToken(tt) {
+				depth--
+			}
+			if tt != js.CommaToken || depth != 0 {
+				continue
+			}
+		}
+		param := tokens[start:i]
+		start = i + 1
+		if len(param) > 0 && param[0].Type == js.EllipsisToken {
+			param = param[1:]
+		}
+		if len(param) == 0 {
+			continue
+		}
+		if js.IsIdentifier(param[0].Type) {
+			names = append(names, string(param[0].Value))
+		} else if end := matchingToken(param, 0); isOpenToken(param[0].Type) && end != -1 {
+			names = append(names, getPatternNames(param[1:end])...)
+		}
+	}
+	return names
+}
+
+// GetLocalBindings returns the names declared anywhere in source, at any
+// depth, by variable, function and class declarations, function parameters
+// and catch clauses. Source may be an incomplete fragment of code, like the
+// text of an expression that is interrupted by an element.
+func GetLocalBindings(source []byte) []string {
+	names := make([]string, 0)
+	tokens, _ := tokenize(source)
+	for i, t := range tokens {
+		switch t.Type {
+		case js.VarToken, js.LetToken, js.ConstToken:
+			declarations := make([]Declaration, 0)
+			readVariableDeclarations(source, tokens, i+1, &declarations)
+			for _, declaration := range declarations {
+				names = append(names, declaration.Name)
+			}
+		case js.ArrowToken:
+			if i == 0 {
+				continue
+			}
+			if prev := tokens[i-1]; js.IsIdentifier(prev.Type) {
+				names = append(names, string(prev.Value))
+			} else if prev.Type == js.CloseParenToken {
+				if open := matchingOpenToken(tokens, i-1); open != -1 {
+					names = append(names, getParamNames(tokens[open+1:i-1])...)
+				}
+			}
+		case js.FunctionToken, js.ClassToken, js.CatchToken:
+			j := i + 1
+			if j < len(tokens) && tokens[j].Type == js.MulToken {
+				j++
+			}
+			if j < len(tokens) && js.IsIdentifier(tokens[j].Type) {
+				names = append(names, string(tokens[j].Value))
+				j++
+			}
+			if t.Type == js.ClassToken || j >= len(tokens) || tokens[j].Type != js.OpenParenToken {
+				continue
+			}
+			if end := matchingToken(tokens, j); end != -1 {
+				names = append(names, getParamNames(tokens[j+1:end])...)
+			}
+		}
+	}
+	return names
+}
+
+// A Reference is an identifier that is read by source, like `a` in `a.b`
+type Reference struct {
+	Name string
+	// Start is the offset of the identifier in source
+	Start int
+}
+
+// Contextual keywords that the lexer returns as identifiers
+var contextualKeywords = map[string]bool{
+	"as":        true,
+	"async":     true,
+	"from":      true,
+	"get":       true,
+	"of":        true,
+	"satisfies": true,
+	"set":       true,
+	"static":    true,
+}
+
+// GetReferences returns the identifiers read by source, in order. Property
+// names, object keys, labels and contextual keywords are not references, but names
+// declared by source are: compare against GetLocalBindings to find free variables.
+func GetReferences(source []byte) []Reference {
+	references := make([]Reference, 0)
+	tokens, _ := tokenize(source)
+	for i, t := range tokens {
+		if !js.IsIdentifier(t.Type) || contextualKeywords[string(t.Value)] {
+			continue
+		}
+		if i > 0 {
+			prev := tokens[i-1].Type
+			// Property access like `a.b` or `a?.b`
+			if prev == js.DotToken || prev == js.OptChainToken {
+				continue
+			}
+			// Object keys like `{ a: b }`
+			if (prev == js.OpenBraceToken || prev == js.CommaToken) && i+1 < len(tokens) && tokens[i+1].Type == js.ColonToken {
+				continue
+			}
+			// Label targets like `break outer`
+			if prev == js.BreakToken || prev == js.ContinueToken {
+				continue
+			}
+		}
+		// Labels like `outer: for (...) {}`
+		if isLabel(tokens, i) {
+			continue
+		}
+		references = append(references, Reference{Name: string(t.Value), Start: t.Start})
+	}
+	return references
+}
+
+// isLabel returns true if the identifier at tokens[i] labels a statement
+func isLabel(tokens []token, i int) bool {
+	if i+1 >= len(tokens) || tokens[i+1].Type != js.ColonToken {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch tokens[i-1].Type {
+	case js.SemicolonToken, js.CloseBraceToken, js.CloseParenToken:
+		return true
+	}
+	return false
+}
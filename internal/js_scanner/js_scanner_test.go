@@ -2,6 +2,7 @@ package js_scanner
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/withastro/compiler/internal/test_utils"
@@ -202,3 +203,529 @@ import { c } from "c";`,
 		})
 	}
 }
+
+func TestGetArrayElements(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+		ok     bool
+	}{
+		{
+			name:   "empty",
+			source: `[]`,
+			want:   []string{},
+			ok:     true,
+		},
+		{
+			name:   "strings",
+			source: `['a', "b", ` + "`c`" + `]`,
+			want:   []string{`'a'`, `"b"`, "`c`"},
+			ok:     true,
+		},
+		{
+			name:   "nested",
+			source: `[a, { b: c, d }, [e, f], fn(g, h)]`,
+			want:   []string{`a`, `{ b: c, d }`, `[e, f]`, `fn(g, h)`},
+			ok:     true,
+		},
+		{
+			name:   "template literal",
+			source: "[`${a}, ${b}`, c]",
+			want:   []string{"`${a}, ${b}`", `c`},
+			ok:     true,
+		},
+		{
+			name:   "regexp and division",
+			source: `[/,/.test(a) && 'b', c / 2]`,
+			want:   []string{`/,/.test(a) && 'b'`, `c / 2`},
+			ok:     true,
+		},
+		{
+			name:   "trailing comma and comments",
+			source: "[\n\t'a', // first\n\t'b',\n]",
+			want:   []string{`'a'`, `'b'`},
+			ok:     true,
+		},
+		{
+			name:   "identifier",
+			source: `classes`,
+			ok:     false,
+		},
+		{
+			name:   "member access",
+			source: `[a, b].filter(Boolean)`,
+			ok:     false,
+		},
+		{
+			name:   "unterminated",
+			source: `[a, b`,
+			ok:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, ok := GetArrayElements([]byte(tt.source))
+			if ok != tt.ok {
+				t.Fatalf("expected ok to be %v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			got := make([]string, 0)
+			for _, item := range items {
+				got = append(got, string(item))
+			}
+			if diff := test_utils.ANSIDiff(tt.want, got); diff != "" {
+				t.Error(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
+			}
+		})
+	}
+}
+
+func TestGetStaticString(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+		ok     bool
+	}{
+		{`'a b'`, "a b", true},
+		{`"a"`, "a", true},
+		{"`a`", "a", true},
+		{"`${a}`", "", false},
+		{`'a\'b'`, "", false},
+		{`a`, "", false},
+		{`'a' + 'b'`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got, ok := GetStaticString([]byte(tt.source))
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("expected (%q, %v), got (%q, %v)", tt.want, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestNextImportStatementTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []Import
+	}{
+		{
+			name:   "default",
+			source: "import A from 'a';\n",
+			want:   []Import{{ExportName: "default", LocalName: "A"}},
+		},
+		{
+			name:   "type default",
+			source: "import type A from 'a';\n",
+			want:   []Import{{ExportName: "default", LocalName: "A", IsType: true}},
+		},
+		{
+			name:   "type named",
+			source: "import type { A, B as C } from 'a';\n",
+			want:   []Import{{ExportName: "A", LocalName: "A", IsType: true}, {ExportName: "B", LocalName: "C", IsType: true}},
+		},
+		{
+			name:   "inline type",
+			source: "import { type A, B } from 'a';\n",
+			want:   []Import{{ExportName: "A", LocalName: "A", IsType: true}, {ExportName: "B", LocalName: "B"}},
+		},
+		{
+			name:   "binding named type",
+			source: "import type, { type as t } from 'a';\n",
+			want:   []Import{{ExportName: "default", LocalName: "type"}, {ExportName: "type", LocalName: "t"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, statement := NextImportStatement([]byte(tt.source), 0)
+			if diff := test_utils.ANSIDiff(tt.want, statement.Imports); diff != "" {
+				t.Error(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
+			}
+		})
+	}
+}
+
+func TestGetTopLevelDeclarations(t *testing.T) {
+	source := `import A from 'a';
+const a = 1, b = () => {}
+let { c, d: e, f = g, ...h } = obj
+const [i, , j = 2] = arr
+export const k: Map<string, number> = new Map()
+function l() { const inner = 1 }
+async function* m() {}
+class N {}
+const o = foo
+  .bar()
+var p
+if (x) { const q = 1 }
+export async function r() {}`
+	want := []string{
+		`const a = 1`,
+		`const b = () => {}`,
+		`const c = obj (destructured)`,
+		`const e = obj (destructured)`,
+		`const f = obj (destructured)`,
+		`const h = obj (destructured)`,
+		`const i = arr (destructured)`,
+		`const j = arr (destructured)`,
+		`export const k = new Map()`,
+		`function l`,
+		`function m`,
+		`class N`,
+		"const o = foo\n  .bar()",
+		`const p`,
+		`export function r`,
+	}
+	got := make([]string, 0)
+	for _, declaration := range GetTopLevelDeclarations([]byte(source)) {
+		str := ""
+		if declaration.Exported {
+			str = "export "
+		}
+		switch declaration.Kind {
+		case FunctionDeclaration:
+			str += "function " + declaration.Name
+		case ClassDeclaration:
+			str += "class " + declaration.Name
+		default:
+			str += "const " + declaration.Name
+			if declaration.Init != nil {
+				str += " = " + string(declaration.Init)
+			}
+			if declaration.Destructured {
+				str += " (destructured)"
+			}
+		}
+		got = append(got, str)
+	}
+	if diff := test_utils.ANSIDiff(want, got); diff != "" {
+		t.Error(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
+	}
+}
+
+func TestGetExportStatements(t *testing.T) {
+	source := `import A from 'a';
+export const a = 1, { b, c: d } = obj
+export let e = foo
+  .bar();
+export function f(x = {}) {
+  return x
+}
+export default async function () {}
+export class G extends H {}
+export { a as default, b, type T, d as "e-f" };
+export type { I } from './i';
+export * from './j'
+export * as k from './k' assert { type: 'json' }
+export interface Props {
+  title: string
+}
+export type L =
+  | 'a'
+  | 'b'
+export declare const m: string
+export const enum N { A }
+const inner = () => { export const nope = 1 }
+export default o;
+module.exports = p`
+	want := []string{
+		"export const a = 1, { b, c: d } = obj: a, b, d",
+		"export let e = foo\n  .bar();: e",
+		"export function f(x = {}) {\n  return x\n}: f",
+		"export default async function () {}: default",
+		"export class G extends H {}: G",
+		`export { a as default, b, type T, d as "e-f" };: a as default, b, type T, d as e-f`,
+		"export type { I } from './i';: type I from ./i (type)",
+		"export * from './j': * from ./j",
+		"export * as k from './k' assert { type: 'json' }: * as k from ./k assert {type:'json'}",
+		"export interface Props {\n  title: string\n}: type Props (type)",
+		"export type L =\n  | 'a'\n  | 'b': type L (type)",
+		"export declare const m: string: m",
+		"export const enum N { A }: N",
+		"export default o;: o as default",
+	}
+	got := make([]string, 0)
+	for _, statement := range GetExportStatements([]byte(source)) {
+		names := make([]string, 0)
+		for _, export := range statement.Exports {
+			name := export.LocalName
+			if name == "" || export.LocalName == "*" {
+				name = export.ExportName
+			} else if export.ExportName != export.LocalName {
+				name += " as " + export.ExportName
+			}
+			if export.LocalName == "*" {
+				name = "* as " + export.ExportName
+			}
+			if export.IsType {
+				name = "type " + name
+			}
+			names = append(names, name)
+		}
+		str := source[statement.Start:statement.End] + ": " + strings.Join(names, ", ")
+		if statement.Specifier != "" {
+			str += " from " + statement.Specifier
+		}
+		if statement.Assertions != "" {
+			str += " assert " + statement.Assertions
+		}
+		if statement.IsType {
+			str += " (type)"
+		}
+		got = append(got, str)
+	}
+	if diff := test_utils.ANSIDiff(want, got); diff != "" {
+		t.Error(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
+	}
+}
+
+func TestIsFunctionExpression(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{`() => 1`, true},
+		{`a => a`, true},
+		{`async (a, b) => a`, true},
+		{`function () {}`, true},
+		{`async function named() {}`, true},
+		{`(() => 1)`, true},
+		{`list.map((x) => x)`, false},
+		{`fn`, false},
+		{`{ a: () => 1 }`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if got := IsFunctionExpression([]byte(tt.source)); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetObjectProperty(t *testing.T) {
+	tests := []struct {
+		source string
+		kind   PropertyKind
+		key    string
+		value  string
+	}{
+		{`a: 1`, KeyValueProperty, `a`, `1`},
+		{`'a-b': c ? d : e`, KeyValueProperty, `'a-b'`, `c ? d : e`},
+		{`[key]: () => 1`, KeyValueProperty, `[key]`, `() => 1`},
+		{`a`, ShorthandProperty, `a`, `a`},
+		{`...b`, SpreadProperty, ``, `b`},
+		{`fn() {}`, MethodProperty, `fn`, `fn() {}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			kind, key, value := GetObjectProperty([]byte(tt.source))
+			if kind != tt.kind || string(key) != tt.key || string(value) != tt.value {
+				t.Errorf("expected (%v, %q, %q), got (%v, %q, %q)", tt.kind, tt.key, tt.value, kind, key, value)
+			}
+		})
+	}
+}
+
+func TestGetPropsType(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []TypeMember
+		ok     bool
+	}{
+		{
+			name: "interface",
+			source: `import type { Base } from './types';
+export interface Props extends Base {
+	/** The title of the card */
+	title: string
+	/**
+	 * Number of items
+	 * @default 1
+	 */
+	count?: number;
+	readonly 'data-id': string,
+	kind:
+		| 'a'
+		| 'b'
+	items: Array<{ name: string, value: number }>
+	onClick(e: MouseEvent): void
+	[key: string]: any
+}
+const { title } = Astro.props as Props;`,
+			want: []TypeMember{
+				{Name: "title", Type: "string", Description: "The title of the card"},
+				{Name: "count", Type: "number", Optional: true, Description: "Number of items\n@default 1"},
+				{Name: "data-id", Type: "string"},
+				{Name: "kind", Type: "| 'a'\n\t\t| 'b'"},
+				{Name: "items", Type: "Array<{ name: string, value: number }>"},
+				{Name: "onClick", Type: "(e: MouseEvent) => void"},
+			},
+			ok: true,
+		},
+		{
+			name:   "type alias",
+			source: `type Props = { a: string; b?: () => void }`,
+			want:   []TypeMember{{Name: "a", Type: "string"}, {Name: "b", Type: "() => void", Optional: true}},
+			ok:     true,
+		},
+		{
+			name:   "intersection",
+			source: `type Props = Base & { a: string }`,
+			ok:     false,
+		},
+		{
+			name:   "nested",
+			source: `function fn() { interface Props { a: string } }`,
+			ok:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := GetPropsType([]byte(tt.source))
+			if ok != tt.ok {
+				t.Fatalf("expected ok to be %v, got %v", tt.ok, ok)
+			}
+			if !ok {
+				return
+			}
+			if diff := test_utils.ANSIDiff(tt.want, got); diff != "" {
+				t.Error(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
+			}
+		})
+	}
+}
+
+func TestParseType(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`string`, `string`},
+		{`| 'a'
+	| 'b'`, `'a' | 'b'`},
+		{`-1 | 2 | true`, `-1 | 2 | true`},
+		{`string[][]`, `string[][]`},
+		{`Array<Array<number>>`, `number[][]`},
+		{`('a' | 'b')[]`, `('a' | 'b')[]`},
+		{`Record<string, boolean>`, `Record<boolean>`},
+		{`{ [key: string]: number }`, `Record<number>`},
+		{`{ a: string; b?: null }`, `{ a: string; b?: null }`},
+		{`A & { b: undefined }`, `A & { b: undefined }`},
+		{`(e: Event) => void`, `function`},
+		{`Astro.Props`, `Astro.Props`},
+		{`typeof value`, `unknown`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if got := printType(ParseType([]byte(tt.source))); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func printType(t *Type) string {
+	switch t.Kind {
+	case AnyType:
+		return "any"
+	case StringType:
+		return "string"
+	case NumberType:
+		return "number"
+	case BooleanType:
+		return "boolean"
+	case NullType:
+		return "null"
+	case UndefinedType:
+		return "undefined"
+	case LiteralType:
+		if str, ok := t.Literal.(string); ok {
+			return "'" + str + "'"
+		}
+		return fmt.Sprint(t.Literal)
+	case ArrayType:
+		if t.Elem.Kind == UnionType {
+			return "(" + printType(t.Elem) + ")[]"
+		}
+		return printType(t.Elem) + "[]"
+	case RecordType:
+		return "Record<" + printType(t.Elem) + ">"
+	case ObjectType:
+		members := make([]string, 0)
+		for _, member := range t.Members {
+			optional := ""
+			if member.Optional {
+				optional = "?"
+			}
+			members = append(members, member.Name+optional+": "+printType(ParseType([]byte(member.Type))))
+		}
+		return "{ " + strings.Join(members, "; ") + " }"
+	case UnionType, IntersectionType:
+		separator := " | "
+		if t.Kind == IntersectionType {
+			separator = " & "
+		}
+		types := make([]string, 0)
+		for _, member := range t.Types {
+			types = append(types, printType(member))
+		}
+		return strings.Join(types, separator)
+	case FunctionType:
+		return "function"
+	case ReferenceType:
+		return t.Name
+	}
+	return "unknown"
+}
+
+func TestGetLocalBindings(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{`items.map((item, i) => `, []string{"item", "i"}},
+		{`items.map(item => item.name)`, []string{"item"}},
+		{`entries.map(([key, { value }], ...rest) => `, []string{"key", "value", "rest"}},
+		{`(() => { const a = 1, { b } = c; let d; })()`, []string{"a", "b", "d"}},
+		{`list.forEach(function named(x) {})`, []string{"named", "x"}},
+		{`try {} catch (err) {}`, []string{"err"}},
+		{`a + b`, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if diff := test_utils.ANSIDiff(tt.want, GetLocalBindings([]byte(tt.source))); diff != "" {
+				t.Error(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
+			}
+		})
+	}
+}
+
+func TestGetReferences(t *testing.T) {
+	tests := []struct {
+		source string
+		want   []Reference
+	}{
+		{`a.b?.c`, []Reference{{Name: "a", Start: 0}}},
+		{`{ key: value, shorthand }`, []Reference{{Name: "value", Start: 7}, {Name: "shorthand", Start: 14}}},
+		{`cond ? yes : no`, []Reference{{Name: "cond", Start: 0}, {Name: "yes", Start: 7}, {Name: "no", Start: 13}}},
+		{"`${a}b`", []Reference{{Name: "a", Start: 3}}},
+		{`async (x) => x`, []Reference{{Name: "x", Start: 7}, {Name: "x", Start: 13}}},
+		{`this.value instanceof Foo`, []Reference{{Name: "Foo", Start: 22}}},
+		{`label: 1`, []Reference{}},
+		{`outer: for (x of xs) { if (x) continue outer; break outer }`, []Reference{{Name: "x", Start: 12}, {Name: "xs", Start: 17}, {Name: "x", Start: 27}}},
+		{`a ? b ? c : d : e`, []Reference{{Name: "a", Start: 0}, {Name: "b", Start: 4}, {Name: "c", Start: 8}, {Name: "d", Start: 12}, {Name: "e", Start: 16}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if diff := test_utils.ANSIDiff(tt.want, GetReferences([]byte(tt.source))); diff != "" {
+				t.Error(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
+			}
+		})
+	}
+}
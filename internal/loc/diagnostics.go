@@ -0,0 +1,46 @@
+package loc
+
+type DiagnosticSeverity int
+
+const (
+	ErrorType       DiagnosticSeverity = 1
+	WarningType     DiagnosticSeverity = 2
+	InformationType DiagnosticSeverity = 3
+	HintType        DiagnosticSeverity = 4
+)
+
+type DiagnosticCode int
+
+const (
+	ERROR                              DiagnosticCode = 1000
+	ERROR_UNSUPPORTED_DIRECTIVE_TARGET DiagnosticCode = 1001
+	ERROR_UNKNOWN_CLIENT_DIRECTIVE     DiagnosticCode = 1002
+	ERROR_MISSING_DIRECTIVE_VALUE      DiagnosticCode = 1003
+	ERROR_HYDRATED_TYPE_IMPORT         DiagnosticCode = 1004
+	ERROR_STYLE_PREPROCESSOR           DiagnosticCode = 1005
+	ERROR_SCRIPT_PREPROCESSOR          DiagnosticCode = 1006
+	ERROR_PARSE                        DiagnosticCode = 1007
+	ERROR_INVALID_DIRECTIVE_OPTION     DiagnosticCode = 1008
+	WARNING                            DiagnosticCode = 2000
+	WARNING_UNKNOWN_DIRECTIVE          DiagnosticCode = 2001
+	WARNING_UNKNOWN_CSS_PROPERTY       DiagnosticCode = 2002
+	WARNING_NON_SERIALIZABLE_PROP      DiagnosticCode = 2003
+	WARNING_UNDEFINED_IDENTIFIER       DiagnosticCode = 2004
+	WARNING_UNUSED_IMPORT              DiagnosticCode = 2005
+	WARNING_UNUSED_VARIABLE            DiagnosticCode = 2006
+	WARNING_SET_WITH_CHILDREN          DiagnosticCode = 2007
+	WARNING_DEPRECATED_DIRECTIVE       DiagnosticCode = 2008
+	WARNING_IGNORED_SCRIPT             DiagnosticCode = 2009
+)
+
+// A Diagnostic is an error or warning about a range of the source text
+type Diagnostic struct {
+	Severity DiagnosticSeverity
+	Code     DiagnosticCode
+	Text     string
+	Range    Range
+}
+
+func (d Diagnostic) Error() string {
+	return d.Text
+}
@@ -84,6 +84,11 @@ type Node struct {
 	HydratedComponents   []*Node
 	ClientOnlyComponents []*Node
 	HydrationDirectives  map[string]bool
+	Diagnostics          []loc.Diagnostic
+	// StyleSourceMaps are the source maps returned by style preprocessors, keyed by <style> element
+	StyleSourceMaps map[*Node]string
+	// checkpoints are used by Reparse, if the document was parsed with ParseOptionIncremental
+	checkpoints map[*Node]*checkpoint
 
 	Type      NodeType
 	DataAtom  atom.Atom
@@ -166,6 +171,15 @@ func (n *Node) RemoveChild(c *Node) {
 	c.NextSibling = nil
 }
 
+// AppendDiagnostic records a diagnostic on the document root Node
+func (n *Node) AppendDiagnostic(d loc.Diagnostic) {
+	root := n
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	root.Diagnostics = append(root.Diagnostics, d)
+}
+
 func GetAttribute(n *Node, key string) *Attribute {
 	for _, attr := range n.Attr {
 		if attr.Key == key {
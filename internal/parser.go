@@ -56,6 +56,9 @@ type parser struct {
 	// context is the context element when parsing an HTML fragment
 	// (section 12.4).
 	context *Node
+	// checkpoints are recorded for each element if the document can be
+	// reparsed incrementally, see ParseOptionIncremental.
+	checkpoints map[*Node]*checkpoint
 }
 
 func (p *parser) top() *Node {
@@ -2789,24 +2792,52 @@ func (p *parser) parseCurrentToken() {
 	}
 }
 
-func (p *parser) parse() error {
+func (p *parser) parse() (err error) {
+	// Panics, like using attributes on a `<>` Fragment, are returned as errors
+	// located at the token that was being parsed
+	defer func() {
+		if r := recover(); r != nil {
+			err = loc.Diagnostic{
+				Severity: loc.ErrorType,
+				Code:     loc.ERROR_PARSE,
+				Text:     strings.TrimSpace(fmt.Sprint(r)),
+				Range:    loc.Range{Loc: loc.Loc{Start: p.tokenizer.raw.Start}, Len: p.tokenizer.raw.End - p.tokenizer.raw.Start},
+			}
+		}
+	}()
+
 	// Iterate until EOF. Any other error will cause an early return.
-	var err error
 	for err != io.EOF {
-		// CDATA sections are allowed only in foreign content.
-		n := p.oe.top()
-		p.tokenizer.AllowCDATA(n != nil && n.Namespace != "")
-		p.ltok = p.tok
-		// Read and parse the next token.
-		p.tokenizer.Next()
-		p.tok = p.tokenizer.Token()
-		if p.tok.Type == ErrorToken {
-			err = p.tokenizer.Err()
-			if err != nil && err != io.EOF {
-				return err
-			}
+		err = p.parseNextToken()
+		if err != nil && err != io.EOF {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseNextToken reads and parses the next token. It returns io.EOF once the
+// end of the input has been parsed.
+func (p *parser) parseNextToken() error {
+	// CDATA sections are allowed only in foreign content.
+	n := p.oe.top()
+	p.tokenizer.AllowCDATA(n != nil && n.Namespace != "")
+	p.ltok = p.tok
+	// Read and parse the next token.
+	p.tokenizer.Next()
+	p.tok = p.tokenizer.Token()
+	if p.tok.Type == ErrorToken {
+		if err := p.tokenizer.Err(); err != nil && err != io.EOF {
+			return err
 		}
+	}
+	if p.checkpoints == nil {
 		p.parseCurrentToken()
+	} else {
+		p.parseCurrentTokenWithCheckpoints()
+	}
+	if p.tok.Type == ErrorToken {
+		return p.tokenizer.Err()
 	}
 	return nil
 }
@@ -2869,6 +2900,7 @@ func ParseWithOptions(r io.Reader, opts ...ParseOption) (*Node, error) {
 	if err := p.parse(); err != nil {
 		return nil, err
 	}
+	p.doc.checkpoints = p.checkpoints
 	return p.doc, nil
 }
 
@@ -5,6 +5,8 @@ import (
 	"strings"
 )
 
+// PrintToSource prints node back to .astro source. Parsing the result gives
+// the same tree, apart from the positions of the nodes.
 func PrintToSource(buf *strings.Builder, node *Node) {
 	switch node.Type {
 	case DocumentNode:
@@ -13,30 +15,50 @@ func PrintToSource(buf *strings.Builder, node *Node) {
 		}
 	case TextNode:
 		buf.WriteString(node.Data)
+	case CommentNode:
+		buf.WriteString("<!--")
+		buf.WriteString(node.Data)
+		buf.WriteString("-->")
+	case DoctypeNode:
+		buf.WriteString("<!DOCTYPE ")
+		buf.WriteString(node.Data)
+		buf.WriteString(">")
+	case FrontmatterNode:
+		if isImplicitNode(node) {
+			return
+		}
+		buf.WriteString("---")
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			PrintToSource(buf, c)
+		}
+		// The whitespace after the closing fence isn't part of the tree, but it can't be left out
+		buf.WriteString("---\n")
 	case ElementNode:
-		isImplicit := false
-		for _, a := range node.Attr {
-			if a.Key == ImplicitNodeMarker {
-				isImplicit = true
-				break
+		if node.Expression {
+			buf.WriteString("{")
+			for c := node.FirstChild; c != nil; c = c.NextSibling {
+				PrintToSource(buf, c)
 			}
+			buf.WriteString("}")
+			return
 		}
+		isImplicit := isImplicitNode(node)
 		if !isImplicit {
 			buf.WriteString(fmt.Sprintf(`<%s`, node.Data))
 			for _, attr := range node.Attr {
 				if attr.Key == ImplicitNodeMarker {
 					continue
 				}
+				buf.WriteString(" ")
 				if attr.Namespace != "" {
 					buf.WriteString(attr.Namespace)
 					buf.WriteString(":")
 				}
-				buf.WriteString(" ")
 				switch attr.Type {
 				case QuotedAttribute:
 					buf.WriteString(attr.Key)
 					buf.WriteString("=")
-					buf.WriteString(`"` + attr.Val + `"`)
+					buf.WriteString(quoteAttributeValue(attr.Val))
 				case EmptyAttribute:
 					buf.WriteString(attr.Key)
 				case ExpressionAttribute:
@@ -44,10 +66,9 @@ func PrintToSource(buf *strings.Builder, node *Node) {
 					buf.WriteString("=")
 					buf.WriteString(`{` + strings.TrimSpace(attr.Val) + `}`)
 				case SpreadAttribute:
-					buf.WriteString(`{...` + strings.TrimSpace(attr.Val) + `}`)
+					// The expression of a spread attribute is its key
+					buf.WriteString(`{...` + strings.TrimSpace(attr.Key) + `}`)
 				case ShorthandAttribute:
-					buf.WriteString(attr.Key)
-					buf.WriteString("=")
 					buf.WriteString(`{` + strings.TrimSpace(attr.Key) + `}`)
 				case TemplateLiteralAttribute:
 					buf.WriteString(attr.Key)
@@ -59,8 +80,29 @@ func PrintToSource(buf *strings.Builder, node *Node) {
 		for c := node.FirstChild; c != nil; c = c.NextSibling {
 			PrintToSource(buf, c)
 		}
-		if !isImplicit {
+		if !isImplicit && !VoidElements[node.Data] {
 			buf.WriteString(fmt.Sprintf(`</%s>`, node.Data))
 		}
 	}
 }
+
+func isImplicitNode(node *Node) bool {
+	for _, a := range node.Attr {
+		if a.Key == ImplicitNodeMarker {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteAttributeValue quotes the decoded value of a quoted attribute,
+// escaping it only if it contains both kinds of quotes
+func quoteAttributeValue(value string) string {
+	if !strings.Contains(value, `"`) {
+		return `"` + value + `"`
+	}
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+	return `"` + strings.NewReplacer("&", "&amp;", `"`, "&quot;").Replace(value) + `"`
+}
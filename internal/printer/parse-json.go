@@ -0,0 +1,129 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+	a "golang.org/x/net/html/atom"
+)
+
+var attributeKinds = map[string]AttributeType{
+	"quoted":           QuotedAttribute,
+	"empty":            EmptyAttribute,
+	"expression":       ExpressionAttribute,
+	"spread":           SpreadAttribute,
+	"shorthand":        ShorthandAttribute,
+	"template-literal": TemplateLiteralAttribute,
+}
+
+// ParseJSON rebuilds a document from the AST printed by PrintToJSON. The
+// positions of the nodes are kept if the AST has them, but the elements that
+// PrintToJSON leaves out, like an implicit <html>, aren't added back.
+//
+// The tree can be printed with PrintToSource. Nodes that were changed after
+// the AST was printed may have the wrong positions, so parsing the printed
+// source again is the safest way to transform the tree.
+func ParseJSON(data []byte) (*Node, error) {
+	var root ASTNode
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if root.Type != "root" {
+		return nil, fmt.Errorf("expected a root node, got %q", root.Type)
+	}
+	doc := &Node{Type: DocumentNode, Loc: astLoc(root.Position)}
+	if err := appendASTChildren(doc, root.Children); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func appendASTChildren(parent *Node, children []ASTNode) error {
+	for _, child := range children {
+		n, err := astToNode(child)
+		if err != nil {
+			return err
+		}
+		parent.AppendChild(n)
+	}
+	return nil
+}
+
+func astToNode(node ASTNode) (*Node, error) {
+	n := &Node{Loc: astLoc(node.Position)}
+	switch node.Type {
+	case "text":
+		n.Type = TextNode
+		n.Data = node.Value
+		return n, nil
+	case "comment":
+		n.Type = CommentNode
+		n.Data = node.Value
+		return n, nil
+	case "doctype":
+		n.Type = DoctypeNode
+		n.Data = node.Value
+		return n, nil
+	case "frontmatter":
+		n.Type = FrontmatterNode
+		if node.Value != "" {
+			n.AppendChild(&Node{Type: TextNode, Data: node.Value})
+		}
+		return n, nil
+	case "expression":
+		n.Type = ElementNode
+		n.DataAtom = a.Template
+		n.Data = "astro:expression"
+		n.Attr = make([]Attribute, 0)
+		n.Expression = true
+	case "element", "component", "custom-element", "fragment":
+		n.Type = ElementNode
+		n.DataAtom = a.Lookup([]byte(node.Name))
+		n.Data = node.Name
+		n.Component = node.Type == "component"
+		n.CustomElement = node.Type == "custom-element"
+		n.Fragment = node.Type == "fragment"
+		n.Attr = make([]Attribute, 0, len(node.Attributes))
+		for _, attrNode := range node.Attributes {
+			attr, err := astToAttribute(attrNode)
+			if err != nil {
+				return nil, err
+			}
+			n.Attr = append(n.Attr, attr)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected %q node", node.Type)
+	}
+	if err := appendASTChildren(n, node.Children); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func astToAttribute(node ASTNode) (Attribute, error) {
+	if node.Type != "attribute" {
+		return Attribute{}, fmt.Errorf("expected an attribute, got %q", node.Type)
+	}
+	kind, ok := attributeKinds[node.Kind]
+	if !ok {
+		return Attribute{}, fmt.Errorf("unknown kind of attribute %q", node.Kind)
+	}
+	attr := Attribute{Key: node.Name, Val: node.Value, Type: kind}
+	if node.Position.Start.Line != 0 {
+		attr.KeyLoc = loc.Loc{Start: node.Position.Start.Offset}
+	}
+	return attr, nil
+}
+
+func astLoc(position ASTPosition) []loc.Loc {
+	if position.Start.Line == 0 {
+		return nil
+	}
+	locs := []loc.Loc{{Start: position.Start.Offset}}
+	if position.End.Line != 0 {
+		locs = append(locs, loc.Loc{Start: position.End.Offset})
+	}
+	return locs
+}
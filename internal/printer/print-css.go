@@ -1,6 +1,8 @@
 package printer
 
 import (
+	"bufio"
+	"bytes"
 	"strings"
 
 	. "github.com/withastro/compiler/internal"
@@ -14,22 +16,26 @@ type PrintCSSResult struct {
 }
 
 func PrintCSS(sourcetext string, doc *Node, opts transform.TransformOptions) PrintCSSResult {
+	var output bytes.Buffer
 	p := &printer{
 		opts:    opts,
-		builder: sourcemap.MakeChunkBuilder(nil, sourcemap.GenerateLineOffsetTables(sourcetext, len(strings.Split(sourcetext, "\n")))),
+		out:     bufio.NewWriter(&output),
+		builder: newChunkBuilder(sourcetext),
+		doc:     doc,
 	}
 
 	result := PrintCSSResult{
-		SourceMapChunk: p.builder.GenerateChunk(p.output),
+		SourceMapChunk: p.builder.GenerateChunk(),
 	}
 
 	if len(doc.Styles) > 0 {
 		for _, style := range doc.Styles {
 			if style.FirstChild != nil && strings.TrimSpace(style.FirstChild.Data) != "" {
 				p.addSourceMapping(style.Loc[0])
-				p.print(escapeText(strings.TrimSpace(style.FirstChild.Data)))
-				result.Output = append(result.Output, p.output)
-				p.output = []byte{}
+				p.printStyleContent(style)
+				p.out.Flush()
+				result.Output = append(result.Output, append([]byte(nil), output.Bytes()...))
+				output.Reset()
 				p.addNilSourceMapping()
 			}
 		}
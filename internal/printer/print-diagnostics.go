@@ -0,0 +1,129 @@
+package printer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/withastro/compiler/internal/loc"
+	"github.com/withastro/compiler/internal/sourcemap"
+	"github.com/withastro/compiler/internal/transform"
+)
+
+type DiagnosticLocation struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Length int    `json:"length"`
+}
+
+type DiagnosticMessage struct {
+	Severity int                 `json:"severity"`
+	Code     int                 `json:"code"`
+	Text     string              `json:"text"`
+	Location *DiagnosticLocation `json:"location,omitempty"`
+}
+
+// PrintDiagnostics resolves the line and column of each diagnostic in the source text
+func PrintDiagnostics(sourcetext string, filename string, diagnostics []loc.Diagnostic) []DiagnosticMessage {
+	messages := make([]DiagnosticMessage, 0, len(diagnostics))
+	if len(diagnostics) == 0 {
+		return messages
+	}
+	builder := sourcemap.MakeChunkBuilder(nil, sourcemap.GenerateLineOffsetTables(sourcetext, len(strings.Split(sourcetext, "\n"))))
+	for _, d := range diagnostics {
+		position := builder.GetLineAndColumnForLocation(d.Range.Loc)
+		messages = append(messages, DiagnosticMessage{
+			Severity: int(d.Severity),
+			Code:     int(d.Code),
+			Text:     d.Text,
+			Location: &DiagnosticLocation{
+				File:   filename,
+				Line:   position[0],
+				Column: position[1],
+				Length: d.Range.Len,
+			},
+		})
+	}
+	return messages
+}
+
+// A CompileError describes an error that prevented a file from being compiled
+type CompileError struct {
+	Message  string              `json:"message"`
+	Code     int                 `json:"code"`
+	Location *DiagnosticLocation `json:"loc,omitempty"`
+	// Frame shows the lines of source text around the location of the error
+	Frame string `json:"frame,omitempty"`
+}
+
+// PrintError resolves the location of err in the source text, if it has one.
+// Errors without a location, like panics outside of the parser, use the ERROR code.
+func PrintError(sourcetext string, filename string, err error) CompileError {
+	result := CompileError{Message: err.Error(), Code: int(loc.ERROR)}
+	var r *loc.Range
+	var diagnostic loc.Diagnostic
+	var preprocessorErr *transform.PreprocessorError
+	if errors.As(err, &diagnostic) {
+		result.Code = int(diagnostic.Code)
+		r = &diagnostic.Range
+	} else if errors.As(err, &preprocessorErr) {
+		result.Code = int(loc.ERROR_STYLE_PREPROCESSOR)
+		if preprocessorErr.Element == "script" {
+			result.Code = int(loc.ERROR_SCRIPT_PREPROCESSOR)
+		}
+		r = &loc.Range{Loc: preprocessorErr.Loc, Len: len(preprocessorErr.Element)}
+	}
+	if r == nil {
+		return result
+	}
+
+	builder := sourcemap.MakeChunkBuilder(nil, sourcemap.GenerateLineOffsetTables(sourcetext, len(strings.Split(sourcetext, "\n"))))
+	position := builder.GetLineAndColumnForLocation(r.Loc)
+	result.Location = &DiagnosticLocation{
+		File:   filename,
+		Line:   position[0],
+		Column: position[1],
+		Length: r.Len,
+	}
+	result.Frame = printCodeFrame(sourcetext, position[0], position[1])
+	return result
+}
+
+// printCodeFrame prints up to two lines before and after the 1-based line, with the column marked
+func printCodeFrame(sourcetext string, line int, column int) string {
+	lines := strings.Split(sourcetext, "\n")
+	start := line - 2
+	if start < 1 {
+		start = 1
+	}
+	end := line + 2
+	if end > len(lines) {
+		end = len(lines)
+	}
+	width := len(fmt.Sprint(end))
+
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		text := strings.TrimRight(lines[i-1], "\r")
+		marker := " "
+		if i == line {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s %*d | %s\n", marker, width, i, text)
+		if i == line {
+			// Keep tabs before the column so the caret lines up
+			indent := []rune(text)
+			if column-1 < len(indent) {
+				indent = indent[:column-1]
+			}
+			for j, r := range indent {
+				if r != '\t' {
+					indent[j] = ' '
+				}
+			}
+			fmt.Fprintf(&b, "  %*s | %s^\n", width, "", string(indent))
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,175 @@
+package printer
+
+import (
+	"strings"
+
+	. "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/js_scanner"
+	"github.com/withastro/compiler/internal/transform"
+)
+
+type ManifestProp struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Optional    bool   `json:"optional"`
+	Description string `json:"description,omitempty"`
+}
+
+type ManifestSlot struct {
+	Name     string `json:"name"`
+	Dynamic  bool   `json:"dynamic"`
+	Fallback bool   `json:"fallback"`
+}
+
+// A ManifestPassedSlot is a named slot passed to a child component, which
+// tooling can compare with the slots declared in the child's manifest
+type ManifestPassedSlot struct {
+	// Component is the name of the child component
+	Component string `json:"component"`
+	// Specifier is the module the child component is imported from
+	Specifier string `json:"specifier"`
+	Name      string `json:"name"`
+	Dynamic   bool   `json:"dynamic"`
+}
+
+type ManifestComponent struct {
+	Name string `json:"name"`
+	// Specifier is the module the component is imported from. Custom elements have no specifier.
+	Specifier string `json:"specifier"`
+	// Export is the name of the imported export, like `default`
+	Export    string `json:"export"`
+	Directive string `json:"directive"`
+}
+
+type ManifestScript struct {
+	Type string `json:"type"`
+	Src  string `json:"src,omitempty"`
+	Code string `json:"code,omitempty"`
+}
+
+// A ComponentManifest describes the public interface of a component
+type ComponentManifest struct {
+	Name                 string               `json:"name"`
+	Props                []ManifestProp       `json:"props"`
+	Slots                []ManifestSlot       `json:"slots"`
+	PassedSlots          []ManifestPassedSlot `json:"passedSlots"`
+	HydratedComponents   []ManifestComponent  `json:"hydratedComponents"`
+	ClientOnlyComponents []ManifestComponent  `json:"clientOnlyComponents"`
+	Scripts              []ManifestScript     `json:"scripts"`
+	StyleCount           int                  `json:"styleCount"`
+}
+
+// PrintManifest describes the component in doc. It should be called after the document has been transformed.
+func PrintManifest(doc *Node, opts transform.TransformOptions) ComponentManifest {
+	manifest := ComponentManifest{
+		Name:                 strings.TrimPrefix(getComponentName(opts.Pathname), "$$"),
+		Props:                make([]ManifestProp, 0),
+		Slots:                make([]ManifestSlot, 0),
+		PassedSlots:          make([]ManifestPassedSlot, 0),
+		HydratedComponents:   make([]ManifestComponent, 0),
+		ClientOnlyComponents: make([]ManifestComponent, 0),
+		Scripts:              make([]ManifestScript, 0),
+		StyleCount:           len(doc.Styles),
+	}
+
+	frontmatter := getFrontmatterSource(doc)
+	if members, ok := js_scanner.GetPropsType(frontmatter); ok {
+		for _, member := range members {
+			manifest.Props = append(manifest.Props, ManifestProp{
+				Name:        member.Name,
+				Type:        member.Type,
+				Optional:    member.Optional,
+				Description: member.Description,
+			})
+		}
+	}
+
+	for _, slot := range transform.GetDeclaredSlots(doc) {
+		manifest.Slots = append(manifest.Slots, ManifestSlot{
+			Name:     slot.Name,
+			Dynamic:  slot.Dynamic,
+			Fallback: slot.HasFallback,
+		})
+	}
+
+	imports := getImports(frontmatter)
+	for _, slot := range transform.GetPassedSlots(doc) {
+		specifier, _ := getComponentImport(slot.Component, imports)
+		manifest.PassedSlots = append(manifest.PassedSlots, ManifestPassedSlot{
+			Component: slot.Component,
+			Specifier: specifier,
+			Name:      slot.Name,
+			Dynamic:   slot.Dynamic,
+		})
+	}
+	for _, n := range doc.HydratedComponents {
+		manifest.HydratedComponents = append(manifest.HydratedComponents, getManifestComponent(n, imports))
+	}
+	for _, n := range doc.ClientOnlyComponents {
+		manifest.ClientOnlyComponents = append(manifest.ClientOnlyComponents, getManifestComponent(n, imports))
+	}
+
+	for _, n := range doc.Scripts {
+		script := ManifestScript{}
+		if src := GetAttribute(n, "src"); src != nil {
+			script.Type = "external"
+			script.Src = src.Val
+		} else {
+			script.Type = "inline"
+			if n.FirstChild != nil {
+				script.Code = n.FirstChild.Data
+			}
+		}
+		manifest.Scripts = append(manifest.Scripts, script)
+	}
+
+	return manifest
+}
+
+func getFrontmatterSource(doc *Node) []byte {
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == FrontmatterNode && c.FirstChild != nil {
+			return []byte(c.FirstChild.Data)
+		}
+	}
+	return nil
+}
+
+func getImports(source []byte) []js_scanner.ImportStatement {
+	statements := make([]js_scanner.ImportStatement, 0)
+	pos, statement := js_scanner.NextImportStatement(source, 0)
+	for pos != -1 {
+		statements = append(statements, statement)
+		pos, statement = js_scanner.NextImportStatement(source, pos)
+	}
+	return statements
+}
+
+func getManifestComponent(n *Node, imports []js_scanner.ImportStatement) ManifestComponent {
+	component := ManifestComponent{Name: n.Data}
+	if attr := GetAttribute(n, "client:component-hydration"); attr != nil {
+		component.Directive = attr.Val
+	}
+	if n.CustomElement {
+		return component
+	}
+	component.Specifier, component.Export = getComponentImport(n.Data, imports)
+	return component
+}
+
+// getComponentImport returns the module and export a component is imported
+// from, or empty strings if it isn't imported
+func getComponentImport(name string, imports []js_scanner.ImportStatement) (specifier string, export string) {
+	for _, statement := range imports {
+		for _, imported := range statement.Imports {
+			if imported.LocalName == name {
+				return statement.Specifier, imported.ExportName
+			}
+			// Components like `<components.A>` use the `A` export of a namespace import
+			if prefix := imported.LocalName + "."; imported.ExportName == "*" && strings.HasPrefix(name, prefix) {
+				return statement.Specifier, strings.Split(name[len(prefix):], ".")[0]
+			}
+		}
+	}
+	return "", ""
+}
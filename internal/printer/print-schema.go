@@ -0,0 +1,208 @@
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	. "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/js_scanner"
+	"github.com/withastro/compiler/internal/transform"
+)
+
+const JSON_SCHEMA_DRAFT = "http://json-schema.org/draft-07/schema#"
+
+type JSONSchema struct {
+	Schema               string           `json:"$schema,omitempty"`
+	Title                string           `json:"title,omitempty"`
+	Description          string           `json:"description,omitempty"`
+	Type                 string           `json:"type,omitempty"`
+	Enum                 []interface{}    `json:"enum,omitempty"`
+	Items                *JSONSchema      `json:"items,omitempty"`
+	Properties           SchemaProperties `json:"properties,omitempty"`
+	Required             []string         `json:"required,omitempty"`
+	AdditionalProperties *JSONSchema      `json:"additionalProperties,omitempty"`
+	AnyOf                []*JSONSchema    `json:"anyOf,omitempty"`
+	AllOf                []*JSONSchema    `json:"allOf,omitempty"`
+	Default              interface{}      `json:"default,omitempty"`
+}
+
+type SchemaProperty struct {
+	Name   string
+	Schema *JSONSchema
+}
+
+// SchemaProperties are printed as a JSON object in declaration order
+type SchemaProperties []SchemaProperty
+
+func (properties SchemaProperties) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBufferString("{")
+	for i, property := range properties {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := marshalJSON(property.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := marshalJSON(property.Schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// marshalJSON is like json.Marshal, but does not escape HTML characters
+func marshalJSON(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// PrintPropsSchema prints a JSON Schema describing the `Props` interface or
+// type declared in the frontmatter. The second return value is false if
+// there is no `Props` declaration.
+func PrintPropsSchema(doc *Node, opts transform.TransformOptions) ([]byte, bool) {
+	members, ok := js_scanner.GetPropsType(getFrontmatterSource(doc))
+	if !ok {
+		return nil, false
+	}
+	schema := getObjectSchema(members)
+	schema.Schema = JSON_SCHEMA_DRAFT
+	schema.Title = strings.TrimPrefix(getComponentName(opts.Pathname), "$$")
+	output, err := marshalJSON(schema)
+	if err != nil {
+		return nil, false
+	}
+	return output, true
+}
+
+func getObjectSchema(members []js_scanner.TypeMember) *JSONSchema {
+	schema := &JSONSchema{Type: "object", Properties: make(SchemaProperties, 0)}
+	for _, member := range members {
+		t := js_scanner.ParseType([]byte(member.Type))
+		// Functions can't be passed as serialized props
+		if t.Kind == js_scanner.FunctionType {
+			continue
+		}
+		optional := member.Optional
+		if t.Kind == js_scanner.UnionType {
+			types := make([]*js_scanner.Type, 0, len(t.Types))
+			for _, member := range t.Types {
+				if member.Kind == js_scanner.UndefinedType {
+					optional = true
+				} else {
+					types = append(types, member)
+				}
+			}
+			t = &js_scanner.Type{Kind: js_scanner.UnionType, Types: types}
+		}
+		property := getTypeSchema(t)
+		property.Description, property.Default = getJSDocSchema(member.Description)
+		schema.Properties = append(schema.Properties, SchemaProperty{Name: member.Name, Schema: property})
+		if !optional {
+			schema.Required = append(schema.Required, member.Name)
+		}
+	}
+	return schema
+}
+
+func getTypeSchema(t *js_scanner.Type) *JSONSchema {
+	switch t.Kind {
+	case js_scanner.StringType:
+		return &JSONSchema{Type: "string"}
+	case js_scanner.NumberType:
+		return &JSONSchema{Type: "number"}
+	case js_scanner.BooleanType:
+		return &JSONSchema{Type: "boolean"}
+	case js_scanner.NullType:
+		return &JSONSchema{Type: "null"}
+	case js_scanner.LiteralType:
+		return &JSONSchema{Type: getLiteralType(t.Literal), Enum: []interface{}{t.Literal}}
+	case js_scanner.ArrayType:
+		return &JSONSchema{Type: "array", Items: getTypeSchema(t.Elem)}
+	case js_scanner.RecordType:
+		return &JSONSchema{Type: "object", AdditionalProperties: getTypeSchema(t.Elem)}
+	case js_scanner.ObjectType:
+		return getObjectSchema(t.Members)
+	case js_scanner.UnionType:
+		if len(t.Types) == 1 {
+			return getTypeSchema(t.Types[0])
+		}
+		// Unions of literals, like `'sm' | 'md' | 'lg'`, are enums
+		enum := make([]interface{}, 0, len(t.Types))
+		kind := ""
+		for _, member := range t.Types {
+			if member.Kind != js_scanner.LiteralType {
+				enum = nil
+				break
+			}
+			if kind == "" || kind == getLiteralType(member.Literal) {
+				kind = getLiteralType(member.Literal)
+			} else {
+				kind = "mixed"
+			}
+			enum = append(enum, member.Literal)
+		}
+		if enum != nil {
+			schema := &JSONSchema{Enum: enum}
+			if kind != "mixed" {
+				schema.Type = kind
+			}
+			return schema
+		}
+		schema := &JSONSchema{}
+		for _, member := range t.Types {
+			schema.AnyOf = append(schema.AnyOf, getTypeSchema(member))
+		}
+		return schema
+	case js_scanner.IntersectionType:
+		schema := &JSONSchema{}
+		for _, member := range t.Types {
+			schema.AllOf = append(schema.AllOf, getTypeSchema(member))
+		}
+		return schema
+	}
+	// Any, references, and syntax that isn't understood accept any value
+	return &JSONSchema{}
+}
+
+func getLiteralType(literal interface{}) string {
+	switch literal.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return ""
+}
+
+// getJSDocSchema splits a JSDoc comment into its description and `@default` value
+func getJSDocSchema(comment string) (string, interface{}) {
+	lines := make([]string, 0)
+	var value interface{}
+	for _, line := range strings.Split(comment, "\n") {
+		if !strings.HasPrefix(line, "@default") {
+			lines = append(lines, line)
+			continue
+		}
+		raw := strings.TrimSpace(strings.TrimPrefix(line, "@default"))
+		if str, ok := js_scanner.GetStaticString([]byte(raw)); ok {
+			value = str
+		} else if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			value = raw
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), value
+}
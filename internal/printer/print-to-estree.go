@@ -0,0 +1,1773 @@
+package printer
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/js"
+	. "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+// placeholderPrefix names the identifiers that stand in for the elements of an
+// expression while its JS is parsed
+const placeholderPrefix = "$$astroChildren"
+
+// expressionSource is where an expression is in the source, which places the
+// nodes of its tree
+type expressionSource struct {
+	p    *printer
+	text string
+	// start and end are the offsets of the braces of the expression
+	start int
+	end   int
+}
+
+// expressionESTree parses the JS of an expression into an ESTree tree, with
+// the element children of the expression replaced by AstroChildren nodes.
+// Those hold the indices of the elements in children, so a run of elements
+// with only whitespace between them is a single node.
+//
+// With a source, the nodes have the `loc` and `range` of their JS in it, and
+// AstroChildren nodes those of their elements. The parser doesn't keep
+// parentheses or the braces of an arrow function that only returns, like
+// `() => { return a }`, which has an expression body in the tree. An
+// expression with no JS, like `{}` or `{/* comment */}`, has no tree.
+func expressionESTree(children []*Node, source *expressionSource) ([]byte, error) {
+	code := []byte{'('}
+	placeholders := make(map[string][]int)
+	var segments []estreeSegment
+	positions := source != nil
+	group := ""
+	groupSegment := 0
+	for i, child := range children {
+		if len(child.Loc) == 0 {
+			positions = false
+		}
+		if child.Type == TextNode {
+			if strings.TrimSpace(child.Data) != "" {
+				group = ""
+			}
+			if positions {
+				segments = append(segments, newTextSegment(len(code), source.text, child.Loc[0].Start, child.Data))
+			}
+			code = append(code, child.Data...)
+			continue
+		}
+		if group != "" {
+			placeholders[group] = append(placeholders[group], i)
+			if positions {
+				segments[groupSegment].srcEnd = source.elementEnd(children, i)
+			}
+			continue
+		}
+		group = placeholderPrefix + strconv.Itoa(i)
+		placeholders[group] = []int{i}
+		if positions {
+			// The element starts where the text before it ends
+			start := source.start + 1
+			if len(segments) > 0 {
+				start = segments[len(segments)-1].srcEnd
+			}
+			groupSegment = len(segments)
+			segments = append(segments, estreeSegment{
+				gen:         len(code) + 1,
+				src:         start,
+				srcEnd:      source.elementEnd(children, i),
+				placeholder: true,
+			})
+		}
+		// Keep the placeholder apart from the JS around it
+		code = append(code, ' ')
+		code = append(code, group...)
+		code = append(code, ' ')
+	}
+	if len(bytes.TrimSpace(code[1:])) == 0 {
+		return nil, nil
+	}
+	// The line terminator ends a line comment at the end of the expression
+	code = append(code, '\n', ')')
+
+	// Leave room for the NULL the input appends, so the lexer reads the same
+	// buffer as the parser
+	buf := make([]byte, len(code), len(code)+1)
+	copy(buf, code)
+	ast, err := js.Parse(parse.NewInputBytes(buf), js.Options{})
+	if err != nil {
+		if program, _ := js.Parse(parse.NewInputString(string(code[1:len(code)-2])), js.Options{}); program != nil && len(program.List) == 0 {
+			return nil, nil
+		}
+		var parseErr *parse.Error
+		if errors.As(err, &parseErr) {
+			return nil, errors.New(parseErr.Message)
+		}
+		return nil, err
+	}
+	if len(ast.List) == 1 {
+		if stmt, ok := ast.List[0].(*js.ExprStmt); ok {
+			if group, ok := stmt.Value.(*js.GroupExpr); ok {
+				p := estreePrinter{placeholders: placeholders}
+				if positions {
+					p.source = source
+					p.segments = segments
+					p.tokenize(buf, ast)
+				}
+				return p.appendExpr(nil, group), nil
+			}
+		}
+	}
+	return nil, errors.New("expected a single expression")
+}
+
+// elementEnd returns the end of the element at i in children, which is where
+// the text after it starts
+func (s *expressionSource) elementEnd(children []*Node, i int) int {
+	if i+1 < len(children) {
+		if next := children[i+1]; next.Type == TextNode {
+			return next.Loc[0].Start
+		}
+	}
+	return s.end
+}
+
+// estreeSegment is a text child or placeholder in the parsed code
+type estreeSegment struct {
+	// gen is the offset of the segment in the code, and src its offset in the
+	// source
+	gen    int
+	src    int
+	srcEnd int
+	// data is the text of a text segment
+	data string
+	// normalized is true if the parser turned CRLF line terminators of the
+	// source into LF in data
+	normalized  bool
+	placeholder bool
+}
+
+func newTextSegment(gen int, source string, start int, data string) estreeSegment {
+	s := estreeSegment{gen: gen, src: start, data: data, normalized: true}
+	s.srcEnd = s.sourceOffset(source, len(data))
+	s.normalized = s.srcEnd-start != len(data)
+	return s
+}
+
+// sourceOffset returns the offset in the source of the byte at i in the data
+// of a text segment
+func (s estreeSegment) sourceOffset(source string, i int) int {
+	if !s.normalized {
+		return s.src + i
+	}
+	offset := s.src
+	for j := 0; j < i && j < len(s.data) && offset < len(source); j++ {
+		if source[offset] == '\r' && s.data[j] == '\n' && offset+1 < len(source) && source[offset+1] == '\n' {
+			offset++
+		}
+		offset++
+	}
+	return offset
+}
+
+type estreeToken struct {
+	tt    js.TokenType
+	data  []byte
+	start int
+	end   int
+}
+
+// regExps collects the regular expressions of a tree, which the lexer can't
+// tell from divisions
+type regExps map[*byte]bool
+
+func (r regExps) Enter(n js.INode) js.IVisitor {
+	if literal, ok := n.(*js.LiteralExpr); ok && literal.TokenType == js.RegExpToken && len(literal.Data) > 0 {
+		r[&literal.Data[0]] = true
+	}
+	return r
+}
+
+func (r regExps) Exit(n js.INode) {}
+
+type estreePrinter struct {
+	placeholders map[string][]int
+	source       *expressionSource
+	segments     []estreeSegment
+	// tokens are the tokens of the code, without whitespace and comments. The
+	// printer reads them along with the nodes to place them, so they're only
+	// set with a source.
+	tokens []estreeToken
+	next   int
+	// literals finds the tokens of literals by their data, which is in the
+	// buffer the tokens are read from
+	literals map[*byte]int
+}
+
+// tokenize reads the tokens of code, which ast was parsed from
+func (p *estreePrinter) tokenize(code []byte, ast *js.AST) {
+	regexps := regExps{}
+	js.Walk(regexps, ast)
+	p.literals = make(map[*byte]int)
+	l := js.NewLexer(parse.NewInputBytes(code))
+	offset := 0
+	for {
+		tt, data := l.Next()
+		if (tt == js.DivToken || tt == js.DivEqToken) && regexps[&data[0]] {
+			tt, data = l.RegExp()
+		}
+		if tt == js.ErrorToken {
+			return
+		}
+		start := offset
+		offset += len(data)
+		switch tt {
+		case js.WhitespaceToken, js.LineTerminatorToken, js.CommentToken, js.CommentLineTerminatorToken:
+			continue
+		}
+		p.literals[&data[0]] = len(p.tokens)
+		p.tokens = append(p.tokens, estreeToken{tt: tt, data: data, start: start, end: offset})
+	}
+}
+
+// skip reads the next token if it's of type tt
+func (p *estreePrinter) skip(tt js.TokenType) bool {
+	if p.next < len(p.tokens) && p.tokens[p.next].tt == tt {
+		p.next++
+		return true
+	}
+	return false
+}
+
+// advance reads the next token, whatever it is, returning its index
+func (p *estreePrinter) advance() int {
+	if p.next < len(p.tokens) {
+		p.next++
+	}
+	return p.next - 1
+}
+
+// literal reads the token of a literal, returning its index
+func (p *estreePrinter) literal(data []byte) int {
+	if len(data) > 0 {
+		if i, ok := p.literals[&data[0]]; ok {
+			p.next = i + 1
+			return i
+		}
+	}
+	return p.advance()
+}
+
+// name reads the next token with the name of a variable, returning its index.
+// Variables have the data of their first use, so it doesn't find the token.
+func (p *estreePrinter) name(data []byte) int {
+	for i := p.next; i < len(p.tokens); i++ {
+		if bytes.Equal(p.tokens[i].data, data) {
+			p.next = i + 1
+			return i
+		}
+	}
+	return p.advance()
+}
+
+// closing returns the index of the bracket that closes the one at open
+func (p *estreePrinter) closing(open int) int {
+	depth := 0
+	for i := open; i < len(p.tokens); i++ {
+		switch p.tokens[i].tt {
+		case js.OpenBraceToken, js.OpenBracketToken, js.OpenParenToken, js.TemplateStartToken:
+			depth++
+		case js.CloseBraceToken, js.CloseBracketToken, js.CloseParenToken, js.TemplateEndToken:
+			depth--
+		}
+		if depth == 0 {
+			return i
+		}
+	}
+	return len(p.tokens) - 1
+}
+
+// appendPosition appends the position of a node, from the token at first to
+// the last token read
+func (p *estreePrinter) appendPosition(b []byte, first int) []byte {
+	last := p.next - 1
+	if first < 0 || first > last || last >= len(p.tokens) {
+		return b
+	}
+	return p.appendRange(b, p.tokens[first].start, p.tokens[last].end)
+}
+
+// appendRange appends the loc and range in the source of the code from start
+// to end
+func (p *estreePrinter) appendRange(b []byte, start int, end int) []byte {
+	start = p.sourceOffset(start, false)
+	end = p.sourceOffset(end, true)
+	b = append(b, `,"loc":{"start":`...)
+	b = p.appendPoint(b, start)
+	b = append(b, `,"end":`...)
+	b = p.appendPoint(b, end)
+	b = append(b, `},"range":[`...)
+	b = strconv.AppendInt(b, int64(start), 10)
+	b = append(b, ',')
+	b = strconv.AppendInt(b, int64(end), 10)
+	return append(b, ']')
+}
+
+func (p *estreePrinter) appendPoint(b []byte, offset int) []byte {
+	point := locToPoint(p.source.p, loc.Loc{Start: offset})
+	b = append(b, `{"line":`...)
+	b = strconv.AppendInt(b, int64(point.Line), 10)
+	// ESTree columns are 0-based
+	b = append(b, `,"column":`...)
+	b = strconv.AppendInt(b, int64(point.Column-1), 10)
+	return append(b, '}')
+}
+
+// sourceOffset returns the offset in the source of an offset in the code. An
+// end is in the segment of the byte before it, so a placeholder ends with its
+// last element.
+func (p *estreePrinter) sourceOffset(offset int, end bool) int {
+	at := offset
+	if end {
+		at--
+	}
+	i := sort.Search(len(p.segments), func(i int) bool { return p.segments[i].gen > at }) - 1
+	if i < 0 {
+		i = 0
+	}
+	s := p.segments[i]
+	if s.placeholder {
+		// Leave out the whitespace around the elements
+		elements := p.source.text[s.src:s.srcEnd]
+		if end {
+			return s.src + len(strings.TrimRightFunc(elements, unicode.IsSpace))
+		}
+		return s.srcEnd - len(strings.TrimLeftFunc(elements, unicode.IsSpace))
+	}
+	return s.sourceOffset(p.source.text, offset-s.gen)
+}
+
+func appendESTreeType(b []byte, typ string) []byte {
+	b = append(b, `{"type":"`...)
+	b = append(b, typ...)
+	return append(b, '"')
+}
+
+func appendESTreeKey(b []byte, key string) []byte {
+	b = append(b, ',', '"')
+	b = append(b, key...)
+	return append(b, '"', ':')
+}
+
+func appendESTreeBool(b []byte, key string, value bool) []byte {
+	b = appendESTreeKey(b, key)
+	return strconv.AppendBool(b, value)
+}
+
+// appendIdentifier appends an identifier read from the token at first
+func (p *estreePrinter) appendIdentifier(b []byte, name []byte, first int) []byte {
+	b = appendESTreeType(b, "Identifier")
+	b = appendESTreeKey(b, "name")
+	b = appendJSONString(b, string(name))
+	b = p.appendPosition(b, first)
+	return append(b, '}')
+}
+
+func (p *estreePrinter) appendExprs(b []byte, key string, list []js.IExpr) []byte {
+	b = appendESTreeKey(b, key)
+	b = append(b, '[')
+	for i, expr := range list {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = p.appendExpr(b, expr)
+		p.skip(js.CommaToken)
+	}
+	return append(b, ']')
+}
+
+// appendOptionalExpr appends null for a missing expression
+func (p *estreePrinter) appendOptionalExpr(b []byte, key string, expr js.IExpr) []byte {
+	b = appendESTreeKey(b, key)
+	if expr == nil {
+		return append(b, "null"...)
+	}
+	return p.appendExpr(b, expr)
+}
+
+func (p *estreePrinter) appendExpr(b []byte, expr js.IExpr) []byte {
+	start := p.next
+	switch n := expr.(type) {
+	case *js.Var:
+		first := p.name(n.Data)
+		if indices, ok := p.placeholders[string(n.Data)]; ok {
+			b = appendESTreeType(b, "AstroChildren")
+			b = appendESTreeKey(b, "children")
+			b = append(b, '[')
+			for i, index := range indices {
+				if i > 0 {
+					b = append(b, ',')
+				}
+				b = strconv.AppendInt(b, int64(index), 10)
+			}
+			b = append(b, ']')
+			b = p.appendPosition(b, first)
+			return append(b, '}')
+		}
+		return p.appendIdentifier(b, n.Data, first)
+	case *js.LiteralExpr:
+		return p.appendLiteral(b, *n)
+	case *js.GroupExpr:
+		p.skip(js.OpenParenToken)
+		b = p.appendExpr(b, n.X)
+		p.skip(js.CloseParenToken)
+		return b
+	case *js.ArrayExpr:
+		p.skip(js.OpenBracketToken)
+		b = appendESTreeType(b, "ArrayExpression")
+		b = appendESTreeKey(b, "elements")
+		b = append(b, '[')
+		for i, element := range n.List {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if element.Value == nil {
+				b = append(b, "null"...)
+			} else if element.Spread {
+				b = p.appendSpread(b, "SpreadElement", element.Value)
+			} else {
+				b = p.appendExpr(b, element.Value)
+			}
+			// A hole is only the comma
+			p.skip(js.CommaToken)
+		}
+		b = append(b, ']')
+		p.skip(js.CloseBracketToken)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.ObjectExpr:
+		p.skip(js.OpenBraceToken)
+		b = appendESTreeType(b, "ObjectExpression")
+		b = appendESTreeKey(b, "properties")
+		b = append(b, '[')
+		for i, property := range n.List {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = p.appendProperty(b, property)
+			p.skip(js.CommaToken)
+		}
+		b = append(b, ']')
+		p.skip(js.CloseBraceToken)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.TemplateExpr:
+		if n.Tag == nil {
+			return p.appendTemplate(b, *n)
+		}
+		b = appendESTreeType(b, "TaggedTemplateExpression")
+		b = appendESTreeKey(b, "tag")
+		b = p.appendExpr(b, n.Tag)
+		b = appendESTreeKey(b, "quasi")
+		b = p.appendTemplate(b, *n)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.DotExpr, *js.IndexExpr, *js.CallExpr, *js.OptChainExpr:
+		if !isOptionalChain(expr) {
+			return p.appendChainElement(b, expr)
+		}
+		b = appendESTreeType(b, "ChainExpression")
+		b = appendESTreeKey(b, "expression")
+		b = p.appendChainElement(b, expr)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.NewTargetExpr:
+		return p.appendMetaProperty(b, "new", "target")
+	case *js.ImportMetaExpr:
+		return p.appendMetaProperty(b, "import", "meta")
+	case *js.NewExpr:
+		p.skip(js.NewToken)
+		b = appendESTreeType(b, "NewExpression")
+		b = appendESTreeKey(b, "callee")
+		b = p.appendExpr(b, n.X)
+		if n.Args != nil {
+			b = p.appendArgs(b, *n.Args)
+		} else {
+			b = append(b, `,"arguments":[]`...)
+		}
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.UnaryExpr:
+		return p.appendUnary(b, *n)
+	case *js.BinaryExpr:
+		return p.appendBinary(b, *n)
+	case *js.CondExpr:
+		b = appendESTreeType(b, "ConditionalExpression")
+		b = appendESTreeKey(b, "test")
+		b = p.appendExpr(b, n.Cond)
+		p.skip(js.QuestionToken)
+		b = appendESTreeKey(b, "consequent")
+		b = p.appendExpr(b, n.X)
+		p.skip(js.ColonToken)
+		b = appendESTreeKey(b, "alternate")
+		b = p.appendExpr(b, n.Y)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.YieldExpr:
+		p.skip(js.YieldToken)
+		p.skip(js.MulToken)
+		b = appendESTreeType(b, "YieldExpression")
+		b = p.appendOptionalExpr(b, "argument", n.X)
+		b = appendESTreeBool(b, "delegate", n.Generator)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.ArrowFunc:
+		if n.Async {
+			p.skip(js.AsyncToken)
+		}
+		b = appendESTreeType(b, "ArrowFunctionExpression")
+		b = appendESTreeKey(b, "id")
+		b = append(b, "null"...)
+		b = p.appendParams(b, n.Params)
+		p.skip(js.ArrowToken)
+		b = appendESTreeKey(b, "body")
+		isExpression := false
+		if len(n.Body.List) == 1 {
+			if stmt, ok := n.Body.List[0].(*js.ReturnStmt); ok && stmt.Value != nil {
+				isExpression = true
+				braces := p.skip(js.OpenBraceToken)
+				if braces {
+					p.skip(js.ReturnToken)
+				}
+				b = p.appendExpr(b, stmt.Value)
+				if braces {
+					p.skip(js.SemicolonToken)
+					p.skip(js.CloseBraceToken)
+				}
+			}
+		}
+		if !isExpression {
+			b = p.appendBlock(b, n.Body)
+		}
+		b = appendESTreeBool(b, "expression", isExpression)
+		b = appendESTreeBool(b, "generator", false)
+		b = appendESTreeBool(b, "async", n.Async)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.CommaExpr:
+		b = appendESTreeType(b, "SequenceExpression")
+		b = p.appendExprs(b, "expressions", n.List)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.FuncDecl:
+		return p.appendFunction(b, "FunctionExpression", *n)
+	case *js.ClassDecl:
+		return p.appendClass(b, "ClassExpression", *n)
+	case *js.VarDecl:
+		return p.appendVarDecl(b, *n, false)
+	}
+	return append(b, "null"...)
+}
+
+// appendMetaProperty appends new.target or import.meta
+func (p *estreePrinter) appendMetaProperty(b []byte, meta string, property string) []byte {
+	start := p.advance()
+	b = appendESTreeType(b, "MetaProperty")
+	b = appendESTreeKey(b, "meta")
+	b = p.appendIdentifier(b, []byte(meta), start)
+	p.skip(js.DotToken)
+	b = appendESTreeKey(b, "property")
+	b = p.appendIdentifier(b, []byte(property), p.advance())
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+func (p *estreePrinter) appendSpread(b []byte, typ string, argument js.INode) []byte {
+	start := p.next
+	p.skip(js.EllipsisToken)
+	b = appendESTreeType(b, typ)
+	b = appendESTreeKey(b, "argument")
+	if binding, ok := argument.(js.IBinding); ok {
+		b = p.appendBinding(b, binding)
+	} else {
+		b = p.appendExpr(b, argument.(js.IExpr))
+	}
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+func (p *estreePrinter) appendArgs(b []byte, args js.Args) []byte {
+	p.skip(js.OpenParenToken)
+	b = appendESTreeKey(b, "arguments")
+	b = append(b, '[')
+	for i, arg := range args.List {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if arg.Rest {
+			b = p.appendSpread(b, "SpreadElement", arg.Value)
+		} else {
+			b = p.appendExpr(b, arg.Value)
+		}
+		p.skip(js.CommaToken)
+	}
+	p.skip(js.CloseParenToken)
+	return append(b, ']')
+}
+
+// appendLiteral appends this, super and the literal values
+func (p *estreePrinter) appendLiteral(b []byte, n js.LiteralExpr) []byte {
+	switch n.TokenType {
+	case js.ThisToken:
+		b = appendESTreeType(b, "ThisExpression")
+		b = p.appendPosition(b, p.literal(n.Data))
+		return append(b, '}')
+	case js.SuperToken:
+		b = appendESTreeType(b, "Super")
+		b = p.appendPosition(b, p.literal(n.Data))
+		return append(b, '}')
+	case js.TrueToken, js.FalseToken, js.NullToken:
+	default:
+		if n.TokenType == js.PrivateIdentifierToken || js.IsIdentifierName(n.TokenType) {
+			return p.appendName(b, n)
+		}
+	}
+
+	first := p.literal(n.Data)
+	raw := string(n.Data)
+	b = appendESTreeType(b, "Literal")
+	b = appendESTreeKey(b, "value")
+	switch n.TokenType {
+	case js.TrueToken, js.FalseToken, js.NullToken:
+		b = append(b, raw...)
+	case js.StringToken:
+		b = appendJSONString(b, unescapeJS(raw[1:len(raw)-1]))
+	case js.RegExpToken:
+		b = append(b, "null"...)
+		slash := strings.LastIndexByte(raw, '/')
+		b = appendESTreeKey(b, "regex")
+		b = append(b, `{"pattern":`...)
+		b = appendJSONString(b, raw[1:slash])
+		b = append(b, `,"flags":`...)
+		b = appendJSONString(b, raw[slash+1:])
+		b = append(b, '}')
+	case js.BigIntToken:
+		b = append(b, "null"...)
+		b = appendESTreeKey(b, "bigint")
+		b = appendJSONString(b, strings.TrimSuffix(raw, "n"))
+	default:
+		b = appendJSONNumber(b, parseJSNumber(n.TokenType, raw))
+	}
+	b = appendESTreeKey(b, "raw")
+	b = appendJSONString(b, raw)
+	b = p.appendPosition(b, first)
+	return append(b, '}')
+}
+
+// appendName appends the name of a property, which may be a reserved word or
+// a private name
+func (p *estreePrinter) appendName(b []byte, n js.LiteralExpr) []byte {
+	if n.TokenType == js.PrivateIdentifierToken {
+		first := p.literal(n.Data)
+		b = appendESTreeType(b, "PrivateIdentifier")
+		b = appendESTreeKey(b, "name")
+		b = appendJSONString(b, strings.TrimPrefix(string(n.Data), "#"))
+		b = p.appendPosition(b, first)
+		return append(b, '}')
+	}
+	if js.IsIdentifierName(n.TokenType) {
+		return p.appendIdentifier(b, n.Data, p.literal(n.Data))
+	}
+	return p.appendLiteral(b, n)
+}
+
+func parseJSNumber(tt js.TokenType, raw string) float64 {
+	if tt == js.DecimalToken {
+		value, _ := strconv.ParseFloat(raw, 64)
+		return value
+	}
+	value, ok := new(big.Int).SetString(raw, 0)
+	if !ok {
+		return math.NaN()
+	}
+	f, _ := new(big.Float).SetInt(value).Float64()
+	return f
+}
+
+// appendJSONNumber appends null for the numbers JSON can't represent
+func appendJSONNumber(b []byte, value float64) []byte {
+	if math.IsInf(value, 0) || math.IsNaN(value) {
+		return append(b, "null"...)
+	}
+	return strconv.AppendFloat(b, value, 'g', -1, 64)
+}
+
+// unescapeJS returns the value of the escape sequences in the body of a
+// string or template literal
+func unescapeJS(raw string) string {
+	if !strings.Contains(raw, `\`) && !strings.Contains(raw, "\r") {
+		return raw
+	}
+	b := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c == '\r' {
+			// Template literals normalize line terminators
+			if i+1 < len(raw) && raw[i+1] == '\n' {
+				i++
+			}
+			b = append(b, '\n')
+			continue
+		}
+		if c != '\\' || i+1 == len(raw) {
+			b = append(b, c)
+			continue
+		}
+		i++
+		switch c = raw[i]; c {
+		case 'n':
+			b = append(b, '\n')
+		case 't':
+			b = append(b, '\t')
+		case 'r':
+			b = append(b, '\r')
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'v':
+			b = append(b, '\v')
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// Legacy octal escapes have up to three digits and stay below \400.
+			// `\0` is a NUL character when no other octal digit follows.
+			end := i + 1
+			for end < len(raw) && end-i < 3 && raw[end] >= '0' && raw[end] <= '7' && (c <= '3' || end-i < 2) {
+				end++
+			}
+			code, _ := strconv.ParseUint(raw[i:end], 8, 8)
+			b = appendRune(b, rune(code))
+			i = end - 1
+		case '\r':
+			// A line continuation
+			if i+1 < len(raw) && raw[i+1] == '\n' {
+				i++
+			}
+		case '\n':
+		case 'x':
+			if i+2 < len(raw) {
+				if code, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+					b = appendRune(b, rune(code))
+					i += 2
+					continue
+				}
+			}
+			b = append(b, c)
+		case 'u':
+			r, size := unescapeCodePoint(raw[i+1:])
+			if size == 0 {
+				b = append(b, c)
+				continue
+			}
+			i += size
+			if utf16.IsSurrogate(r) && strings.HasPrefix(raw[i+1:], `\u`) {
+				if low, size := unescapeCodePoint(raw[i+3:]); size > 0 {
+					if pair := utf16.DecodeRune(r, low); pair != utf8.RuneError {
+						r = pair
+						i += size + 2
+					}
+				}
+			}
+			b = appendRune(b, r)
+		default:
+			// A line continuation with a line or paragraph separator
+			if c == 0xe2 && (strings.HasPrefix(raw[i:], "\u2028") || strings.HasPrefix(raw[i:], "\u2029")) {
+				i += 2
+				continue
+			}
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var buf [utf8.UTFMax]byte
+	n := utf8.EncodeRune(buf[:], r)
+	return append(b, buf[:n]...)
+}
+
+// unescapeCodePoint reads the XXXX or {X...} of a \u escape, returning the
+// number of bytes read
+func unescapeCodePoint(s string) (rune, int) {
+	if strings.HasPrefix(s, "{") {
+		end := strings.IndexByte(s, '}')
+		if end < 2 {
+			return 0, 0
+		}
+		code, err := strconv.ParseUint(s[1:end], 16, 32)
+		if err != nil || code > utf8.MaxRune {
+			return 0, 0
+		}
+		return rune(code), end + 1
+	}
+	if len(s) < 4 {
+		return 0, 0
+	}
+	code, err := strconv.ParseUint(s[:4], 16, 16)
+	if err != nil {
+		return 0, 0
+	}
+	return rune(code), 4
+}
+
+func (p *estreePrinter) appendTemplate(b []byte, n js.TemplateExpr) []byte {
+	first := n.Tail
+	if len(n.List) > 0 {
+		first = n.List[0].Value
+	}
+	start := p.literal(first)
+	b = appendESTreeType(b, "TemplateLiteral")
+	b = appendESTreeKey(b, "quasis")
+	b = append(b, '[')
+	for _, part := range n.List {
+		// Parts are delimited by ` or } and ${
+		b = p.appendTemplateElement(b, part.Value, 2, false)
+		b = append(b, ',')
+	}
+	b = p.appendTemplateElement(b, n.Tail, 1, true)
+	b = append(b, ']')
+	b = appendESTreeKey(b, "expressions")
+	b = append(b, '[')
+	for i, part := range n.List {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		p.literal(part.Value)
+		b = p.appendExpr(b, part.Expr)
+	}
+	p.literal(n.Tail)
+	b = append(b, ']')
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+// appendTemplateElement appends the text of a template token, which has one
+// delimiter before it and end bytes of delimiter after it
+func (p *estreePrinter) appendTemplateElement(b []byte, token []byte, end int, tail bool) []byte {
+	raw := string(token[1 : len(token)-end])
+	b = appendESTreeType(b, "TemplateElement")
+	b = append(b, `,"value":{"raw":`...)
+	b = appendJSONString(b, raw)
+	b = append(b, `,"cooked":`...)
+	b = appendJSONString(b, unescapeJS(raw))
+	b = append(b, '}')
+	b = appendESTreeBool(b, "tail", tail)
+	if i, ok := p.literals[&token[0]]; ok {
+		b = p.appendRange(b, p.tokens[i].start+1, p.tokens[i].end-end)
+	}
+	return append(b, '}')
+}
+
+// isOptionalChain reports whether the member accesses and calls of expr
+// include an optional one, which makes expr a ChainExpression
+func isOptionalChain(expr js.IExpr) bool {
+	switch n := expr.(type) {
+	case *js.OptChainExpr:
+		return true
+	case *js.DotExpr:
+		return isOptionalChain(n.X)
+	case *js.IndexExpr:
+		return isOptionalChain(n.X)
+	case *js.CallExpr:
+		return isOptionalChain(n.X)
+	}
+	return false
+}
+
+// appendChainElement appends a member access or call, without wrapping a chain
+// in its ChainExpression
+func (p *estreePrinter) appendChainElement(b []byte, expr js.IExpr) []byte {
+	switch n := expr.(type) {
+	case *js.DotExpr:
+		return p.appendMember(b, n.X, &n.Y, false, false)
+	case *js.IndexExpr:
+		return p.appendMember(b, n.X, n.Y, true, false)
+	case *js.CallExpr:
+		return p.appendCall(b, n.X, n.Args, false)
+	case *js.OptChainExpr:
+		switch y := n.Y.(type) {
+		case *js.CallExpr:
+			return p.appendCall(b, n.X, y.Args, true)
+		case *js.IndexExpr:
+			return p.appendMember(b, n.X, y.Y, true, true)
+		case *js.LiteralExpr:
+			return p.appendMember(b, n.X, y, false, true)
+		case *js.TemplateExpr:
+			start := p.next
+			b = appendESTreeType(b, "TaggedTemplateExpression")
+			b = appendESTreeKey(b, "tag")
+			b = p.appendChainObject(b, n.X)
+			p.skip(js.OptChainToken)
+			b = appendESTreeKey(b, "quasi")
+			b = p.appendTemplate(b, *y)
+			b = p.appendPosition(b, start)
+			return append(b, '}')
+		}
+	}
+	return p.appendExpr(b, expr)
+}
+
+func (p *estreePrinter) appendChainObject(b []byte, expr js.IExpr) []byte {
+	if isOptionalChain(expr) {
+		return p.appendChainElement(b, expr)
+	}
+	return p.appendExpr(b, expr)
+}
+
+func (p *estreePrinter) appendMember(b []byte, object js.IExpr, property js.IExpr, computed bool, optional bool) []byte {
+	start := p.next
+	b = appendESTreeType(b, "MemberExpression")
+	b = appendESTreeKey(b, "object")
+	b = p.appendChainObject(b, object)
+	if optional {
+		p.skip(js.OptChainToken)
+	}
+	b = appendESTreeKey(b, "property")
+	if literal, ok := property.(*js.LiteralExpr); ok && !computed {
+		p.skip(js.DotToken)
+		b = p.appendName(b, *literal)
+	} else {
+		p.skip(js.OpenBracketToken)
+		b = p.appendExpr(b, property)
+		p.skip(js.CloseBracketToken)
+	}
+	b = appendESTreeBool(b, "computed", computed)
+	b = appendESTreeBool(b, "optional", optional)
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+func (p *estreePrinter) appendCall(b []byte, callee js.IExpr, args js.Args, optional bool) []byte {
+	start := p.next
+	if literal, ok := callee.(*js.LiteralExpr); ok && literal.TokenType == js.ImportToken && len(args.List) > 0 {
+		p.literal(literal.Data)
+		b = appendESTreeType(b, "ImportExpression")
+		b = appendESTreeKey(b, "source")
+		open := p.next
+		p.skip(js.OpenParenToken)
+		b = p.appendExpr(b, args.List[0].Value)
+		if open < len(p.tokens) {
+			p.next = p.closing(open) + 1
+		}
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	}
+	b = appendESTreeType(b, "CallExpression")
+	b = appendESTreeKey(b, "callee")
+	b = p.appendChainObject(b, callee)
+	if optional {
+		p.skip(js.OptChainToken)
+	}
+	b = p.appendArgs(b, args)
+	b = appendESTreeBool(b, "optional", optional)
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+func (p *estreePrinter) appendUnary(b []byte, n js.UnaryExpr) []byte {
+	start := p.next
+	switch n.Op {
+	case js.AwaitToken:
+		p.advance()
+		b = appendESTreeType(b, "AwaitExpression")
+		b = appendESTreeKey(b, "argument")
+		b = p.appendExpr(b, n.X)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case js.PreIncrToken, js.PreDecrToken, js.PostIncrToken, js.PostDecrToken:
+		prefix := n.Op == js.PreIncrToken || n.Op == js.PreDecrToken
+		if prefix {
+			p.advance()
+		}
+		b = appendESTreeType(b, "UpdateExpression")
+		b = appendESTreeKey(b, "operator")
+		b = appendJSONString(b, n.Op.String())
+		b = appendESTreeBool(b, "prefix", prefix)
+		b = appendESTreeKey(b, "argument")
+		b = p.appendExpr(b, n.X)
+		if !prefix {
+			p.advance()
+		}
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	}
+	p.advance()
+	b = appendESTreeType(b, "UnaryExpression")
+	b = appendESTreeKey(b, "operator")
+	b = appendJSONString(b, n.Op.String())
+	b = appendESTreeBool(b, "prefix", true)
+	b = appendESTreeKey(b, "argument")
+	b = p.appendExpr(b, n.X)
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+func isAssignment(op js.TokenType) bool {
+	switch op {
+	case js.EqToken, js.AddEqToken, js.SubEqToken, js.MulEqToken, js.DivEqToken, js.ModEqToken, js.ExpEqToken,
+		js.LtLtEqToken, js.GtGtEqToken, js.GtGtGtEqToken, js.BitAndEqToken, js.BitOrEqToken, js.BitXorEqToken,
+		js.AndEqToken, js.OrEqToken, js.NullishEqToken:
+		return true
+	}
+	return false
+}
+
+func (p *estreePrinter) appendBinary(b []byte, n js.BinaryExpr) []byte {
+	start := p.next
+	switch {
+	case isAssignment(n.Op):
+		b = appendESTreeType(b, "AssignmentExpression")
+		b = appendESTreeKey(b, "operator")
+		b = appendJSONString(b, n.Op.String())
+		b = appendESTreeKey(b, "left")
+		b = p.appendTarget(b, n.X)
+	case n.Op == js.AndToken || n.Op == js.OrToken || n.Op == js.NullishToken:
+		b = appendESTreeType(b, "LogicalExpression")
+		b = appendESTreeKey(b, "operator")
+		b = appendJSONString(b, n.Op.String())
+		b = appendESTreeKey(b, "left")
+		b = p.appendExpr(b, n.X)
+	default:
+		b = appendESTreeType(b, "BinaryExpression")
+		b = appendESTreeKey(b, "operator")
+		b = appendJSONString(b, n.Op.String())
+		b = appendESTreeKey(b, "left")
+		b = p.appendExpr(b, n.X)
+	}
+	// The operator
+	p.advance()
+	b = appendESTreeKey(b, "right")
+	b = p.appendExpr(b, n.Y)
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+// appendTarget appends the left-hand side of an assignment, which the parser
+// keeps as an expression, turning array and object literals into patterns
+func (p *estreePrinter) appendTarget(b []byte, expr js.IExpr) []byte {
+	start := p.next
+	switch n := expr.(type) {
+	case *js.ArrayExpr:
+		p.skip(js.OpenBracketToken)
+		b = appendESTreeType(b, "ArrayPattern")
+		b = appendESTreeKey(b, "elements")
+		b = append(b, '[')
+		for i, element := range n.List {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			if element.Value == nil {
+				b = append(b, "null"...)
+			} else if element.Spread {
+				rest := p.next
+				p.skip(js.EllipsisToken)
+				b = appendESTreeType(b, "RestElement")
+				b = appendESTreeKey(b, "argument")
+				b = p.appendTarget(b, element.Value)
+				b = p.appendPosition(b, rest)
+				b = append(b, '}')
+			} else {
+				b = p.appendTarget(b, element.Value)
+			}
+			p.skip(js.CommaToken)
+		}
+		b = append(b, ']')
+		p.skip(js.CloseBracketToken)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.ObjectExpr:
+		p.skip(js.OpenBraceToken)
+		b = appendESTreeType(b, "ObjectPattern")
+		b = appendESTreeKey(b, "properties")
+		b = append(b, '[')
+		for i, property := range n.List {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			first := p.next
+			if property.Spread || property.Name == nil {
+				p.skip(js.EllipsisToken)
+				b = appendESTreeType(b, "RestElement")
+				b = appendESTreeKey(b, "argument")
+				b = p.appendTarget(b, property.Value)
+				b = p.appendPosition(b, first)
+				b = append(b, '}')
+				p.skip(js.CommaToken)
+				continue
+			}
+			b = appendESTreeType(b, "Property")
+			b = p.appendPropertyKey(b, *property.Name)
+			shorthand := isShorthand(*property.Name, property.Value)
+			if shorthand {
+				// The key is also the value
+				p.next = first
+			} else {
+				p.skip(js.ColonToken)
+			}
+			b = appendESTreeKey(b, "value")
+			if property.Init != nil {
+				b = p.appendAssignmentPattern(b, property.Value, property.Init)
+			} else {
+				b = p.appendTarget(b, property.Value)
+			}
+			b = append(b, `,"kind":"init","method":false`...)
+			b = appendESTreeBool(b, "shorthand", shorthand)
+			b = p.appendPosition(b, first)
+			b = append(b, '}')
+			p.skip(js.CommaToken)
+		}
+		b = append(b, ']')
+		p.skip(js.CloseBraceToken)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.BinaryExpr:
+		// A default value
+		if n.Op == js.EqToken {
+			return p.appendAssignmentPattern(b, n.X, n.Y)
+		}
+	}
+	return p.appendExpr(b, expr)
+}
+
+func (p *estreePrinter) appendAssignmentPattern(b []byte, left js.IExpr, right js.IExpr) []byte {
+	start := p.next
+	b = appendESTreeType(b, "AssignmentPattern")
+	b = appendESTreeKey(b, "left")
+	b = p.appendTarget(b, left)
+	p.skip(js.EqToken)
+	b = appendESTreeKey(b, "right")
+	b = p.appendExpr(b, right)
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+func isShorthand(name js.PropertyName, value js.INode) bool {
+	v, ok := value.(*js.Var)
+	return ok && name.IsIdent(v.Data)
+}
+
+// appendPropertyKey appends the key and computed fields of a property
+func (p *estreePrinter) appendPropertyKey(b []byte, name js.PropertyName) []byte {
+	b = appendESTreeKey(b, "key")
+	if name.IsComputed() {
+		p.skip(js.OpenBracketToken)
+		b = p.appendExpr(b, name.Computed)
+		p.skip(js.CloseBracketToken)
+	} else {
+		b = p.appendName(b, name.Literal)
+	}
+	return appendESTreeBool(b, "computed", name.IsComputed())
+}
+
+// skipModifiers reads the keywords before the name of a method
+func (p *estreePrinter) skipModifiers(method js.MethodDecl) {
+	if method.Static {
+		p.skip(js.StaticToken)
+	}
+	if method.Async {
+		p.skip(js.AsyncToken)
+	}
+	if method.Get {
+		p.skip(js.GetToken)
+	}
+	if method.Set {
+		p.skip(js.SetToken)
+	}
+	if method.Generator {
+		p.skip(js.MulToken)
+	}
+}
+
+func (p *estreePrinter) appendProperty(b []byte, property js.Property) []byte {
+	if property.Spread {
+		return p.appendSpread(b, "SpreadElement", property.Value)
+	}
+	start := p.next
+	if method, ok := property.Value.(*js.MethodDecl); ok {
+		kind := "init"
+		if method.Get {
+			kind = "get"
+		} else if method.Set {
+			kind = "set"
+		}
+		p.skipModifiers(*method)
+		b = appendESTreeType(b, "Property")
+		b = p.appendPropertyKey(b, method.Name)
+		b = appendESTreeKey(b, "value")
+		b = p.appendMethodFunction(b, *method)
+		b = append(b, `,"kind":"`...)
+		b = append(b, kind...)
+		b = append(b, '"')
+		b = appendESTreeBool(b, "method", kind == "init")
+		b = appendESTreeBool(b, "shorthand", false)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	}
+	b = appendESTreeType(b, "Property")
+	b = p.appendPropertyKey(b, *property.Name)
+	shorthand := isShorthand(*property.Name, property.Value)
+	if shorthand {
+		// The key is also the value
+		p.next = start
+	} else {
+		p.skip(js.ColonToken)
+	}
+	b = appendESTreeKey(b, "value")
+	if property.Init != nil {
+		// Only valid as a pattern, like `({ a = 1 } = b)`
+		b = p.appendAssignmentPattern(b, property.Value, property.Init)
+	} else {
+		b = p.appendExpr(b, property.Value)
+	}
+	b = append(b, `,"kind":"init","method":false`...)
+	b = appendESTreeBool(b, "shorthand", shorthand)
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+func (p *estreePrinter) appendBinding(b []byte, binding js.IBinding) []byte {
+	start := p.next
+	switch n := binding.(type) {
+	case *js.Var:
+		return p.appendIdentifier(b, n.Data, p.name(n.Data))
+	case *js.BindingArray:
+		p.skip(js.OpenBracketToken)
+		b = appendESTreeType(b, "ArrayPattern")
+		b = appendESTreeKey(b, "elements")
+		b = append(b, '[')
+		for i, element := range n.List {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = p.appendBindingElement(b, element)
+			p.skip(js.CommaToken)
+		}
+		if n.Rest != nil {
+			if len(n.List) > 0 {
+				b = append(b, ',')
+			}
+			b = p.appendSpread(b, "RestElement", n.Rest)
+		}
+		b = append(b, ']')
+		p.skip(js.CloseBracketToken)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	case *js.BindingObject:
+		p.skip(js.OpenBraceToken)
+		b = appendESTreeType(b, "ObjectPattern")
+		b = appendESTreeKey(b, "properties")
+		b = append(b, '[')
+		for i, item := range n.List {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			first := p.next
+			b = appendESTreeType(b, "Property")
+			if item.Key != nil {
+				b = p.appendPropertyKey(b, *item.Key)
+			} else {
+				name := item.Value.Binding.(*js.Var).Data
+				b = appendESTreeKey(b, "key")
+				b = p.appendIdentifier(b, name, p.name(name))
+				b = appendESTreeBool(b, "computed", false)
+			}
+			shorthand := item.Key == nil || isShorthand(*item.Key, item.Value.Binding)
+			if shorthand {
+				// The key is also the value
+				p.next = first
+			} else {
+				p.skip(js.ColonToken)
+			}
+			b = appendESTreeKey(b, "value")
+			b = p.appendBindingElement(b, item.Value)
+			b = append(b, `,"kind":"init","method":false`...)
+			b = appendESTreeBool(b, "shorthand", shorthand)
+			b = p.appendPosition(b, first)
+			b = append(b, '}')
+			p.skip(js.CommaToken)
+		}
+		if n.Rest != nil {
+			if len(n.List) > 0 {
+				b = append(b, ',')
+			}
+			b = p.appendSpread(b, "RestElement", n.Rest)
+		}
+		b = append(b, ']')
+		p.skip(js.CloseBraceToken)
+		b = p.appendPosition(b, start)
+		return append(b, '}')
+	}
+	return append(b, "null"...)
+}
+
+// appendBindingElement appends null for an elision
+func (p *estreePrinter) appendBindingElement(b []byte, element js.BindingElement) []byte {
+	if element.Binding == nil {
+		return append(b, "null"...)
+	}
+	if element.Default == nil {
+		return p.appendBinding(b, element.Binding)
+	}
+	start := p.next
+	b = appendESTreeType(b, "AssignmentPattern")
+	b = appendESTreeKey(b, "left")
+	b = p.appendBinding(b, element.Binding)
+	p.skip(js.EqToken)
+	b = appendESTreeKey(b, "right")
+	b = p.appendExpr(b, element.Default)
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+// appendParams appends the parameters of a function, which only an arrow
+// function with one parameter has no parentheses around
+func (p *estreePrinter) appendParams(b []byte, params js.Params) []byte {
+	parens := p.skip(js.OpenParenToken)
+	b = appendESTreeKey(b, "params")
+	b = append(b, '[')
+	for i, element := range params.List {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = p.appendBindingElement(b, element)
+		p.skip(js.CommaToken)
+	}
+	if params.Rest != nil {
+		if len(params.List) > 0 {
+			b = append(b, ',')
+		}
+		b = p.appendSpread(b, "RestElement", params.Rest)
+	}
+	if parens {
+		p.skip(js.CloseParenToken)
+	}
+	return append(b, ']')
+}
+
+func (p *estreePrinter) appendFunction(b []byte, typ string, n js.FuncDecl) []byte {
+	start := p.next
+	if n.Async {
+		p.skip(js.AsyncToken)
+	}
+	p.skip(js.FunctionToken)
+	if n.Generator {
+		p.skip(js.MulToken)
+	}
+	b = appendESTreeType(b, typ)
+	b = appendESTreeKey(b, "id")
+	if n.Name == nil {
+		b = append(b, "null"...)
+	} else {
+		b = p.appendIdentifier(b, n.Name.Data, p.name(n.Name.Data))
+	}
+	b = p.appendFunctionBody(b, n.Params, n.Body, n.Generator, n.Async)
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+// appendFunctionBody appends the fields of a function from its parameters on
+func (p *estreePrinter) appendFunctionBody(b []byte, params js.Params, body js.BlockStmt, generator bool, async bool) []byte {
+	b = p.appendParams(b, params)
+	b = appendESTreeKey(b, "body")
+	b = p.appendBlock(b, body)
+	b = appendESTreeBool(b, "expression", false)
+	b = appendESTreeBool(b, "generator", generator)
+	return appendESTreeBool(b, "async", async)
+}
+
+// appendMethodFunction appends the function of a method, which starts at its
+// parameters
+func (p *estreePrinter) appendMethodFunction(b []byte, n js.MethodDecl) []byte {
+	start := p.next
+	b = appendESTreeType(b, "FunctionExpression")
+	b = append(b, `,"id":null`...)
+	b = p.appendFunctionBody(b, n.Params, n.Body, n.Generator, n.Async)
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+// placeClassElement moves to the start of a class element, before its
+// modifiers. The parser keeps the fields and methods of a class apart, so
+// they aren't read in order.
+func (p *estreePrinter) placeClassElement(name js.PropertyName, modifiers int) {
+	if name.IsComputed() || len(name.Literal.Data) == 0 {
+		return
+	}
+	if i, ok := p.literals[&name.Literal.Data[0]]; ok && i >= modifiers {
+		p.next = i - modifiers
+	}
+}
+
+func countModifiers(method js.MethodDecl) int {
+	count := 0
+	for _, modifier := range []bool{method.Static, method.Async, method.Get, method.Set, method.Generator} {
+		if modifier {
+			count++
+		}
+	}
+	return count
+}
+
+func (p *estreePrinter) appendClass(b []byte, typ string, n js.ClassDecl) []byte {
+	start := p.next
+	p.skip(js.ClassToken)
+	b = appendESTreeType(b, typ)
+	b = appendESTreeKey(b, "id")
+	if n.Name == nil {
+		b = append(b, "null"...)
+	} else {
+		b = p.appendIdentifier(b, n.Name.Data, p.name(n.Name.Data))
+	}
+	if n.Extends != nil {
+		p.skip(js.ExtendsToken)
+	}
+	b = p.appendOptionalExpr(b, "superClass", n.Extends)
+	open := p.next
+	p.skip(js.OpenBraceToken)
+	b = append(b, `,"body":{"type":"ClassBody","body":[`...)
+	for i, field := range n.Definitions {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		p.placeClassElement(field.Name, 0)
+		first := p.next
+		b = appendESTreeType(b, "PropertyDefinition")
+		b = p.appendPropertyKey(b, field.Name)
+		if field.Init != nil {
+			p.skip(js.EqToken)
+		}
+		b = p.appendOptionalExpr(b, "value", field.Init)
+		b = appendESTreeBool(b, "static", false)
+		p.skip(js.SemicolonToken)
+		b = p.appendPosition(b, first)
+		b = append(b, '}')
+	}
+	for i, method := range n.Methods {
+		if i > 0 || len(n.Definitions) > 0 {
+			b = append(b, ',')
+		}
+		kind := "method"
+		if method.Get {
+			kind = "get"
+		} else if method.Set {
+			kind = "set"
+		} else if !method.Static && method.Name.IsIdent([]byte("constructor")) {
+			kind = "constructor"
+		}
+		p.placeClassElement(method.Name, countModifiers(*method))
+		first := p.next
+		p.skipModifiers(*method)
+		b = appendESTreeType(b, "MethodDefinition")
+		b = p.appendPropertyKey(b, method.Name)
+		b = appendESTreeKey(b, "value")
+		b = p.appendMethodFunction(b, *method)
+		b = append(b, `,"kind":"`...)
+		b = append(b, kind...)
+		b = append(b, '"')
+		b = appendESTreeBool(b, "static", method.Static)
+		b = p.appendPosition(b, first)
+		b = append(b, '}')
+	}
+	if open < len(p.tokens) {
+		p.next = p.closing(open) + 1
+	}
+	b = append(b, ']')
+	b = p.appendPosition(b, open)
+	b = append(b, '}')
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+// appendVarDecl appends a declaration, which ends with a semicolon as a
+// statement but not as the start of a for loop
+func (p *estreePrinter) appendVarDecl(b []byte, n js.VarDecl, statement bool) []byte {
+	start := p.next
+	// var, let or const
+	p.advance()
+	b = appendESTreeType(b, "VariableDeclaration")
+	b = appendESTreeKey(b, "declarations")
+	b = append(b, '[')
+	for i, element := range n.List {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		first := p.next
+		b = appendESTreeType(b, "VariableDeclarator")
+		b = appendESTreeKey(b, "id")
+		b = p.appendBinding(b, element.Binding)
+		if element.Default != nil {
+			p.skip(js.EqToken)
+		}
+		b = p.appendOptionalExpr(b, "init", element.Default)
+		b = p.appendPosition(b, first)
+		b = append(b, '}')
+		p.skip(js.CommaToken)
+	}
+	b = append(b, ']')
+	b = appendESTreeKey(b, "kind")
+	b = appendJSONString(b, n.TokenType.String())
+	if statement {
+		p.skip(js.SemicolonToken)
+	}
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+func (p *estreePrinter) appendBlock(b []byte, n js.BlockStmt) []byte {
+	start := p.next
+	// The parser makes a block of a single statement body, like that of `for (a of b) c`
+	braces := p.skip(js.OpenBraceToken)
+	b = appendESTreeType(b, "BlockStatement")
+	b = p.appendStmts(b, "body", n.List)
+	if braces {
+		p.skip(js.CloseBraceToken)
+	}
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
+
+func (p *estreePrinter) appendStmts(b []byte, key string, list []js.IStmt) []byte {
+	b = appendESTreeKey(b, key)
+	b = append(b, '[')
+	for i, stmt := range list {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = p.appendStmt(b, stmt)
+	}
+	return append(b, ']')
+}
+
+// appendOptionalStmt appends null for a missing statement
+func (p *estreePrinter) appendOptionalStmt(b []byte, key string, stmt js.IStmt) []byte {
+	b = appendESTreeKey(b, key)
+	if stmt == nil {
+		return append(b, "null"...)
+	}
+	return p.appendStmt(b, stmt)
+}
+
+func (p *estreePrinter) appendLabel(b []byte, label []byte) []byte {
+	b = appendESTreeKey(b, "label")
+	if label == nil {
+		return append(b, "null"...)
+	}
+	return p.appendIdentifier(b, label, p.literal(label))
+}
+
+func (p *estreePrinter) appendStmt(b []byte, stmt js.IStmt) []byte {
+	start := p.next
+	switch n := stmt.(type) {
+	case *js.BlockStmt:
+		return p.appendBlock(b, *n)
+	case *js.EmptyStmt:
+		p.skip(js.SemicolonToken)
+		b = appendESTreeType(b, "EmptyStatement")
+	case *js.DebuggerStmt:
+		p.advance()
+		p.skip(js.SemicolonToken)
+		b = appendESTreeType(b, "DebuggerStatement")
+	case *js.ExprStmt:
+		b = appendESTreeType(b, "ExpressionStatement")
+		b = appendESTreeKey(b, "expression")
+		b = p.appendExpr(b, n.Value)
+		p.skip(js.SemicolonToken)
+	case *js.DirectivePrologueStmt:
+		raw := string(n.Value)
+		b = appendESTreeType(b, "ExpressionStatement")
+		b = appendESTreeKey(b, "expression")
+		b = p.appendLiteral(b, js.LiteralExpr{TokenType: js.StringToken, Data: n.Value})
+		b = appendESTreeKey(b, "directive")
+		b = appendJSONString(b, raw[1:len(raw)-1])
+		p.skip(js.SemicolonToken)
+	case *js.VarDecl:
+		return p.appendVarDecl(b, *n, true)
+	case *js.FuncDecl:
+		return p.appendFunction(b, "FunctionDeclaration", *n)
+	case *js.ClassDecl:
+		return p.appendClass(b, "ClassDeclaration", *n)
+	case *js.IfStmt:
+		p.advance()
+		b = appendESTreeType(b, "IfStatement")
+		b = appendESTreeKey(b, "test")
+		p.skip(js.OpenParenToken)
+		b = p.appendExpr(b, n.Cond)
+		p.skip(js.CloseParenToken)
+		b = p.appendOptionalStmt(b, "consequent", n.Body)
+		if n.Else != nil {
+			p.skip(js.ElseToken)
+		}
+		b = p.appendOptionalStmt(b, "alternate", n.Else)
+	case *js.DoWhileStmt:
+		p.advance()
+		b = appendESTreeType(b, "DoWhileStatement")
+		b = p.appendOptionalStmt(b, "body", n.Body)
+		p.skip(js.WhileToken)
+		b = appendESTreeKey(b, "test")
+		p.skip(js.OpenParenToken)
+		b = p.appendExpr(b, n.Cond)
+		p.skip(js.CloseParenToken)
+		p.skip(js.SemicolonToken)
+	case *js.WhileStmt:
+		p.advance()
+		b = appendESTreeType(b, "WhileStatement")
+		b = appendESTreeKey(b, "test")
+		p.skip(js.OpenParenToken)
+		b = p.appendExpr(b, n.Cond)
+		p.skip(js.CloseParenToken)
+		b = p.appendOptionalStmt(b, "body", n.Body)
+	case *js.WithStmt:
+		p.advance()
+		b = appendESTreeType(b, "WithStatement")
+		b = appendESTreeKey(b, "object")
+		p.skip(js.OpenParenToken)
+		b = p.appendExpr(b, n.Cond)
+		p.skip(js.CloseParenToken)
+		b = p.appendOptionalStmt(b, "body", n.Body)
+	case *js.ForStmt:
+		p.advance()
+		p.skip(js.OpenParenToken)
+		b = appendESTreeType(b, "ForStatement")
+		b = p.appendOptionalExpr(b, "init", n.Init)
+		p.skip(js.SemicolonToken)
+		b = p.appendOptionalExpr(b, "test", n.Cond)
+		p.skip(js.SemicolonToken)
+		b = p.appendOptionalExpr(b, "update", n.Post)
+		p.skip(js.CloseParenToken)
+		b = appendESTreeKey(b, "body")
+		b = p.appendBlock(b, *n.Body)
+	case *js.ForInStmt:
+		p.advance()
+		p.skip(js.OpenParenToken)
+		b = appendESTreeType(b, "ForInStatement")
+		b = appendESTreeKey(b, "left")
+		b = p.appendTarget(b, n.Init)
+		p.skip(js.InToken)
+		b = appendESTreeKey(b, "right")
+		b = p.appendExpr(b, n.Value)
+		p.skip(js.CloseParenToken)
+		b = appendESTreeKey(b, "body")
+		b = p.appendBlock(b, *n.Body)
+	case *js.ForOfStmt:
+		p.advance()
+		if n.Await {
+			p.skip(js.AwaitToken)
+		}
+		p.skip(js.OpenParenToken)
+		b = appendESTreeType(b, "ForOfStatement")
+		b = appendESTreeBool(b, "await", n.Await)
+		b = appendESTreeKey(b, "left")
+		b = p.appendTarget(b, n.Init)
+		p.skip(js.OfToken)
+		b = appendESTreeKey(b, "right")
+		b = p.appendExpr(b, n.Value)
+		p.skip(js.CloseParenToken)
+		b = appendESTreeKey(b, "body")
+		b = p.appendBlock(b, *n.Body)
+	case *js.SwitchStmt:
+		p.advance()
+		b = appendESTreeType(b, "SwitchStatement")
+		b = appendESTreeKey(b, "discriminant")
+		p.skip(js.OpenParenToken)
+		b = p.appendExpr(b, n.Init)
+		p.skip(js.CloseParenToken)
+		p.skip(js.OpenBraceToken)
+		b = appendESTreeKey(b, "cases")
+		b = append(b, '[')
+		for i, clause := range n.List {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			first := p.next
+			// case or default
+			p.advance()
+			b = appendESTreeType(b, "SwitchCase")
+			b = p.appendOptionalExpr(b, "test", clause.Cond)
+			p.skip(js.ColonToken)
+			b = p.appendStmts(b, "consequent", clause.List)
+			b = p.appendPosition(b, first)
+			b = append(b, '}')
+		}
+		b = append(b, ']')
+		p.skip(js.CloseBraceToken)
+	case *js.BranchStmt:
+		p.advance()
+		if n.Type == js.ContinueToken {
+			b = appendESTreeType(b, "ContinueStatement")
+		} else {
+			b = appendESTreeType(b, "BreakStatement")
+		}
+		b = p.appendLabel(b, n.Label)
+		p.skip(js.SemicolonToken)
+	case *js.ReturnStmt:
+		p.advance()
+		b = appendESTreeType(b, "ReturnStatement")
+		b = p.appendOptionalExpr(b, "argument", n.Value)
+		p.skip(js.SemicolonToken)
+	case *js.LabelledStmt:
+		b = appendESTreeType(b, "LabeledStatement")
+		b = p.appendLabel(b, n.Label)
+		p.skip(js.ColonToken)
+		b = p.appendOptionalStmt(b, "body", n.Value)
+	case *js.ThrowStmt:
+		p.advance()
+		b = appendESTreeType(b, "ThrowStatement")
+		b = appendESTreeKey(b, "argument")
+		b = p.appendExpr(b, n.Value)
+		p.skip(js.SemicolonToken)
+	case *js.TryStmt:
+		p.advance()
+		b = appendESTreeType(b, "TryStatement")
+		b = appendESTreeKey(b, "block")
+		b = p.appendBlock(b, *n.Body)
+		b = appendESTreeKey(b, "handler")
+		if n.Catch == nil {
+			b = append(b, "null"...)
+		} else {
+			first := p.next
+			p.skip(js.CatchToken)
+			b = appendESTreeType(b, "CatchClause")
+			b = appendESTreeKey(b, "param")
+			if n.Binding == nil {
+				b = append(b, "null"...)
+			} else {
+				p.skip(js.OpenParenToken)
+				b = p.appendBinding(b, n.Binding)
+				p.skip(js.CloseParenToken)
+			}
+			b = appendESTreeKey(b, "body")
+			b = p.appendBlock(b, *n.Catch)
+			b = p.appendPosition(b, first)
+			b = append(b, '}')
+		}
+		b = appendESTreeKey(b, "finalizer")
+		if n.Finally == nil {
+			b = append(b, "null"...)
+		} else {
+			p.skip(js.FinallyToken)
+			b = p.appendBlock(b, *n.Finally)
+		}
+	default:
+		// Imports and exports can't be in an expression
+		return append(b, "null"...)
+	}
+	b = p.appendPosition(b, start)
+	return append(b, '}')
+}
@@ -5,7 +5,10 @@
 package printer
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"sort"
 	"strings"
 
@@ -42,17 +45,28 @@ import (
 // Another example is that the programmatic equivalent of "a<head>b</head>c"
 // becomes "<html><head><head/><body>abc</body></html>".
 func PrintToJS(sourcetext string, n *Node, cssLen int, opts transform.TransformOptions) PrintResult {
-	p := &printer{
-		opts:    opts,
-		builder: sourcemap.MakeChunkBuilder(nil, sourcemap.GenerateLineOffsetTables(sourcetext, len(strings.Split(sourcetext, "\n")))),
+	var output bytes.Buffer
+	// Writing to a bytes.Buffer can't fail
+	chunk, _ := PrintToJSWriter(&output, sourcetext, n, cssLen, opts)
+	return PrintResult{
+		Output:         output.Bytes(),
+		SourceMapChunk: chunk,
 	}
-	return printToJs(p, n, cssLen, opts)
 }
 
 func PrintToJSFragment(sourcetext string, n *Node, cssLen int, opts transform.TransformOptions) PrintResult {
+	return PrintToJS(sourcetext, n, cssLen, opts)
+}
+
+// PrintToJSWriter is like PrintToJS, but writes the code to w as it's printed,
+// so the output for a large document doesn't need to be held in memory. The
+// source map is built as the code is written. The error is the first one
+// returned by w.
+func PrintToJSWriter(w io.Writer, sourcetext string, n *Node, cssLen int, opts transform.TransformOptions) (sourcemap.Chunk, error) {
 	p := &printer{
 		opts:    opts,
-		builder: sourcemap.MakeChunkBuilder(nil, sourcemap.GenerateLineOffsetTables(sourcetext, len(strings.Split(sourcetext, "\n")))),
+		out:     bufio.NewWriter(w),
+		builder: newChunkBuilder(sourcetext),
 	}
 	return printToJs(p, n, cssLen, opts)
 }
@@ -70,7 +84,11 @@ type ExtractedStatement struct {
 	Loc     loc.Loc
 }
 
-func printToJs(p *printer, n *Node, cssLen int, opts transform.TransformOptions) PrintResult {
+func printToJs(p *printer, n *Node, cssLen int, opts transform.TransformOptions) (sourcemap.Chunk, error) {
+	p.doc = n
+	p.directives = transform.GetUsedDirectives(n, opts)
+	p.needsClassList = hasNode(n, needsClassList)
+	p.needsSerializeStyle = hasNode(n, needsSerializeStyle)
 	render1(p, n, RenderOptions{
 		cssLen:       cssLen,
 		isRoot:       true,
@@ -79,10 +97,7 @@ func printToJs(p *printer, n *Node, cssLen int, opts transform.TransformOptions)
 		opts:         opts,
 	})
 
-	return PrintResult{
-		Output:         p.output,
-		SourceMapChunk: p.builder.GenerateChunk(p.output),
-	}
+	return p.builder.GenerateChunk(), p.out.Flush()
 }
 
 func render1(p *printer, n *Node, opts RenderOptions) {
@@ -382,17 +397,10 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 				if a.Key != "name" {
 					continue
 				}
-				switch a.Type {
-				case QuotedAttribute:
-					p.addSourceMapping(a.ValLoc)
-					p.print(`"` + a.Val + `"`)
-					slotted = true
-				default:
-					panic("slot[name] must be a static string")
-				}
-				// if i != len(n.Attr)-1 {
-				// 	p.print("")
-				// }
+				p.addSourceMapping(a.ValLoc)
+				name, _ := getSlotName(a)
+				p.print(name)
+				slotted = true
 			}
 			if !slotted {
 				p.print(`"default"`)
@@ -400,11 +408,25 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 		}
 		p.print(`]`)
 	} else {
+		hasClassList := transform.HasClassList(n)
+		printedClassList := false
 		for _, a := range n.Attr {
 			if transform.IsImplictNodeMarker(a) || a.Key == "is:inline" {
 				continue
 			}
-			if a.Key == "slot" {
+			if hasClassList && transform.IsClassAttr(a) {
+				// `class` and `class:list` are merged into a single attribute
+				if !printedClassList {
+					p.printClassList(n, a)
+					p.addSourceMapping(n.Loc[0])
+					printedClassList = true
+				}
+			} else if directive, ok := transform.GetDirective(p.opts, a); ok {
+				if directive.SupportsNode(n) {
+					p.printDirective(directive, a)
+					p.addSourceMapping(n.Loc[0])
+				}
+			} else if a.Key == "slot" {
 				if !(n.Parent.Component || n.Parent.CustomElement) {
 					panic(`Element with a slot='...' attribute must be a child of a component or a descendant of a custom element`)
 				}
@@ -421,7 +443,7 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 		p.print(">")
 	}
 
-	if voidElements[n.Data] {
+	if VoidElements[n.Data] {
 		if n.FirstChild != nil {
 			// return fmt.Errorf("html: void element <%s> has child nodes", n.Data)
 		}
@@ -495,12 +517,12 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 					slotProp := `"default"`
 					for _, a := range c.Attr {
 						if a.Key == "slot" {
-							if a.Type == QuotedAttribute {
-								slotProp = fmt.Sprintf(`"%s"`, a.Val)
-							} else if a.Type == ExpressionAttribute {
-								slotProp = fmt.Sprintf(`[%s]`, a.Val)
+							name, isStatic := getSlotName(a)
+							if isStatic {
+								slotProp = name
 							} else {
-								panic(`unknown slot attribute type`)
+								// Dynamic slot names become computed keys
+								slotProp = fmt.Sprintf(`[%s]`, name)
 							}
 						}
 					}
@@ -571,24 +593,3 @@ func render1(p *printer, n *Node, opts RenderOptions) {
 		p.print(`</` + n.Data + `>`)
 	}
 }
-
-// Section 12.1.2, "Elements", gives this list of void elements. Void elements
-// are those that can't have any contents.
-//nolint
-var voidElements = map[string]bool{
-	"area":   true,
-	"base":   true,
-	"br":     true,
-	"col":    true,
-	"embed":  true,
-	"hr":     true,
-	"img":    true,
-	"input":  true,
-	"keygen": true, // "keygen" has been removed from the spec, but are kept here for backwards compatibility.
-	"link":   true,
-	"meta":   true,
-	"param":  true,
-	"source": true,
-	"track":  true,
-	"wbr":    true,
-}
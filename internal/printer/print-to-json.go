@@ -1,13 +1,14 @@
 package printer
 
 import (
-	"fmt"
-	"regexp"
+	"encoding/json"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	. "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/js_scanner"
 	"github.com/withastro/compiler/internal/loc"
-	"github.com/withastro/compiler/internal/sourcemap"
 	"github.com/withastro/compiler/internal/t"
 	"github.com/withastro/compiler/internal/transform"
 )
@@ -34,83 +35,383 @@ type ASTNode struct {
 
 	// Attributes only
 	Kind string `json:"kind,omitempty"`
+
+	// Frontmatter imports and exports only
+	Source     string         `json:"source,omitempty"`
+	Specifiers []ASTSpecifier `json:"specifiers,omitempty"`
+	Assertions string         `json:"assertions,omitempty"`
+	TypeOnly   bool           `json:"typeOnly,omitempty"`
+
+	// Expressions only
+	ESTree      json.RawMessage `json:"estree,omitempty"`
+	ESTreeError string          `json:"estreeError,omitempty"`
 }
 
-func escapeForJSON(value string) string {
-	newlines := regexp.MustCompile(`\n`)
-	value = newlines.ReplaceAllString(value, `\n`)
-	doublequotes := regexp.MustCompile(`"`)
-	value = doublequotes.ReplaceAllString(value, `\"`)
-	r := regexp.MustCompile(`\r`)
-	value = r.ReplaceAllString(value, `\r`)
-	t := regexp.MustCompile(`\t`)
-	value = t.ReplaceAllString(value, `\t`)
-	f := regexp.MustCompile(`\f`)
-	value = f.ReplaceAllString(value, `\f`)
-	return value
+// An ASTSpecifier is a binding of an import or export declaration
+type ASTSpecifier struct {
+	// Kind is `default`, `named` or `namespace`
+	Kind     string `json:"kind"`
+	Imported string `json:"imported,omitempty"`
+	Exported string `json:"exported,omitempty"`
+	Local    string `json:"local,omitempty"`
+	TypeOnly bool   `json:"typeOnly,omitempty"`
 }
 
-func (n ASTNode) String() string {
-	str := fmt.Sprintf(`{"type":"%s"`, n.Type)
+const hex = "0123456789abcdef"
+
+// appendJSONString appends value as a JSON string, escaped as described in
+// RFC 8259. Invalid UTF-8 is replaced with U+FFFD, like encoding/json does.
+func appendJSONString(b []byte, value string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(value); {
+		c := value[i]
+		if c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' {
+				i++
+				continue
+			}
+			b = append(b, value[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(value[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, value[start:i]...)
+			b = append(b, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, value[start:]...)
+	return append(b, '"')
+}
+
+func appendJSONPoint(b []byte, name string, point ASTPoint) []byte {
+	b = append(b, '"')
+	b = append(b, name...)
+	b = append(b, `":{"line":`...)
+	b = strconv.AppendInt(b, int64(point.Line), 10)
+	b = append(b, `,"column":`...)
+	b = strconv.AppendInt(b, int64(point.Column), 10)
+	b = append(b, `,"offset":`...)
+	b = strconv.AppendInt(b, int64(point.Offset), 10)
+	return append(b, '}')
+}
+
+func hasChildList(n ASTNode) bool {
+	return isElementType(n.Type)
+}
+
+// isElementType returns true for the node types that always have attributes and children
+func isElementType(typ string) bool {
+	return typ == "element" || typ == "component" || typ == "custom-element" || typ == "fragment"
+}
+
+// appendJSON appends n to b as JSON, in a single pass over the tree
+func (n ASTNode) appendJSON(b []byte) []byte {
+	b = append(b, `{"type":`...)
+	b = appendJSONString(b, n.Type)
 	if n.Kind != "" {
-		str += fmt.Sprintf(`,"kind":"%s"`, n.Kind)
+		b = append(b, `,"kind":`...)
+		b = appendJSONString(b, n.Kind)
 	}
-	if n.Name != "" {
-		str += fmt.Sprintf(`,"name":"%s"`, escapeForJSON(n.Name))
-	} else if n.Type == "fragment" {
-		str += `,"name":""`
+	if n.Name != "" || n.Type == "fragment" {
+		b = append(b, `,"name":`...)
+		b = appendJSONString(b, n.Name)
 	}
 	if n.Value != "" || n.Type == "attribute" {
-		str += fmt.Sprintf(`,"value":"%s"`, escapeForJSON(n.Value))
+		b = append(b, `,"value":`...)
+		b = appendJSONString(b, n.Value)
 	}
-	if len(n.Attributes) > 0 {
-		str += `,"attributes":[`
+	if len(n.Attributes) > 0 || hasChildList(n) {
+		b = append(b, `,"attributes":[`...)
 		for i, attr := range n.Attributes {
-			str += attr.String()
-			if i < len(n.Attributes)-1 {
-				str += ","
+			if i > 0 {
+				b = append(b, ',')
 			}
+			b = attr.appendJSON(b)
 		}
-		str += `]`
-	} else if n.Type == "element" || n.Type == "component" || n.Type == "custom-element" || n.Type == "fragment" {
-		str += `,"attributes":[]`
+		b = append(b, ']')
 	}
-	if len(n.Children) > 0 {
-		str += `,"children":[`
-		for i, node := range n.Children {
-			str += node.String()
-			if i < len(n.Children)-1 {
-				str += ","
+	if len(n.Children) > 0 || hasChildList(n) {
+		b = append(b, `,"children":[`...)
+		for i, child := range n.Children {
+			if i > 0 {
+				b = append(b, ',')
 			}
+			b = child.appendJSON(b)
 		}
-		str += `]`
-	} else if n.Type == "element" || n.Type == "component" || n.Type == "custom-element" || n.Type == "fragment" {
-		str += `,"children":[]`
+		b = append(b, ']')
 	}
-	if n.Position.Start.Line != 0 {
-		str += `,"position":{`
-		str += fmt.Sprintf(`"start":{"line":%d,"column":%d,"offset":%d}`, n.Position.Start.Line, n.Position.Start.Column, n.Position.Start.Offset)
-		if n.Position.End.Line != 0 {
-			str += fmt.Sprintf(`,"end":{"line":%d,"column":%d,"offset":%d}`, n.Position.End.Line, n.Position.End.Column, n.Position.End.Offset)
+	if n.Type == "import" || n.Type == "export" {
+		if n.Source != "" {
+			b = append(b, `,"source":`...)
+			b = appendJSONString(b, n.Source)
+		}
+		b = append(b, `,"specifiers":[`...)
+		for i, specifier := range n.Specifiers {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = specifier.appendJSON(b)
 		}
-		str += "}"
+		b = append(b, ']')
+		if n.Assertions != "" {
+			b = append(b, `,"assertions":`...)
+			b = appendJSONString(b, n.Assertions)
+		}
+		if n.TypeOnly {
+			b = append(b, `,"typeOnly":true`...)
+		}
+	}
+	if len(n.ESTree) > 0 {
+		b = append(b, `,"estree":`...)
+		b = append(b, n.ESTree...)
+	}
+	if n.ESTreeError != "" {
+		b = append(b, `,"estreeError":`...)
+		b = appendJSONString(b, n.ESTreeError)
 	}
-	str += "}"
-	return str
+	b = appendJSONPosition(b, n.Position)
+	return append(b, '}')
 }
 
+// appendJSONPosition appends the position field, unless the position is unknown
+func appendJSONPosition(b []byte, position ASTPosition) []byte {
+	if position.Start.Line == 0 {
+		return b
+	}
+	b = append(b, `,"position":{`...)
+	b = appendJSONPoint(b, "start", position.Start)
+	if position.End.Line != 0 {
+		b = append(b, ',')
+		b = appendJSONPoint(b, "end", position.End)
+	}
+	return append(b, '}')
+}
+
+func (s ASTSpecifier) appendJSON(b []byte) []byte {
+	b = append(b, `{"kind":`...)
+	b = appendJSONString(b, s.Kind)
+	if s.Imported != "" {
+		b = append(b, `,"imported":`...)
+		b = appendJSONString(b, s.Imported)
+	}
+	if s.Exported != "" {
+		b = append(b, `,"exported":`...)
+		b = appendJSONString(b, s.Exported)
+	}
+	if s.Local != "" {
+		b = append(b, `,"local":`...)
+		b = appendJSONString(b, s.Local)
+	}
+	if s.TypeOnly {
+		b = append(b, `,"typeOnly":true`...)
+	}
+	return append(b, '}')
+}
+
+func (n ASTNode) String() string {
+	return string(n.appendJSON(nil))
+}
+
+// PrintToJSON encodes the tree rooted at n as JSON while it walks it, so the
+// output is written in a single pass without building an intermediate tree
 func PrintToJSON(sourcetext string, n *Node, opts t.ParseOptions) PrintResult {
-	p := &printer{
-		builder: sourcemap.MakeChunkBuilder(nil, sourcemap.GenerateLineOffsetTables(sourcetext, len(strings.Split(sourcetext, "\n")))),
+	e := &jsonEncoder{
+		p: &printer{
+			builder: newChunkBuilder(sourcetext),
+		},
+		opts:   opts,
+		source: sourcetext,
+		// The output is usually a few times bigger than the source
+		b: make([]byte, 0, len(sourcetext)*4),
 	}
-	root := ASTNode{}
-	renderNode(p, &root, n, opts)
-	doc := root.Children[0]
+	e.appendNode(n)
 	return PrintResult{
-		Output: []byte(doc.String()),
+		Output: e.b,
 	}
 }
 
+type jsonEncoder struct {
+	p      *printer
+	opts   t.ParseOptions
+	source string
+	b      []byte
+}
+
+func isImplicitNode(n *Node) bool {
+	for _, a := range n.Attr {
+		if transform.IsImplictNodeMarker(a) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonNodeType(n *Node) string {
+	if n.Type != ElementNode {
+		return n.Type.String()
+	}
+	switch {
+	case n.Expression:
+		return "expression"
+	case n.Component:
+		return "component"
+	case n.CustomElement:
+		return "custom-element"
+	case n.Fragment:
+		return "fragment"
+	}
+	return "element"
+}
+
+// appendNode appends n as JSON, with the fields in the same order as ASTNode.appendJSON
+func (e *jsonEncoder) appendNode(n *Node) {
+	typ := jsonNodeType(n)
+	e.b = append(e.b, `{"type":`...)
+	e.b = appendJSONString(e.b, typ)
+	isElement := isElementType(typ)
+	if isElement && (n.Data != "" || typ == "fragment") {
+		e.b = append(e.b, `,"name":`...)
+		e.b = appendJSONString(e.b, n.Data)
+	}
+
+	value := ""
+	if n.Type == TextNode || n.Type == CommentNode || n.Type == DoctypeNode {
+		value = n.Data
+	}
+	isFrontmatter := n.Type == FrontmatterNode && n.FirstChild != nil
+	if isFrontmatter {
+		value = n.FirstChild.Data
+	}
+	if value != "" {
+		e.b = append(e.b, `,"value":`...)
+		e.b = appendJSONString(e.b, value)
+	}
+
+	if isElement {
+		e.b = append(e.b, `,"attributes":[`...)
+		for i, attr := range n.Attr {
+			if i > 0 {
+				e.b = append(e.b, ',')
+			}
+			e.appendAttribute(&attr)
+		}
+		e.b = append(e.b, ']')
+	}
+
+	if isFrontmatter {
+		if e.opts.FrontmatterAST {
+			if children := frontmatterChildren(e.p, n.FirstChild, e.opts); len(children) > 0 {
+				e.b = append(e.b, `,"children":[`...)
+				for i, child := range children {
+					if i > 0 {
+						e.b = append(e.b, ',')
+					}
+					e.b = child.appendJSON(e.b)
+				}
+				e.b = append(e.b, ']')
+			}
+		}
+	} else {
+		count := 0
+		e.appendChildren(n, &count)
+		if count > 0 {
+			e.b = append(e.b, ']')
+		} else if isElement {
+			e.b = append(e.b, `,"children":[]`...)
+		}
+		if typ == "expression" && e.opts.ExpressionAST {
+			var source *expressionSource
+			if e.opts.Position && len(n.Loc) == 2 {
+				source = &expressionSource{p: e.p, text: e.source, start: n.Loc[0].Start, end: n.Loc[1].Start}
+			}
+			estree, err := expressionESTree(flattenChildren(n, nil), source)
+			if len(estree) > 0 {
+				e.b = append(e.b, `,"estree":`...)
+				e.b = append(e.b, estree...)
+			}
+			if err != nil {
+				e.b = append(e.b, `,"estreeError":`...)
+				e.b = appendJSONString(e.b, err.Error())
+			}
+		}
+	}
+
+	e.b = appendJSONPosition(e.b, positionAt(e.p, n, e.opts))
+	e.b = append(e.b, '}')
+}
+
+// appendChildren appends the children of n, opening the children field before
+// the first one. The children of implicit nodes are appended in their place.
+func (e *jsonEncoder) appendChildren(n *Node, count *int) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isImplicitNode(c) {
+			e.appendChildren(c, count)
+			continue
+		}
+		if *count == 0 {
+			e.b = append(e.b, `,"children":[`...)
+		} else {
+			e.b = append(e.b, ',')
+		}
+		*count++
+		e.appendNode(c)
+	}
+}
+
+func (e *jsonEncoder) appendAttribute(attr *Attribute) {
+	e.b = append(e.b, `{"type":"attribute","kind":`...)
+	e.b = appendJSONString(e.b, attr.Type.String())
+	// Foreign attributes keep their namespace, like xlink:href
+	name := attr.Key
+	if attr.Namespace != "" {
+		name = attr.Namespace + ":" + attr.Key
+	}
+	if name != "" {
+		e.b = append(e.b, `,"name":`...)
+		e.b = appendJSONString(e.b, name)
+	}
+	e.b = append(e.b, `,"value":`...)
+	e.b = appendJSONString(e.b, attr.Val)
+	e.b = appendJSONPosition(e.b, attrPositionAt(e.p, attr, e.opts))
+	e.b = append(e.b, '}')
+}
+
+// flattenChildren appends the children of n to children, replacing implicit
+// nodes with their own children like appendChildren does
+func flattenChildren(n *Node, children []*Node) []*Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if isImplicitNode(c) {
+			children = flattenChildren(c, children)
+			continue
+		}
+		children = append(children, c)
+	}
+	return children
+}
+
 func locToPoint(p *printer, loc loc.Loc) ASTPoint {
 	offset := loc.Start
 	info := p.builder.GetLineAndColumnForLocation(loc)
@@ -165,68 +466,141 @@ func attrPositionAt(p *printer, n *Attribute, opts t.ParseOptions) ASTPosition {
 	}
 }
 
-func renderNode(p *printer, parent *ASTNode, n *Node, opts t.ParseOptions) {
-	isImplicit := false
-	for _, a := range n.Attr {
-		if transform.IsImplictNodeMarker(a) {
-			isImplicit = true
-			break
-		}
+// frontmatterChildren breaks the frontmatter down into its import and export
+// declarations, and the code between them
+func frontmatterChildren(p *printer, text *Node, opts t.ParseOptions) []ASTNode {
+	source := text.Data
+	offset := 0
+	if len(text.Loc) > 0 {
+		offset = text.Loc[0].Start
 	}
-	hasChildren := n.FirstChild != nil
-	if isImplicit {
-		if hasChildren {
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				renderNode(p, parent, c, opts)
-			}
+	declarations := make([]ASTNode, 0)
+	starts := make([]int, 0)
+	ends := make([]int, 0)
+	add := func(node ASTNode, start int, end int) {
+		// Leave the line terminator that ended the statement to the code after it
+		end = start + len(strings.TrimRight(source[start:end], " \t\r\n"))
+		node.Value = source[start:end]
+		node.Position = rangePositionAt(p, offset+start, offset+end, opts)
+		declarations = append(declarations, node)
+		starts = append(starts, start)
+		ends = append(ends, end)
+	}
+
+	// The last import may not be followed by a line terminator
+	padded := []byte(source + "\n")
+	exports := js_scanner.GetExportStatements([]byte(source))
+	pos, statement := js_scanner.NextImportStatement(padded, 0)
+	for pos != -1 || len(exports) > 0 {
+		// Merge the imports and exports in source order
+		if pos == -1 || (len(exports) > 0 && exports[0].Start < statement.Start) {
+			add(exportNode(exports[0]), exports[0].Start, exports[0].End)
+			exports = exports[1:]
+			continue
+		}
+		end := pos
+		if end > len(source) {
+			end = len(source)
 		}
-		return
+		if !isDynamicImport(source[statement.Start:end]) && (len(ends) == 0 || statement.Start >= ends[len(ends)-1]) {
+			add(importNode(statement), statement.Start, end)
+		}
+		pos, statement = js_scanner.NextImportStatement(padded, pos)
 	}
-	var node ASTNode
 
-	node.Position = positionAt(p, n, opts)
+	children := make([]ASTNode, 0, len(declarations)*2+1)
+	codeStart := 0
+	for i, declaration := range declarations {
+		children = appendCodeNode(p, children, source, codeStart, starts[i], offset, opts)
+		children = append(children, declaration)
+		codeStart = ends[i]
+	}
+	return appendCodeNode(p, children, source, codeStart, len(source), offset, opts)
+}
 
-	if n.Type == ElementNode {
-		if n.Expression {
-			node.Type = "expression"
-		} else {
-			node.Name = n.Data
-			if n.Component {
-				node.Type = "component"
-			} else if n.CustomElement {
-				node.Type = "custom-element"
-			} else if n.Fragment {
-				node.Type = "fragment"
-			} else {
-				node.Type = "element"
-			}
+// appendCodeNode appends the code between start and end, if it isn't only whitespace
+func appendCodeNode(p *printer, children []ASTNode, source string, start int, end int, offset int, opts t.ParseOptions) []ASTNode {
+	code := source[start:end]
+	trimmed := strings.TrimLeft(code, " \t\r\n")
+	start += len(code) - len(trimmed)
+	trimmed = strings.TrimRight(trimmed, " \t\r\n")
+	if trimmed == "" {
+		return children
+	}
+	return append(children, ASTNode{
+		Type:     "code",
+		Value:    trimmed,
+		Position: rangePositionAt(p, offset+start, offset+start+len(trimmed), opts),
+	})
+}
 
-			for _, attr := range n.Attr {
-				attrNode := ASTNode{
-					Type:     "attribute",
-					Kind:     attr.Type.String(),
-					Position: attrPositionAt(p, &attr, opts),
-					Name:     attr.Key,
-					Value:    attr.Val,
-				}
-				node.Attributes = append(node.Attributes, attrNode)
-			}
-		}
-	} else {
-		node.Type = n.Type.String()
-		if n.Type == TextNode || n.Type == CommentNode || n.Type == DoctypeNode {
-			node.Value = n.Data
+// isDynamicImport returns true for `import('./a')`, which NextImportStatement
+// can't tell apart from an import declaration
+func isDynamicImport(statement string) bool {
+	return strings.HasPrefix(strings.TrimLeft(statement[len("import"):], " \t\r\n"), "(")
+}
+
+func importNode(statement js_scanner.ImportStatement) ASTNode {
+	node := ASTNode{
+		Type:       "import",
+		Source:     statement.Specifier,
+		Specifiers: make([]ASTSpecifier, 0, len(statement.Imports)),
+		Assertions: statement.Assertions,
+		TypeOnly:   statement.IsType,
+	}
+	for _, imported := range statement.Imports {
+		specifier := ASTSpecifier{Local: imported.LocalName, TypeOnly: imported.IsType && !statement.IsType}
+		switch imported.ExportName {
+		case "default":
+			specifier.Kind = "default"
+		case "*":
+			specifier.Kind = "namespace"
+		default:
+			specifier.Kind = "named"
+			specifier.Imported = imported.ExportName
 		}
+		node.Specifiers = append(node.Specifiers, specifier)
 	}
-	if n.Type == FrontmatterNode && hasChildren {
-		node.Value = n.FirstChild.Data
-	} else {
-		if !isImplicit && hasChildren {
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				renderNode(p, &node, c, opts)
-			}
+	return node
+}
+
+func exportNode(statement js_scanner.ExportStatement) ASTNode {
+	node := ASTNode{
+		Type:       "export",
+		Source:     statement.Specifier,
+		Specifiers: make([]ASTSpecifier, 0, len(statement.Exports)),
+		Assertions: statement.Assertions,
+		TypeOnly:   statement.IsType,
+	}
+	for _, exported := range statement.Exports {
+		specifier := ASTSpecifier{Local: exported.LocalName, TypeOnly: exported.IsType && !statement.IsType}
+		switch {
+		case exported.ExportName == "default":
+			specifier.Kind = "default"
+		case exported.ExportName == "*":
+			// `export * from`
+			specifier.Kind = "namespace"
+			specifier.Local = ""
+		case exported.LocalName == "*":
+			// `export * as a from`
+			specifier.Kind = "namespace"
+			specifier.Exported = exported.ExportName
+			specifier.Local = ""
+		default:
+			specifier.Kind = "named"
+			specifier.Exported = exported.ExportName
 		}
+		node.Specifiers = append(node.Specifiers, specifier)
 	}
+	return node
+}
 
-	parent.Children = append(parent.Children, node)
+func rangePositionAt(p *printer, start int, end int, opts t.ParseOptions) ASTPosition {
+	if !opts.Position {
+		return ASTPosition{}
+	}
+	return ASTPosition{
+		Start: locToPoint(p, loc.Loc{Start: start}),
+		End:   locToPoint(p, loc.Loc{Start: end}),
+	}
 }
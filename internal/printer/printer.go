@@ -1,14 +1,18 @@
 package printer
 
 import (
+	"bufio"
 	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	astro "github.com/withastro/compiler/internal"
 	"github.com/withastro/compiler/internal/js_scanner"
 	"github.com/withastro/compiler/internal/loc"
 	"github.com/withastro/compiler/internal/sourcemap"
 	"github.com/withastro/compiler/internal/transform"
+	"github.com/withastro/compiler/lib/esbuild/css_ast"
 	"golang.org/x/net/html/atom"
 )
 
@@ -19,11 +23,17 @@ type PrintResult struct {
 
 type printer struct {
 	opts               transform.TransformOptions
-	output             []byte
+	out                *bufio.Writer
 	builder            sourcemap.ChunkBuilder
 	hasFuncPrelude     bool
 	hasInternalImports bool
 	hasCSSImports      bool
+	directives         []transform.Directive
+	// needsClassList is true if a `class:list` directive is merged at runtime
+	needsClassList bool
+	// needsSerializeStyle is true if a `style` object literal is serialized at runtime
+	needsSerializeStyle bool
+	doc                 *astro.Node
 }
 
 var TEMPLATE_TAG = "$$render"
@@ -37,18 +47,29 @@ var SPREAD_ATTRIBUTES = "$$spreadAttributes"
 var DEFINE_STYLE_VARS = "$$defineStyleVars"
 var DEFINE_SCRIPT_VARS = "$$defineScriptVars"
 var CREATE_METADATA = "$$createMetadata"
+var CLASS_LIST = "$$classList"
+var SERIALIZE_STYLE = "$$serializeStyle"
 var METADATA = "$$metadata"
 var RESULT = "$$result"
 var SLOTS = "$$slots"
 var FRAGMENT = "Fragment"
 var BACKTICK = "`"
 
+// newChunkBuilder returns a source map builder for generated code that maps back to sourcetext
+func newChunkBuilder(sourcetext string) sourcemap.ChunkBuilder {
+	return sourcemap.MakeChunkBuilder(nil, sourcemap.GenerateLineOffsetTables(sourcetext, strings.Count(sourcetext, "\n")+1))
+}
+
+// print writes text to the output. Write errors are kept by p.out, and
+// returned when it's flushed.
 func (p *printer) print(text string) {
-	p.output = append(p.output, text...)
+	p.builder.AddOutput(text)
+	p.out.WriteString(text)
 }
 
 func (p *printer) println(text string) {
-	p.output = append(p.output, (text + "\n")...)
+	p.print(text)
+	p.print("\n")
 }
 
 func (p *printer) printInternalImports(importSpecifier string) {
@@ -67,10 +88,23 @@ func (p *printer) printInternalImports(importSpecifier string) {
 	p.print("spreadAttributes as " + SPREAD_ATTRIBUTES + ",\n  ")
 	p.print("defineStyleVars as " + DEFINE_STYLE_VARS + ",\n  ")
 	p.print("defineScriptVars as " + DEFINE_SCRIPT_VARS + ",\n  ")
+	if p.needsClassList {
+		p.print("classList as " + CLASS_LIST + ",\n  ")
+	}
+	if p.needsSerializeStyle {
+		p.print("serializeStyle as " + SERIALIZE_STYLE + ",\n  ")
+	}
 	p.print("createMetadata as " + CREATE_METADATA)
 	p.print("\n} from \"")
 	p.print(importSpecifier)
 	p.print("\";\n")
+	for _, directive := range p.directives {
+		if directive.Export == "" || directive.Export == "default" {
+			p.print(fmt.Sprintf("import %s from \"%s\";\n", directive.LocalName(), directive.Specifier))
+		} else {
+			p.print(fmt.Sprintf("import { %s as %s } from \"%s\";\n", directive.Export, directive.LocalName(), directive.Specifier))
+		}
+	}
 	p.hasInternalImports = true
 }
 
@@ -171,11 +205,36 @@ func (p *printer) printFuncSuffix(opts transform.TransformOptions) {
 
 func (p *printer) printAttributesToObject(n *astro.Node) {
 	p.print("{")
-	for i, a := range n.Attr {
+	hasClassList := transform.HasClassList(n)
+	printedClassList := false
+	i := 0
+	for _, a := range n.Attr {
+		if a.Key == "set:text" || a.Key == "set:html" || a.Key == "is:raw" {
+			continue
+		}
+		if directive, ok := transform.GetDirective(p.opts, a); ok && !directive.SupportsNode(n) {
+			continue
+		}
+		if hasClassList && transform.IsClassAttr(a) {
+			if printedClassList {
+				continue
+			}
+			printedClassList = true
+		}
 		if i != 0 {
 			p.print(",")
 		}
-		if a.Key == "set:text" || a.Key == "set:html" || a.Key == "is:raw" {
+		i++
+		if hasClassList && transform.IsClassAttr(a) {
+			p.addSourceMapping(a.KeyLoc)
+			p.print(`"class":`)
+			p.print(getClassListValue(n))
+			continue
+		}
+		if directive, ok := transform.GetDirective(p.opts, a); ok {
+			p.addSourceMapping(a.KeyLoc)
+			p.print("...")
+			p.printDirectiveCall(directive, a)
 			continue
 		}
 		switch a.Type {
@@ -243,7 +302,11 @@ func (p *printer) printStyleOrScript(opts RenderOptions, n *astro.Node) {
 	if n.FirstChild != nil && strings.TrimSpace(n.FirstChild.Data) != "" {
 		p.print(",children:`")
 		p.addSourceMapping(n.Loc[0])
-		p.print(escapeText(strings.TrimSpace(n.FirstChild.Data)))
+		if n.Data == "style" {
+			p.printStyleContent(n)
+		} else {
+			p.print(escapeText(strings.TrimSpace(n.FirstChild.Data)))
+		}
 		p.addNilSourceMapping()
 		p.print("`")
 	}
@@ -251,8 +314,65 @@ func (p *printer) printStyleOrScript(opts RenderOptions, n *astro.Node) {
 	p.print("},\n")
 }
 
+// printStyleContent prints the trimmed contents of a <style> element. If a
+// preprocessor returned a source map for it, the output is mapped back to the
+// original contents of the element.
+func (p *printer) printStyleContent(n *astro.Node) {
+	code := n.FirstChild.Data
+	if p.doc == nil || p.doc.StyleSourceMaps[n] == "" {
+		p.print(escapeText(strings.TrimSpace(code)))
+		return
+	}
+	sm, err := sourcemap.ParseSourceMap([]byte(p.doc.StyleSourceMaps[n]))
+	if err != nil {
+		p.print(escapeText(strings.TrimSpace(code)))
+		return
+	}
+
+	start := len(code) - len(strings.TrimLeftFunc(code, unicode.IsSpace))
+	end := len(strings.TrimRightFunc(code, unicode.IsSpace))
+	prev := start
+	for _, mapping := range sm.Mappings {
+		offset := getByteOffset(code, mapping.GeneratedLine, mapping.GeneratedColumn)
+		// Splitting `${` would prevent it from being escaped
+		if offset < prev || offset >= end || (offset > 0 && code[offset-1] == '$' && code[offset] == '{') {
+			continue
+		}
+		p.print(escapeText(code[prev:offset]))
+		prev = offset
+		// Mappings into other files, like imported partials, can only point at the element
+		if mapping.SourceIndex == 0 {
+			p.addSourceMapping(p.builder.OffsetLocation(n.FirstChild.Loc[0], mapping.OriginalLine, mapping.OriginalColumn))
+		} else {
+			p.addSourceMapping(n.Loc[0])
+		}
+	}
+	p.print(escapeText(code[prev:end]))
+}
+
+// getByteOffset converts a 0-based line and column of UTF-16 code units in text into a byte offset
+func getByteOffset(text string, line int, column int) int {
+	i := 0
+	for ; line > 0 && i < len(text); i++ {
+		if text[i] == '\n' {
+			line--
+		}
+	}
+	for column > 0 && i < len(text) && text[i] != '\n' {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		// Characters outside the Basic Multilingual Plane are two UTF-16 code units
+		if r > 0xFFFF {
+			column -= 2
+		} else {
+			column--
+		}
+	}
+	return i
+}
+
 func (p *printer) printAttribute(attr astro.Attribute) {
-	if attr.Key == "define:vars" || attr.Key == "set:text" || attr.Key == "set:html" || attr.Key == "is:raw" {
+	if transform.IsCompilerDirective(attr) {
 		return
 	}
 
@@ -276,6 +396,9 @@ func (p *printer) printAttribute(attr astro.Attribute) {
 		p.addSourceMapping(attr.KeyLoc)
 		p.print(attr.Key)
 	case astro.ExpressionAttribute:
+		if attr.Key == "style" && p.printStyleObject(attr) {
+			return
+		}
 		p.print(fmt.Sprintf("${%s(", ADD_ATTRIBUTE))
 		p.addSourceMapping(attr.ValLoc)
 		if strings.TrimSpace(attr.Val) == "" {
@@ -305,12 +428,125 @@ func (p *printer) printAttribute(attr astro.Attribute) {
 	}
 }
 
+// printClassList prints the merged `class` and `class:list` attributes of an element
+func (p *printer) printClassList(n *astro.Node, attr astro.Attribute) {
+	static, dynamic := getClassList(n)
+	if len(dynamic) == 0 {
+		if len(static) == 0 {
+			return
+		}
+		p.print(" ")
+		p.addSourceMapping(attr.KeyLoc)
+		p.print(`class="` + encodeDoubleQuote(strings.Join(static, " ")) + `"`)
+		return
+	}
+	p.print(fmt.Sprintf("${%s(", ADD_ATTRIBUTE))
+	p.addSourceMapping(attr.ValLoc)
+	p.print(getClassListValue(n))
+	p.addSourceMapping(attr.KeyLoc)
+	p.print(`, "class")}`)
+}
+
+// printDirective prints a user-defined directive on an element. The runtime
+// helper returns the attributes to print, with their leading space like addAttribute.
+func (p *printer) printDirective(directive transform.Directive, attr astro.Attribute) {
+	p.print("${")
+	p.printDirectiveCall(directive, attr)
+	p.print("}")
+}
+
+func (p *printer) printDirectiveCall(directive transform.Directive, attr astro.Attribute) {
+	name := attr.Key[len(directive.Namespace)+1:]
+	p.print(fmt.Sprintf(`%s(%s, `, directive.LocalName(), quoteJSString(name)))
+	p.addSourceMapping(attr.ValLoc)
+	switch attr.Type {
+	case astro.QuotedAttribute:
+		p.print(quoteJSString(attr.Val))
+	case astro.EmptyAttribute:
+		p.print("true")
+	case astro.ExpressionAttribute:
+		if strings.TrimSpace(attr.Val) == "" {
+			p.print("(void 0)")
+		} else {
+			p.print(`(` + strings.TrimSpace(attr.Val) + `)`)
+		}
+	case astro.TemplateLiteralAttribute:
+		p.print("`" + strings.TrimSpace(attr.Val) + "`")
+	default:
+		p.print("(void 0)")
+	}
+	p.print(")")
+}
+
+// printStyleObject prints a `style={{ ... }}` object literal attribute. Fully static
+// objects are serialized at compile time, anything else is serialized at runtime.
+// It returns false if the attribute value is not an object literal.
+func (p *printer) printStyleObject(attr astro.Attribute) bool {
+	declarations, ok := getStyleObject([]byte(attr.Val))
+	if !ok {
+		return false
+	}
+	for _, decl := range declarations {
+		if decl.Key != "" {
+			p.validateStyleProperty(decl.Key, attr)
+		}
+	}
+	if isStaticStyle(declarations) {
+		values := make([]string, 0, len(declarations))
+		for _, decl := range declarations {
+			if decl.Empty {
+				continue
+			}
+			values = append(values, decl.Key+":"+decl.Value)
+		}
+		p.print(" ")
+		p.addSourceMapping(attr.KeyLoc)
+		p.print(attr.Key)
+		p.print("=")
+		p.addSourceMapping(attr.ValLoc)
+		p.print(`"` + escapeText(encodeDoubleQuote(strings.Join(values, ";"))) + `"`)
+		return true
+	}
+	p.print(fmt.Sprintf("${%s(%s(", ADD_ATTRIBUTE, SERIALIZE_STYLE))
+	p.addSourceMapping(attr.ValLoc)
+	p.print(strings.TrimSpace(attr.Val))
+	p.addSourceMapping(attr.KeyLoc)
+	p.print(`), "` + attr.Key + `")}`)
+	return true
+}
+
+func (p *printer) validateStyleProperty(property string, attr astro.Attribute) {
+	// Custom properties and vendor-prefixed properties are never validated
+	if strings.HasPrefix(property, "-") {
+		return
+	}
+	if _, ok := css_ast.KnownDeclarations[property]; ok {
+		return
+	}
+	text := fmt.Sprintf("\"%s\" is not a known CSS property.", property)
+	if corrected, ok := css_ast.MaybeCorrectDeclarationTypo(property); ok {
+		text += fmt.Sprintf(" Did you mean \"%s\" instead?", corrected)
+	}
+	p.appendDiagnostic(loc.Diagnostic{
+		Severity: loc.WarningType,
+		Code:     loc.WARNING_UNKNOWN_CSS_PROPERTY,
+		Text:     text,
+		Range:    transform.GetAttrRange(attr),
+	})
+}
+
+func (p *printer) appendDiagnostic(d loc.Diagnostic) {
+	if p.doc != nil {
+		p.doc.AppendDiagnostic(d)
+	}
+}
+
 func (p *printer) addSourceMapping(location loc.Loc) {
-	p.builder.AddSourceMapping(location, p.output)
+	p.builder.AddSourceMapping(location)
 }
 
 func (p *printer) addNilSourceMapping() {
-	p.builder.AddSourceMapping(loc.Loc{Start: 0}, p.output)
+	p.builder.AddSourceMapping(loc.Loc{Start: 0})
 }
 
 func (p *printer) printTopLevelAstro(opts transform.TransformOptions) {
@@ -481,6 +717,19 @@ conly_loop:
 			p.print(fmt.Sprintf("{ type: 'inline', value: `%s` }", escapeInterpolation(escapeBackticks(node.FirstChild.Data))))
 		}
 	}
+	// Declared slots. Dynamic slot names can't be resolved statically.
+	p.print("], slots: [")
+	k := 0
+	for _, slot := range transform.GetDeclaredSlots(doc) {
+		if slot.Dynamic {
+			continue
+		}
+		if k > 0 {
+			p.print(", ")
+		}
+		p.print(fmt.Sprintf("{ name: %s, fallback: %t }", quoteJSString(slot.Name), slot.HasFallback))
+		k++
+	}
 
 	p.print("] });\n\n")
 }
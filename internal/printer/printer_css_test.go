@@ -1,11 +1,13 @@
 package printer
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"testing"
 
 	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/sourcemap"
 	"github.com/withastro/compiler/internal/test_utils"
 	"github.com/withastro/compiler/internal/transform"
 )
@@ -80,3 +82,57 @@ func TestPrinterCSS(t *testing.T) {
 		})
 	}
 }
+
+type scssPreprocessor struct{}
+
+func (scssPreprocessor) Process(ctx context.Context, content string, attrs map[string]string) (string, string, error) {
+	// Maps the `a` and `b` rules to the third and fourth lines of the content
+	return "a {\n  color: red;\n}\nb {\n  color: blue;\n}\n", `{"version":3,"sources":["style.scss"],"mappings":"AAEE;;;AACA"}`, nil
+}
+
+func TestPrinterPreprocessedStyleSourceMap(t *testing.T) {
+	source := `---
+---
+<style lang="scss">
+  $color: red;
+  a { color: $color; }
+  b { color: blue; }
+</style>
+<a>Link</a>`
+	opts := transform.TransformOptions{Scope: "XXXXXX", PreprocessStyle: scssPreprocessor{}}
+	doc, err := astro.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	transform.ExtractStyles(doc)
+	if err := transform.PreprocessStyles(context.Background(), doc, opts); err != nil {
+		t.Fatal(err)
+	}
+	transform.Transform(doc, opts)
+	result := PrintToJS(source, doc, 0, opts)
+	sm, err := sourcemap.ParseSourceMap([]byte(fmt.Sprintf(`{"version":3,"mappings":"%s"}`, result.SourceMapChunk.Buffer)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := string(result.Output)
+	for _, rule := range []string{"a", "b"} {
+		generated := strings.Index(output, rule+".astro-XXXXXX{")
+		if generated == -1 {
+			t.Fatalf("expected the scoped %q rule in the output:\n%s", rule, output)
+		}
+		line := strings.Count(output[:generated], "\n")
+		column := generated - strings.LastIndex(output[:generated], "\n") - 1
+		mapping := sm.Find(line, column)
+		if mapping == nil || mapping.GeneratedColumn != column {
+			t.Fatalf("expected a mapping for the %q rule, got %v", rule, mapping)
+		}
+
+		original := strings.Index(source, "  "+rule+" {") + 2
+		wantLine := strings.Count(source[:original], "\n")
+		wantColumn := original - strings.LastIndex(source[:original], "\n") - 1
+		if mapping.OriginalLine != wantLine || mapping.OriginalColumn != wantColumn {
+			t.Errorf("expected the %q rule to map to %d:%d, got %d:%d", rule, wantLine, wantColumn, mapping.OriginalLine, mapping.OriginalColumn)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package printer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+	"github.com/withastro/compiler/internal/test_utils"
+	"github.com/withastro/compiler/internal/transform"
+)
+
+func TestPrintError(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		err    error
+		want   CompileError
+	}{
+		{
+			name: "parse error",
+			source: `---
+const a = 1;
+---
+< slot="named">foo</>`,
+			want: CompileError{
+				Message: "Unable to assign attributes when using <> Fragment shorthand syntax!",
+				Code:    int(loc.ERROR_PARSE),
+				Location: &DiagnosticLocation{
+					File:   "src/pages/index.astro",
+					Line:   4,
+					Column: 1,
+					Length: 2,
+				},
+				Frame: `  2 | const a = 1;
+  3 | ---
+> 4 | < slot="named">foo</>
+    | ^`,
+			},
+		},
+		{
+			name: "preprocessor error",
+			source: `<div>
+	<style lang="scss">a { color: $missing; }</style>
+</div>`,
+			err: &transform.PreprocessorError{Err: errors.New("Undefined variable"), Element: "style", Loc: loc.Loc{Start: 8}},
+			want: CompileError{
+				Message: "unable to preprocess <style> at offset 8: Undefined variable",
+				Code:    int(loc.ERROR_STYLE_PREPROCESSOR),
+				Location: &DiagnosticLocation{
+					File:   "src/pages/index.astro",
+					Line:   2,
+					Column: 3,
+					Length: 5,
+				},
+				Frame: `  1 | <div>
+> 2 | 	<style lang="scss">a { color: $missing; }</style>
+    | 	 ^
+  3 | </div>`,
+			},
+		},
+		{
+			name:   "error without a location",
+			source: `<div></div>`,
+			err:    errors.New("runtime error: invalid memory address or nil pointer dereference"),
+			want: CompileError{
+				Message: "runtime error: invalid memory address or nil pointer dereference",
+				Code:    int(loc.ERROR),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err
+			if err == nil {
+				_, err = astro.Parse(strings.NewReader(tt.source))
+				if err == nil {
+					t.Fatal("expected a parse error")
+				}
+			}
+			got := PrintError(tt.source, "src/pages/index.astro", err)
+			// Only compare the first line of long messages
+			got.Message = strings.Split(got.Message, "\n")[0]
+			if got.Message != tt.want.Message || got.Code != tt.want.Code {
+				t.Errorf("\nwant: %d %s\ngot:  %d %s", tt.want.Code, tt.want.Message, got.Code, got.Message)
+			}
+			if (got.Location == nil) != (tt.want.Location == nil) || (got.Location != nil && *got.Location != *tt.want.Location) {
+				t.Errorf("\nwant location: %v\ngot location:  %v", tt.want.Location, got.Location)
+			}
+			if diff := test_utils.ANSIDiff(tt.want.Frame, got.Frame); diff != "" {
+				t.Errorf("frame mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package printer
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	astro "github.com/withastro/compiler/internal"
+	types "github.com/withastro/compiler/internal/t"
+)
+
+func TestPrintToJSONExpressionAST(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "identifier",
+			source: `<h1>{title}</h1>`,
+			want:   `{"type":"Identifier","name":"title"}`,
+		},
+		{
+			name:   "element in an arrow function",
+			source: `<ul>{items.map(item => <li>{item}</li>)}</ul>`,
+			want:   `{"type":"CallExpression","callee":{"type":"MemberExpression","object":{"type":"Identifier","name":"items"},"property":{"type":"Identifier","name":"map"},"computed":false,"optional":false},"arguments":[{"type":"ArrowFunctionExpression","id":null,"params":[{"type":"Identifier","name":"item"}],"body":{"type":"AstroChildren","children":[1]},"expression":true,"generator":false,"async":false}],"optional":false}`,
+		},
+		{
+			name:   "elements in a conditional",
+			source: `{cond ? <a/> : 'z'}`,
+			want:   `{"type":"ConditionalExpression","test":{"type":"Identifier","name":"cond"},"consequent":{"type":"AstroChildren","children":[1]},"alternate":{"type":"Literal","value":"z","raw":"'z'"}}`,
+		},
+		{
+			name:   "adjacent elements",
+			source: "{cond && <a/>\n<b/>}",
+			want:   `{"type":"LogicalExpression","operator":"&&","left":{"type":"Identifier","name":"cond"},"right":{"type":"AstroChildren","children":[1,2]}}`,
+		},
+		{
+			name:   "optional chain",
+			source: `{a?.b.c?.(d)}`,
+			want:   `{"type":"ChainExpression","expression":{"type":"CallExpression","callee":{"type":"MemberExpression","object":{"type":"MemberExpression","object":{"type":"Identifier","name":"a"},"property":{"type":"Identifier","name":"b"},"computed":false,"optional":true},"property":{"type":"Identifier","name":"c"},"computed":false,"optional":false},"arguments":[{"type":"Identifier","name":"d"}],"optional":true}}`,
+		},
+		{
+			name:   "trailing line comment",
+			source: "{a // comment\n}",
+			want:   `{"type":"Identifier","name":"a"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := PrintToJSON(tt.source, doc, types.ParseOptions{ExpressionAST: true})
+			if !json.Valid(result.Output) {
+				t.Fatalf("invalid JSON: %s", result.Output)
+			}
+			expression := findASTNode(t, result.Output, "expression")
+			if string(expression.ESTree) != tt.want {
+				t.Errorf("mismatch\nwant: %s\ngot:  %s", tt.want, expression.ESTree)
+			}
+
+			result = PrintToJSON(tt.source, doc, types.ParseOptions{})
+			if strings.Contains(string(result.Output), `"estree"`) {
+				t.Errorf("expected no ESTree without the option, got %s", result.Output)
+			}
+		})
+	}
+}
+
+func TestPrintToJSONExpressionASTPositions(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "element in an arrow function",
+			source: `<ul>{items.map((item) => <li>{item}</li>)}</ul>`,
+			want: []string{
+				"ArrowFunctionExpression (item) => <li>{item}</li>",
+				"AstroChildren <li>{item}</li>",
+				"CallExpression items.map((item) => <li>{item}</li>)",
+				"Identifier item",
+				"Identifier items",
+				"Identifier map",
+				"MemberExpression items.map",
+			},
+		},
+		{
+			name:   "adjacent elements",
+			source: "{cond && <a/>\n<b/> }",
+			want: []string{
+				"AstroChildren <a/>\n<b/>",
+				"Identifier cond",
+				"LogicalExpression cond && <a/>\n<b/>",
+			},
+		},
+		{
+			name:   "template and regular expression",
+			source: "{ `a${b}` + /c/.test(d) }",
+			want: []string{
+				"BinaryExpression `a${b}` + /c/.test(d)",
+				"CallExpression /c/.test(d)",
+				"Identifier b",
+				"Identifier d",
+				"Identifier test",
+				"Literal /c/",
+				"MemberExpression /c/.test",
+				"TemplateElement ",
+				"TemplateElement a",
+				"TemplateLiteral `a${b}`",
+			},
+		},
+		{
+			name:   "parentheses",
+			source: `{(() => { return a })()}`,
+			want: []string{
+				"ArrowFunctionExpression () => { return a }",
+				"CallExpression (() => { return a })()",
+				"Identifier a",
+			},
+		},
+		{
+			name:   "patterns",
+			source: `{({ a, b: [c] = d }) => a}`,
+			want: []string{
+				"ArrayPattern [c]",
+				"ArrowFunctionExpression ({ a, b: [c] = d }) => a",
+				"AssignmentPattern [c] = d",
+				"Identifier a",
+				"Identifier a",
+				"Identifier a",
+				"Identifier b",
+				"Identifier c",
+				"Identifier d",
+				"ObjectPattern { a, b: [c] = d }",
+				"Property a",
+				"Property b: [c] = d",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := PrintToJSON(tt.source, doc, types.ParseOptions{ExpressionAST: true, Position: true})
+			expression := findASTNode(t, result.Output, "expression")
+			var estree interface{}
+			if err := json.Unmarshal(expression.ESTree, &estree); err != nil {
+				t.Fatal(err)
+			}
+			got := estreeRanges(t, tt.source, estree, nil)
+			sort.Strings(got)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPrintToJSONExpressionASTLoc(t *testing.T) {
+	// Columns count UTF-16 code units and offsets count bytes, like the
+	// positions of the other nodes
+	source := "<p>\r\n{a\r\n  + 'é' + b}</p>"
+	doc, err := astro.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := PrintToJSON(source, doc, types.ParseOptions{ExpressionAST: true, Position: true})
+	expression := findASTNode(t, result.Output, "expression")
+	want := `{"type":"Identifier","name":"b","loc":{"start":{"line":3,"column":10},"end":{"line":3,"column":11}},"range":[20,21]}`
+	if !strings.Contains(string(expression.ESTree), want) {
+		t.Errorf("expected %s in %s", want, expression.ESTree)
+	}
+}
+
+// estreeRanges appends the type and source of each node in an ESTree tree
+func estreeRanges(t *testing.T, source string, value interface{}, ranges []string) []string {
+	t.Helper()
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if typ, ok := v["type"].(string); ok {
+			r, ok := v["range"].([]interface{})
+			if !ok {
+				t.Fatalf("expected a range for %v", v)
+			}
+			ranges = append(ranges, typ+" "+source[int(r[0].(float64)):int(r[1].(float64))])
+		}
+		for key, child := range v {
+			if key != "loc" && key != "range" {
+				ranges = estreeRanges(t, source, child, ranges)
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			ranges = estreeRanges(t, source, child, ranges)
+		}
+	}
+	return ranges
+}
+
+func TestPrintToJSONExpressionASTErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{source: `{}`},
+		{source: `{/* comment */}`},
+		{source: `{a +}`, want: "unexpected ) in expression"},
+		{source: `{a; b}`, want: "unexpected ; in expression"},
+	}
+
+	for _, tt := range tests {
+		doc, err := astro.Parse(strings.NewReader(tt.source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := PrintToJSON(tt.source, doc, types.ParseOptions{ExpressionAST: true})
+		expression := findASTNode(t, result.Output, "expression")
+		if expression.ESTree != nil {
+			t.Errorf("%s: expected no ESTree, got %s", tt.source, expression.ESTree)
+		}
+		if expression.ESTreeError != tt.want {
+			t.Errorf("%s: expected the error %q, got %q", tt.source, tt.want, expression.ESTreeError)
+		}
+	}
+}
+
+func TestExpressionESTree(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{
+			code: `"\u{1F600} \uD83D\uDE00 \x41\n"`,
+			want: `{"type":"Literal","value":"😀 😀 A\n","raw":"\"\\u{1F600} \\uD83D\\uDE00 \\x41\\n\""}`,
+		},
+		{
+			code: "`a${b}\\n`",
+			want: `{"type":"TemplateLiteral","quasis":[{"type":"TemplateElement","value":{"raw":"a","cooked":"a"},"tail":false},{"type":"TemplateElement","value":{"raw":"\\n","cooked":"\n"},"tail":true}],"expressions":[{"type":"Identifier","name":"b"}]}`,
+		},
+		{
+			code: `[0x10, 1e3, 10n, /re/g, null]`,
+			want: `{"type":"ArrayExpression","elements":[{"type":"Literal","value":16,"raw":"0x10"},{"type":"Literal","value":1000,"raw":"1e3"},{"type":"Literal","value":null,"bigint":"10","raw":"10n"},{"type":"Literal","value":null,"regex":{"pattern":"re","flags":"g"},"raw":"/re/g"},{"type":"Literal","value":null,"raw":"null"}]}`,
+		},
+		{
+			code: `({ a, [b]: 1, get c() { return this.d }, ...e })`,
+			want: `{"type":"ObjectExpression","properties":[{"type":"Property","key":{"type":"Identifier","name":"a"},"computed":false,"value":{"type":"Identifier","name":"a"},"kind":"init","method":false,"shorthand":true},{"type":"Property","key":{"type":"Identifier","name":"b"},"computed":true,"value":{"type":"Literal","value":1,"raw":"1"},"kind":"init","method":false,"shorthand":false},{"type":"Property","key":{"type":"Identifier","name":"c"},"computed":false,"value":{"type":"FunctionExpression","id":null,"params":[],"body":{"type":"BlockStatement","body":[{"type":"ReturnStatement","argument":{"type":"MemberExpression","object":{"type":"ThisExpression"},"property":{"type":"Identifier","name":"d"},"computed":false,"optional":false}}]},"expression":false,"generator":false,"async":false},"kind":"get","method":false,"shorthand":false},{"type":"SpreadElement","argument":{"type":"Identifier","name":"e"}}]}`,
+		},
+		{
+			code: `[a, , ...b] = c`,
+			want: `{"type":"AssignmentExpression","operator":"=","left":{"type":"ArrayPattern","elements":[{"type":"Identifier","name":"a"},null,{"type":"RestElement","argument":{"type":"Identifier","name":"b"}}]},"right":{"type":"Identifier","name":"c"}}`,
+		},
+		{
+			code: `async ({ a = 1 }, ...b) => { for (const c of b) await c }`,
+			want: `{"type":"ArrowFunctionExpression","id":null,"params":[{"type":"ObjectPattern","properties":[{"type":"Property","key":{"type":"Identifier","name":"a"},"computed":false,"value":{"type":"AssignmentPattern","left":{"type":"Identifier","name":"a"},"right":{"type":"Literal","value":1,"raw":"1"}},"kind":"init","method":false,"shorthand":true}]},{"type":"RestElement","argument":{"type":"Identifier","name":"b"}}],"body":{"type":"BlockStatement","body":[{"type":"ForOfStatement","await":false,"left":{"type":"VariableDeclaration","declarations":[{"type":"VariableDeclarator","id":{"type":"Identifier","name":"c"},"init":null}],"kind":"const"},"right":{"type":"Identifier","name":"b"},"body":{"type":"BlockStatement","body":[{"type":"ExpressionStatement","expression":{"type":"AwaitExpression","argument":{"type":"Identifier","name":"c"}}}]}}]},"expression":false,"generator":false,"async":true}`,
+		},
+		{
+			code: `new Foo(...args, import.meta.url)`,
+			want: `{"type":"NewExpression","callee":{"type":"Identifier","name":"Foo"},"arguments":[{"type":"SpreadElement","argument":{"type":"Identifier","name":"args"}},{"type":"MemberExpression","object":{"type":"MetaProperty","meta":{"type":"Identifier","name":"import"},"property":{"type":"Identifier","name":"meta"}},"property":{"type":"Identifier","name":"url"},"computed":false,"optional":false}]}`,
+		},
+		{
+			code: `['\101\0\08\400\8', "\1a\377"]`,
+			want: `{"type":"ArrayExpression","elements":[{"type":"Literal","value":"A\u0000\u00008 08","raw":"'\\101\\0\\08\\400\\8'"},{"type":"Literal","value":"\u0001aÿ","raw":"\"\\1a\\377\""}]}`,
+		},
+		{
+			code: `class A extends B { constructor(a) { super(a) } static get b() { return 1 } ['c']() {} }`,
+			want: `{"type":"ClassExpression","id":{"type":"Identifier","name":"A"},"superClass":{"type":"Identifier","name":"B"},"body":{"type":"ClassBody","body":[{"type":"MethodDefinition","key":{"type":"Identifier","name":"constructor"},"computed":false,"value":{"type":"FunctionExpression","id":null,"params":[{"type":"Identifier","name":"a"}],"body":{"type":"BlockStatement","body":[{"type":"ExpressionStatement","expression":{"type":"CallExpression","callee":{"type":"Super"},"arguments":[{"type":"Identifier","name":"a"}],"optional":false}}]},"expression":false,"generator":false,"async":false},"kind":"constructor","static":false},{"type":"MethodDefinition","key":{"type":"Identifier","name":"b"},"computed":false,"value":{"type":"FunctionExpression","id":null,"params":[],"body":{"type":"BlockStatement","body":[{"type":"ReturnStatement","argument":{"type":"Literal","value":1,"raw":"1"}}]},"expression":false,"generator":false,"async":false},"kind":"get","static":true},{"type":"MethodDefinition","key":{"type":"Literal","value":"c","raw":"'c'"},"computed":true,"value":{"type":"FunctionExpression","id":null,"params":[],"body":{"type":"BlockStatement","body":[]},"expression":false,"generator":false,"async":false},"kind":"method","static":false}]}}`,
+		},
+		{
+			code: `class {}`,
+			want: `{"type":"ClassExpression","id":null,"superClass":null,"body":{"type":"ClassBody","body":[]}}`,
+		},
+		{
+			code: `({ a: { b: [c = 1, ...d] }, ...e } = f)`,
+			want: `{"type":"AssignmentExpression","operator":"=","left":{"type":"ObjectPattern","properties":[{"type":"Property","key":{"type":"Identifier","name":"a"},"computed":false,"value":{"type":"ObjectPattern","properties":[{"type":"Property","key":{"type":"Identifier","name":"b"},"computed":false,"value":{"type":"ArrayPattern","elements":[{"type":"AssignmentPattern","left":{"type":"Identifier","name":"c"},"right":{"type":"Literal","value":1,"raw":"1"}},{"type":"RestElement","argument":{"type":"Identifier","name":"d"}}]},"kind":"init","method":false,"shorthand":false}]},"kind":"init","method":false,"shorthand":false},{"type":"RestElement","argument":{"type":"Identifier","name":"e"}}]},"right":{"type":"Identifier","name":"f"}}`,
+		},
+		{
+			code: "tag`a${b}c${d + `e${f}`}`",
+			want: `{"type":"TaggedTemplateExpression","tag":{"type":"Identifier","name":"tag"},"quasi":{"type":"TemplateLiteral","quasis":[{"type":"TemplateElement","value":{"raw":"a","cooked":"a"},"tail":false},{"type":"TemplateElement","value":{"raw":"c","cooked":"c"},"tail":false},{"type":"TemplateElement","value":{"raw":"","cooked":""},"tail":true}],"expressions":[{"type":"Identifier","name":"b"},{"type":"BinaryExpression","operator":"+","left":{"type":"Identifier","name":"d"},"right":{"type":"TemplateLiteral","quasis":[{"type":"TemplateElement","value":{"raw":"e","cooked":"e"},"tail":false},{"type":"TemplateElement","value":{"raw":"","cooked":""},"tail":true}],"expressions":[{"type":"Identifier","name":"f"}]}}]}}`,
+		},
+		{
+			code: `({ "a-b": 1, 2: c, [d]() {}, async *e() {} })`,
+			want: `{"type":"ObjectExpression","properties":[{"type":"Property","key":{"type":"Literal","value":"a-b","raw":"\"a-b\""},"computed":false,"value":{"type":"Literal","value":1,"raw":"1"},"kind":"init","method":false,"shorthand":false},{"type":"Property","key":{"type":"Literal","value":2,"raw":"2"},"computed":false,"value":{"type":"Identifier","name":"c"},"kind":"init","method":false,"shorthand":false},{"type":"Property","key":{"type":"Identifier","name":"d"},"computed":true,"value":{"type":"FunctionExpression","id":null,"params":[],"body":{"type":"BlockStatement","body":[]},"expression":false,"generator":false,"async":false},"kind":"init","method":true,"shorthand":false},{"type":"Property","key":{"type":"Identifier","name":"e"},"computed":false,"value":{"type":"FunctionExpression","id":null,"params":[],"body":{"type":"BlockStatement","body":[]},"expression":false,"generator":true,"async":true},"kind":"init","method":true,"shorthand":false}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := expressionESTree([]*astro.Node{{Type: astro.TextNode, Data: tt.code}}, nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.code, err)
+			continue
+		}
+		if !json.Valid(got) {
+			t.Errorf("%s: invalid JSON: %s", tt.code, got)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: mismatch\nwant: %s\ngot:  %s", tt.code, tt.want, got)
+		}
+	}
+}
+
+// findASTNode returns the first node of the given type in the printed AST
+func findASTNode(t *testing.T, output []byte, typ string) ASTNode {
+	t.Helper()
+	var root ASTNode
+	if err := json.Unmarshal(output, &root); err != nil {
+		t.Fatal(err)
+	}
+	nodes := []ASTNode{root}
+	for len(nodes) > 0 {
+		node := nodes[0]
+		nodes = append(nodes[1:], node.Children...)
+		if node.Type == typ {
+			return node
+		}
+	}
+	t.Fatalf("expected a %s node in %s", typ, output)
+	return ASTNode{}
+}
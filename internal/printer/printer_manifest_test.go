@@ -0,0 +1,106 @@
+package printer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/test_utils"
+	"github.com/withastro/compiler/internal/transform"
+)
+
+func TestPrintManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		pathname string
+		want     ComponentManifest
+	}{
+		{
+			name:     "empty",
+			source:   `<div />`,
+			pathname: "",
+			want: ComponentManifest{
+				Name:                 "Component",
+				Props:                []ManifestProp{},
+				Slots:                []ManifestSlot{},
+				PassedSlots:          []ManifestPassedSlot{},
+				HydratedComponents:   []ManifestComponent{},
+				ClientOnlyComponents: []ManifestComponent{},
+				Scripts:              []ManifestScript{},
+			},
+		},
+		{
+			name: "full",
+			source: `---
+import Counter from '../components/Counter.jsx';
+import * as components from '../components';
+import Layout from '../layouts/Layout.astro';
+export interface Props {
+	/** The card title */
+	title: string;
+	count?: number;
+	onSelect(id: string): void;
+}
+---
+<div class="card">
+	<h2>{Astro.props.title}</h2>
+	<slot />
+	<slot name="footer"><p>Default footer</p></slot>
+	<Counter client:visible />
+	<components.Chart client:only="react" />
+	<my-element client:idle />
+	<Layout><h1 slot="title">Title</h1><p slot={name}>Text</p><p>Default</p></Layout>
+</div>
+<script src="/analytics.js"></script>
+<script>console.log("card")</script>
+<style>.card { color: red; }</style>`,
+			pathname: "/src/components/ProductCard.astro",
+			want: ComponentManifest{
+				Name: "ProductCard",
+				Props: []ManifestProp{
+					{Name: "title", Type: "string", Description: "The card title"},
+					{Name: "count", Type: "number", Optional: true},
+					{Name: "onSelect", Type: "(id: string) => void"},
+				},
+				Slots: []ManifestSlot{
+					{Name: "default"},
+					{Name: "footer", Fallback: true},
+				},
+				PassedSlots: []ManifestPassedSlot{
+					{Component: "Layout", Specifier: "../layouts/Layout.astro", Name: "title"},
+					{Component: "Layout", Specifier: "../layouts/Layout.astro", Name: "name", Dynamic: true},
+				},
+				HydratedComponents: []ManifestComponent{
+					{Name: "my-element", Directive: "idle"},
+					{Name: "Counter", Specifier: "../components/Counter.jsx", Export: "default", Directive: "visible"},
+				},
+				ClientOnlyComponents: []ManifestComponent{
+					{Name: "components.Chart", Specifier: "../components", Export: "Chart", Directive: "only"},
+				},
+				Scripts: []ManifestScript{
+					{Type: "inline", Code: `console.log("card")`},
+					{Type: "external", Src: "/analytics.js"},
+				},
+				StyleCount: 1,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			opts := transform.TransformOptions{Scope: "XXXX", Pathname: tt.pathname}
+			transform.ExtractStyles(doc)
+			transform.Transform(doc, opts)
+			got := PrintManifest(doc, opts)
+			if diff := test_utils.ANSIDiff(tt.want, got); diff != "" {
+				t.Error(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
+			}
+		})
+	}
+}
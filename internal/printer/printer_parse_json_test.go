@@ -0,0 +1,69 @@
+package printer
+
+import (
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+	types "github.com/withastro/compiler/internal/t"
+)
+
+func TestParseJSON(t *testing.T) {
+	sources := []string{
+		`<h1>Hello world!</h1>`,
+		"---\nimport Layout from '../layouts/Layout.astro';\nconst { title } = Astro.props;\n---\n<Layout title={title}>\n\t<main>{title}</main>\n</Layout>",
+		"<!DOCTYPE html>\n<html lang=\"en\">\n<head><title>Page</title><meta charset=\"utf-8\"></head>\n<body><!-- comment --><p>a &amp; b</p><br><img src=\"x.png\"></body>\n</html>",
+		"<Component client:load {...props} a={1} b=`two ${2}` {shorthand} c d=\"it's\" e='say \"hi\"' f=\"both ' &quot;\"><Fragment slot=\"x\">y</Fragment><>z</></Component>",
+		`<ul>{items.map(item => <li class={item.class}>{item.name ? <b>{item.name}</b> : "none"}</li>)}</ul>`,
+		`<custom-element><svg><use xlink:href="#icon" /></svg></custom-element>`,
+		`<script>const a = "<div>" < 1;</script><style>p { color: red; }</style>`,
+	}
+	for _, source := range sources {
+		doc, err := astro.Parse(strings.NewReader(source))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast := PrintToJSON(source, doc, types.ParseOptions{Position: true}).Output
+
+		rebuilt, err := ParseJSON(ast)
+		if err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		if again := PrintToJSON(source, rebuilt, types.ParseOptions{Position: true}).Output; string(again) != string(ast) {
+			t.Errorf("expected the rebuilt tree to print the same AST\nwant: %s\ngot:  %s", ast, again)
+		}
+
+		// The printed source should parse to the same tree
+		var b strings.Builder
+		astro.PrintToSource(&b, rebuilt)
+		printed, err := astro.Parse(strings.NewReader(b.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := PrintToJSON(source, doc, types.ParseOptions{}).Output
+		if got := PrintToJSON(b.String(), printed, types.ParseOptions{}).Output; string(got) != string(want) {
+			t.Errorf("expected the printed source to have the same AST\nsource: %s\nwant: %s\ngot:  %s", b.String(), want, got)
+		}
+	}
+}
+
+func TestParseJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		ast  string
+		want string
+	}{
+		{"invalid JSON", `{"type":`, "unexpected end of JSON input"},
+		{"not a root", `{"type":"element","name":"div"}`, `expected a root node, got "element"`},
+		{"unknown node", `{"type":"root","children":[{"type":"widget"}]}`, `unexpected "widget" node`},
+		{"unknown attribute kind", `{"type":"root","children":[{"type":"element","name":"a","attributes":[{"type":"attribute","kind":"odd","name":"href","value":""}]}]}`, `unknown kind of attribute "odd"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseJSON([]byte(tt.ast))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("expected the error %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package printer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/test_utils"
+	"github.com/withastro/compiler/internal/transform"
+)
+
+func TestPrintPropsSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+		ok     bool
+	}{
+		{
+			name:   "no props",
+			source: "---\nconst a = 1;\n---\n<div />",
+			ok:     false,
+		},
+		{
+			name: "primitives",
+			source: `---
+export interface Props {
+	title: string;
+	count?: number;
+	featured: boolean;
+	subtitle: string | undefined;
+}
+---`,
+			want: `{"$schema":"http://json-schema.org/draft-07/schema#","title":"Card","type":"object","properties":{"title":{"type":"string"},"count":{"type":"number"},"featured":{"type":"boolean"},"subtitle":{"type":"string"}},"required":["title","featured"]}`,
+			ok:   true,
+		},
+		{
+			name: "unions",
+			source: `---
+type Props = {
+	size: 'sm' | 'md' | 'lg';
+	level: 1 | 2 | 3;
+	value: string | number | null;
+}
+---`,
+			want: `{"$schema":"http://json-schema.org/draft-07/schema#","title":"Card","type":"object","properties":{"size":{"type":"string","enum":["sm","md","lg"]},"level":{"type":"number","enum":[1,2,3]},"value":{"anyOf":[{"type":"string"},{"type":"number"},{"type":"null"}]}},"required":["size","level","value"]}`,
+			ok:   true,
+		},
+		{
+			name: "arrays and objects",
+			source: `---
+export interface Props {
+	tags: string[];
+	links: Array<{ href: string; label?: string }>;
+	meta: Record<string, string>;
+	author: { name: string; avatar?: Image };
+}
+---`,
+			want: `{"$schema":"http://json-schema.org/draft-07/schema#","title":"Card","type":"object","properties":{"tags":{"type":"array","items":{"type":"string"}},"links":{"type":"array","items":{"type":"object","properties":{"href":{"type":"string"},"label":{"type":"string"}},"required":["href"]}},"meta":{"type":"object","additionalProperties":{"type":"string"}},"author":{"type":"object","properties":{"name":{"type":"string"},"avatar":{}},"required":["name"]}},"required":["tags","links","meta","author"]}`,
+			ok:   true,
+		},
+		{
+			name: "jsdoc",
+			source: `---
+export interface Props {
+	/** The heading shown above the <img> */
+	heading: string;
+	/**
+	 * How many columns to render
+	 * @default 3
+	 */
+	columns?: number;
+	/** @default 'left' */
+	align?: 'left' | 'right';
+	onClick(): void;
+}
+---`,
+			want: `{"$schema":"http://json-schema.org/draft-07/schema#","title":"Card","type":"object","properties":{"heading":{"description":"The heading shown above the <img>","type":"string"},"columns":{"description":"How many columns to render","type":"number","default":3},"align":{"type":"string","enum":["left","right"],"default":"left"}},"required":["heading"]}`,
+			ok:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			opts := transform.TransformOptions{Pathname: "/src/components/Card.astro"}
+			transform.Transform(doc, opts)
+			got, ok := PrintPropsSchema(doc, opts)
+			if ok != tt.ok {
+				t.Fatalf("expected ok to be %v, got %v", tt.ok, ok)
+			}
+			if diff := test_utils.ANSIDiff(tt.want, string(got)); diff != "" {
+				t.Error(fmt.Sprintf("mismatch (-want +got):\n%s", diff))
+			}
+		})
+	}
+}
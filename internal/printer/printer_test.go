@@ -1,6 +1,7 @@
 package printer
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"regexp"
@@ -14,7 +15,13 @@ import (
 	"github.com/withastro/compiler/internal/transform"
 )
 
-var INTERNAL_IMPORTS = fmt.Sprintf("import {\n  %s\n} from \"%s\";\n", strings.Join([]string{
+// internalImports returns the internal imports of a component that uses helpers,
+// which are only imported when the code needs them
+func internalImports(helpers ...string) string {
+	return fmt.Sprintf("import {\n  %s\n} from \"%s\";\n", strings.Join(append(append(INTERNAL_HELPERS, helpers...), "createMetadata as "+CREATE_METADATA), ",\n  "), "http://localhost:3000/")
+}
+
+var INTERNAL_HELPERS = []string{
 	FRAGMENT,
 	"render as " + TEMPLATE_TAG,
 	"createAstro as " + CREATE_ASTRO,
@@ -26,8 +33,8 @@ var INTERNAL_IMPORTS = fmt.Sprintf("import {\n  %s\n} from \"%s\";\n", strings.J
 	"spreadAttributes as " + SPREAD_ATTRIBUTES,
 	"defineStyleVars as " + DEFINE_STYLE_VARS,
 	"defineScriptVars as " + DEFINE_SCRIPT_VARS,
-	"createMetadata as " + CREATE_METADATA,
-}, ",\n  "), "http://localhost:3000/")
+}
+var INTERNAL_IMPORTS = internalImports()
 var PRELUDE = fmt.Sprintf(`//@ts-ignore
 const $$Component = %s(async ($$result, $$props, %s) => {
 const Astro = $$result.createAstro($$Astro, $$props, %s);
@@ -62,6 +69,7 @@ type metadata struct {
 	clientOnlyComponents []string
 	modules              []string
 	hydrationDirectives  []string
+	slots                []string
 }
 
 type testcase struct {
@@ -69,6 +77,7 @@ type testcase struct {
 	source           string
 	only             bool
 	staticExtraction bool
+	directives       []transform.Directive
 	want             want
 }
 
@@ -103,14 +112,16 @@ func TestPrinter(t *testing.T) {
 			name:   "head slot",
 			source: `<html><head><slot /></html>`,
 			want: want{
-				code: `<html><head>${$$renderSlot($$result,$$slots["default"])}` + RENDER_HEAD_RESULT + `</head></html>`,
+				metadata: metadata{slots: []string{`{ name: "default", fallback: false }`}},
+				code:     `<html><head>${$$renderSlot($$result,$$slots["default"])}` + RENDER_HEAD_RESULT + `</head></html>`,
 			},
 		},
 		{
 			name:   "head slot II",
 			source: `<html><head><slot /></head><body class="a"></body></html>`,
 			want: want{
-				code: `<html><head>${$$renderSlot($$result,$$slots["default"])}` + RENDER_HEAD_RESULT + `</head><body class="a"></body></html>`,
+				metadata: metadata{slots: []string{`{ name: "default", fallback: false }`}},
+				code:     `<html><head>${$$renderSlot($$result,$$slots["default"])}` + RENDER_HEAD_RESULT + `</head><body class="a"></body></html>`,
 			},
 		},
 		{
@@ -919,6 +930,263 @@ import Widget2 from '../components/Widget2.astro';`},
 				code:        `${$$renderComponent($$result,'Component',Component,{},{[name]: () => $$render` + "`" + `<div>Named</div>` + "`" + `,})}`,
 			},
 		},
+		{
+			name: "slots (template literal name)",
+			source: `---
+		import Component from 'test';
+		const name = 'named';
+		---
+		<Component>
+			<div slot=` + BACKTICK + `${name}-slot` + BACKTICK + `>Named</div>
+		</Component>`,
+			want: want{
+				frontmatter: []string{`import Component from 'test';`, `const name = 'named';`},
+				styles:      []string{},
+				metadata:    metadata{modules: []string{`{ module: $$module1, specifier: 'test', assert: {} }`}},
+				code:        `${$$renderComponent($$result,'Component',Component,{},{[` + BACKTICK + `${name}-slot` + BACKTICK + `]: () => $$render` + BACKTICK + `<div>Named</div>` + BACKTICK + `,})}`,
+			},
+		},
+		{
+			name:   "slots (shorthand name)",
+			source: `<Component><div {slot}>Named</div></Component>`,
+			want: want{
+				code: `${$$renderComponent($$result,'Component',Component,{},{[slot]: () => $$render` + BACKTICK + `<div>Named</div>` + BACKTICK + `,})}`,
+			},
+		},
+		{
+			name:   "slot with expression name",
+			source: `<slot name={Astro.props.slot} />`,
+			want: want{
+				code: `${$$renderSlot($$result,$$slots[Astro.props.slot])}`,
+			},
+		},
+		{
+			name:   "slot with template literal name",
+			source: "<main><slot name=`${prefix}-title`><h1>Fallback</h1></slot></main>",
+			want: want{
+				code: "<main>${$$renderSlot($$result,$$slots[`${prefix}-title`],$$render`<h1>Fallback</h1>`)}</main>",
+			},
+		},
+		{
+			name:   "slot with shorthand name",
+			source: `<slot {name} />`,
+			want: want{
+				code: `${$$renderSlot($$result,$$slots[name])}`,
+			},
+		},
+		{
+			name:   "slot names with quotes",
+			source: `<main><slot name='say "hi"' /></main><Component><div slot='a"b'>A</div></Component>`,
+			want: want{
+				code:     `<main>${$$renderSlot($$result,$$slots["say \"hi\""])}</main>${$$renderComponent($$result,'Component',Component,{},{"a\"b": () => $$render` + BACKTICK + `<div>A</div>` + BACKTICK + `,})}`,
+				metadata: metadata{slots: []string{`{ name: "say \"hi\"", fallback: false }`}},
+			},
+		},
+		{
+			name:   "slot forwarding",
+			source: `<Component><slot name="a" slot="b" /><slot slot="c"><p>Fallback</p></slot></Component>`,
+			want: want{
+				code:     `${$$renderComponent($$result,'Component',Component,{},{"b": () => $$render` + BACKTICK + `${$$renderSlot($$result,$$slots["a"])}` + BACKTICK + `,"c": () => $$render` + BACKTICK + `${$$renderSlot($$result,$$slots["default"],$$render` + BACKTICK + `<p>Fallback</p>` + BACKTICK + `)}` + BACKTICK + `,})}`,
+				metadata: metadata{slots: []string{`{ name: "a", fallback: false }`, `{ name: "default", fallback: true }`}},
+			},
+		},
+		{
+			name:   "slots metadata",
+			source: `<header><slot name="title"><h1>Untitled</h1></slot></header><main><slot /></main><slot name="title" /><slot name={dynamic} />`,
+			want: want{
+				code:     `<header>${$$renderSlot($$result,$$slots["title"],$$render` + BACKTICK + `<h1>Untitled</h1>` + BACKTICK + `)}</header><main>${$$renderSlot($$result,$$slots["default"])}</main>${$$renderSlot($$result,$$slots["title"])}${$$renderSlot($$result,$$slots[dynamic])}`,
+				metadata: metadata{slots: []string{`{ name: "title", fallback: true }`, `{ name: "default", fallback: false }`}},
+			},
+		},
+		{
+			name:   "class:list static",
+			source: `<div class:list={['a', "b", ` + BACKTICK + `c` + BACKTICK + `, ['d', 'a']]} />`,
+			want: want{
+				code: `<div class="a b c d"></div>`,
+			},
+		},
+		{
+			name:   "class:list dynamic",
+			source: `<div class:list={['a', { b: isB }, cond && 'c']} />`,
+			want: want{
+				code: `<div${$$addAttribute($$classList(["a", { b: isB }, cond && 'c']), "class")}></div>`,
+			},
+		},
+		{
+			name:   "class:list identifier",
+			source: `<div class:list={classes} />`,
+			want: want{
+				code: `<div${$$addAttribute($$classList([classes]), "class")}></div>`,
+			},
+		},
+		{
+			name:   "class:list merged with class",
+			source: `<div id="a" class="a b" class:list={['b', c]} />`,
+			want: want{
+				code: `<div id="a"${$$addAttribute($$classList(["a b", c]), "class")}></div>`,
+			},
+		},
+		{
+			name:   "class:list merged with quoted class",
+			source: `<div class='a"b x\y' class:list={[c]} />`,
+			want: want{
+				code: `<div${$$addAttribute($$classList(["a\"b x\\y", c]), "class")}></div>`,
+			},
+		},
+		{
+			name:   "class:list merged with class expression",
+			source: `<div class:list={['a']} class={b} />`,
+			want: want{
+				code: `<div${$$addAttribute($$classList(["a", b]), "class")}></div>`,
+			},
+		},
+		{
+			name:   "class:list on component",
+			source: `<Component a="b" class:list={['a', { b: isB }]} />`,
+			want: want{
+				code: `${$$renderComponent($$result,'Component',Component,{"a":"b","class":$$classList(["a", { b: isB }])})}`,
+			},
+		},
+		{
+			name:   "class:list on component static",
+			source: `<Component class:list={['a', 'b']} />`,
+			want: want{
+				code: `${$$renderComponent($$result,'Component',Component,{"class":"a b"})}`,
+			},
+		},
+		{
+			name:   "class:list scoped",
+			source: `<style>div{color:red}</style><div class:list={['a', cond && 'b']} /><span class:list={['a']} />`,
+			want: want{
+				code:   `<div${$$addAttribute($$classList(["a astro-K3BC6HNZ", cond && 'b']), "class")}></div><span class="a astro-K3BC6HNZ"></span>`,
+				styles: []string{"{props:{\"data-astro-id\":\"K3BC6HNZ\"},children:`div.astro-K3BC6HNZ{color:red}`}"},
+			},
+		},
+		{
+			name:   "style object static",
+			source: `<div style={{ color: 'red', fontSize: "12px", 'line-height': 1.5, WebkitTransition: 'none', msTransform: 'none', "--my-var": 'a"b' }} />`,
+			want: want{
+				code: `<div style="color:red;font-size:12px;line-height:1.5;-webkit-transition:none;-ms-transform:none;--my-var:a&quot;b"></div>`,
+			},
+		},
+		{
+			name:   "style object numbers",
+			source: `<div style={{ width: 10, marginTop: -2.5, padding: 0, zIndex: 2, opacity: .5, WebkitFlexGrow: 1, '--size': 3, height: Infinity }} />`,
+			want: want{
+				code: `<div${$$addAttribute($$serializeStyle({ width: 10, marginTop: -2.5, padding: 0, zIndex: 2, opacity: .5, WebkitFlexGrow: 1, '--size': 3, height: Infinity }), "style")}></div>`,
+			},
+		},
+		{
+			name:   "style object static numbers",
+			source: `<div style={{ width: 10, marginTop: -2.5, padding: 0, zIndex: 2, opacity: .5, WebkitFlexGrow: 1, '--size': 3 }} />`,
+			want: want{
+				code: `<div style="width:10px;margin-top:-2.5px;padding:0;z-index:2;opacity:.5;-webkit-flex-grow:1;--size:3"></div>`,
+			},
+		},
+		{
+			name:   "style object static escapes",
+			source: "<div style={{ content: '`${x}`' }} />",
+			want: want{
+				code: "<div style=\"content:\\`\\${x}\\`\"></div>",
+			},
+		},
+		{
+			name:   "style object empty values",
+			source: `<div style={{ color: '', margin: 0 }} />`,
+			want: want{
+				code: `<div style="margin:0"></div>`,
+			},
+		},
+		{
+			name:   "style object negative infinity",
+			source: `<div style={{ margin: -Infinity }} />`,
+			want: want{
+				code: `<div${$$addAttribute($$serializeStyle({ margin: -Infinity }), "style")}></div>`,
+			},
+		},
+		{
+			name:   "style object dynamic",
+			source: `<div style={{ color: 'red', fontSize: size + 'px' }} />`,
+			want: want{
+				code: `<div${$$addAttribute($$serializeStyle({ color: 'red', fontSize: size + 'px' }), "style")}></div>`,
+			},
+		},
+		{
+			name:   "style object spread",
+			source: `<div style={{ ...base, color }} />`,
+			want: want{
+				code: `<div${$$addAttribute($$serializeStyle({ ...base, color }), "style")}></div>`,
+			},
+		},
+		{
+			name:   "style expression",
+			source: `<div style={styles} />`,
+			want: want{
+				code: `<div${$$addAttribute(styles, "style")}></div>`,
+			},
+		},
+		{
+			name:   "style object on component",
+			source: `<Component style={{ color: 'red' }} />`,
+			want: want{
+				code: `${$$renderComponent($$result,'Component',Component,{"style":({ color: 'red' })})}`,
+			},
+		},
+		{
+			name:   "custom directive on element",
+			source: `<button analytics:track="click" tooltip:text={label} disabled>Buy</button>`,
+			directives: []transform.Directive{
+				{Namespace: "analytics", Specifier: "@company/analytics", Target: transform.ElementTarget | transform.ComponentTarget},
+				{Namespace: "tooltip", Specifier: "@company/tooltip", Export: "tooltip", Target: transform.ElementTarget},
+				{Namespace: "unused", Specifier: "@company/unused", Target: transform.ElementTarget},
+			},
+			want: want{
+				frontmatter: []string{"import $$directiveAnalytics from \"@company/analytics\";\nimport { tooltip as $$directiveTooltip } from \"@company/tooltip\";"},
+				code:        `<button${$$directiveAnalytics("track", "click")}${$$directiveTooltip("text", (label))} disabled>Buy</button>`,
+			},
+		},
+		{
+			name:   "custom directive with quotes",
+			source: `<button analytics:track='say "hi"\n'>Buy</button>`,
+			directives: []transform.Directive{
+				{Namespace: "analytics", Specifier: "@company/analytics", Target: transform.ElementTarget},
+			},
+			want: want{
+				frontmatter: []string{"import $$directiveAnalytics from \"@company/analytics\";"},
+				code:        `<button${$$directiveAnalytics("track", "say \"hi\"\\n")}>Buy</button>`,
+			},
+		},
+		{
+			name:   "colliding custom directives",
+			source: `<button foo-bar:a="1" foo_bar:b="2">Buy</button>`,
+			directives: []transform.Directive{
+				{Namespace: "foo-bar", Specifier: "foo-bar", Target: transform.ElementTarget},
+				{Namespace: "foo_bar", Specifier: "foo_bar", Target: transform.ElementTarget},
+			},
+			want: want{
+				frontmatter: []string{"import $$directiveFooBar from \"foo-bar\";"},
+				code:        `<button${$$directiveFooBar("a", "1")} foo_bar:b="2">Buy</button>`,
+			},
+		},
+		{
+			name:   "custom directive on component",
+			source: `<Component a="b" analytics:track="click" tooltip:text="Hi" />`,
+			directives: []transform.Directive{
+				{Namespace: "analytics", Specifier: "@company/analytics", Target: transform.ComponentTarget},
+				{Namespace: "tooltip", Specifier: "@company/tooltip", Target: transform.ElementTarget},
+			},
+			want: want{
+				frontmatter: []string{"import $$directiveAnalytics from \"@company/analytics\";"},
+				code:        `${$$renderComponent($$result,'Component',Component,{"a":"b",...$$directiveAnalytics("track", "click")})}`,
+			},
+		},
+		{
+			name:   "unregistered directive",
+			source: `<button analytics:track="click" x-on:click="open = true">Buy</button>`,
+			want: want{
+				code: `<button analytics:track="click" x-on:click="open = true">Buy</button>`,
+			},
+		},
 		{
 			name:   "condition expressions at the top-level",
 			source: `{cond && <span></span>}{cond && <strong></strong>}`,
@@ -1830,17 +2098,25 @@ const items = ["Dog", "Cat", "Platipus"];
 
 			hash := astro.HashFromSource(code)
 			transform.ExtractStyles(doc)
-			transform.Transform(doc, transform.TransformOptions{Scope: hash}) // note: we want to test Transform in context here, but more advanced cases could be tested separately
+			transform.Transform(doc, transform.TransformOptions{Scope: hash, Directives: tt.directives}) // note: we want to test Transform in context here, but more advanced cases could be tested separately
 			result := PrintToJS(code, doc, 0, transform.TransformOptions{
 				Scope:            "XXXX",
 				Site:             "https://astro.build",
 				InternalURL:      "http://localhost:3000/",
 				ProjectRoot:      ".",
 				StaticExtraction: tt.staticExtraction,
+				Directives:       tt.directives,
 			})
 			output := string(result.Output)
 
-			toMatch := INTERNAL_IMPORTS
+			helpers := []string{}
+			if strings.Contains(tt.want.code, CLASS_LIST+"(") {
+				helpers = append(helpers, "classList as "+CLASS_LIST)
+			}
+			if strings.Contains(tt.want.code, SERIALIZE_STYLE+"(") {
+				helpers = append(helpers, "serializeStyle as "+SERIALIZE_STYLE)
+			}
+			toMatch := internalImports(helpers...)
 			if len(tt.want.frontmatter) > 0 {
 				toMatch += test_utils.Dedent(tt.want.frontmatter[0])
 			}
@@ -1917,6 +2193,17 @@ const items = ["Dog", "Cat", "Platipus"];
 					metadata += h
 				}
 			}
+			metadata += "]"
+			// metadata.slots
+			metadata += ", slots: ["
+			if len(tt.want.metadata.slots) > 0 {
+				for i, s := range tt.want.slots {
+					if i > 0 {
+						metadata += ", "
+					}
+					metadata += s
+				}
+			}
 			metadata += "] }"
 
 			toMatch += "\n\n" + fmt.Sprintf("export const %s = %s(import.meta.url, %s);\n\n", METADATA, CREATE_METADATA, metadata)
@@ -2006,6 +2293,11 @@ func TestPrintToJSON(t *testing.T) {
 			source: `<Fragment>World</Fragment>`,
 			want:   []ASTNode{{Type: "fragment", Name: "Fragment", Children: []ASTNode{{Type: "text", Value: "World"}}}},
 		},
+		{
+			name:   "namespaced attribute",
+			source: `<svg><use xlink:href="#icon" /></svg>`,
+			want:   []ASTNode{{Type: "element", Name: "svg", Children: []ASTNode{{Type: "element", Name: "use", Attributes: []ASTNode{{Type: "attribute", Kind: "quoted", Name: "xlink:href", Value: "#icon"}}}}}},
+		},
 		{
 			name: "Frontmatter",
 			source: `---
@@ -2038,3 +2330,115 @@ const a = "hey"
 		})
 	}
 }
+
+func TestPrintToJSONOutput(t *testing.T) {
+	code := "<div class=\"a\\b\">Hi {name}<!--\"quoted\"\t--></div>"
+	doc, err := astro.Parse(strings.NewReader(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := PrintToJSON(code, doc, types.ParseOptions{Position: true})
+	want := `{"type":"root","children":[{"type":"element","name":"div","attributes":[{"type":"attribute","kind":"quoted","name":"class","value":"a\\b","position":{"start":{"line":1,"column":6,"offset":5}}}],"children":[{"type":"text","value":"Hi ","position":{"start":{"line":1,"column":18,"offset":17}}},{"type":"expression","children":[{"type":"text","value":"name","position":{"start":{"line":1,"column":22,"offset":21}}}],"position":{"start":{"line":1,"column":21,"offset":20},"end":{"line":1,"column":26,"offset":25}}},{"type":"comment","value":"\"quoted\"\t","position":{"start":{"line":1,"column":31,"offset":30}}}],"position":{"start":{"line":1,"column":2,"offset":1},"end":{"line":1,"column":45,"offset":44}}}]}`
+	if string(result.Output) != want {
+		t.Errorf("mismatch\nwant: %s\ngot:  %s", want, result.Output)
+	}
+}
+
+func TestPrintToJSONRoundTrip(t *testing.T) {
+	values := []string{
+		"plain",
+		"quote \" and backslash \\",
+		"newlines \n \r\n and tabs \t",
+		"control \x00 \x01 \b \f \x1f \x7f",
+		"unicode é 𝒳    ",
+		"<script>&amp;</script>",
+	}
+	for _, value := range values {
+		node := ASTNode{
+			Type: "root",
+			Children: []ASTNode{{
+				Type:       "element",
+				Name:       value,
+				Attributes: []ASTNode{{Type: "attribute", Kind: "quoted", Name: value, Value: value}},
+				Children:   []ASTNode{{Type: "text", Value: value, Position: ASTPosition{Start: ASTPoint{Line: 1, Column: 2, Offset: 1}}}},
+			}},
+		}
+		output := node.String()
+		var decoded ASTNode
+		if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+			t.Fatalf("%q: %v\n%s", value, err, output)
+		}
+		if fmt.Sprintf("%+v", decoded) != fmt.Sprintf("%+v", node) {
+			t.Errorf("%q: expected the tree to round trip\nwant: %+v\ngot:  %+v", value, node, decoded)
+		}
+	}
+
+	var decoded ASTNode
+	if err := json.Unmarshal([]byte(ASTNode{Type: "text", Value: "bad \xff utf-8"}.String()), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Value != "bad � utf-8" {
+		t.Errorf("expected invalid UTF-8 to be replaced, got %q", decoded.Value)
+	}
+}
+
+func TestPrintToJSONFrontmatterAST(t *testing.T) {
+	code := `---
+import Layout from '../layouts/Layout.astro';
+import { a, b as c, type D } from './utils'
+import * as ns from 'ns';
+import data from './data.json' assert { type: 'json' };
+import './global.css'
+
+const mod = await import('./dynamic');
+export interface Props { title: string }
+const { title } = Astro.props;
+export { title as default, c };
+export * from './all'
+---
+<h1>{title}</h1>`
+	want := []ASTNode{
+		{Type: "import", Value: "import Layout from '../layouts/Layout.astro';", Source: "../layouts/Layout.astro", Specifiers: []ASTSpecifier{{Kind: "default", Local: "Layout"}}},
+		{Type: "import", Value: "import { a, b as c, type D } from './utils'", Source: "./utils", Specifiers: []ASTSpecifier{{Kind: "named", Imported: "a", Local: "a"}, {Kind: "named", Imported: "b", Local: "c"}, {Kind: "named", Imported: "D", Local: "D", TypeOnly: true}}},
+		{Type: "import", Value: "import * as ns from 'ns';", Source: "ns", Specifiers: []ASTSpecifier{{Kind: "namespace", Local: "ns"}}},
+		{Type: "import", Value: "import data from './data.json' assert { type: 'json' };", Source: "./data.json", Specifiers: []ASTSpecifier{{Kind: "default", Local: "data"}}, Assertions: "{type:'json'}"},
+		{Type: "import", Value: "import './global.css'", Source: "./global.css"},
+		{Type: "code", Value: "const mod = await import('./dynamic');"},
+		{Type: "export", Value: "export interface Props { title: string }", Specifiers: []ASTSpecifier{{Kind: "named", Exported: "Props", Local: "Props"}}, TypeOnly: true},
+		{Type: "code", Value: "const { title } = Astro.props;"},
+		{Type: "export", Value: "export { title as default, c };", Specifiers: []ASTSpecifier{{Kind: "default", Local: "title"}, {Kind: "named", Exported: "c", Local: "c"}}},
+		{Type: "export", Value: "export * from './all'", Source: "./all", Specifiers: []ASTSpecifier{{Kind: "namespace"}}},
+	}
+
+	doc, err := astro.Parse(strings.NewReader(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := PrintToJSON(code, doc, types.ParseOptions{FrontmatterAST: true})
+	var root ASTNode
+	if err := json.Unmarshal(result.Output, &root); err != nil {
+		t.Fatal(err)
+	}
+	frontmatter := root.Children[0]
+	if got := (ASTNode{Type: "root", Children: frontmatter.Children}).String(); got != (ASTNode{Type: "root", Children: want}).String() {
+		t.Errorf("mismatch\nwant: %s\ngot:  %s", ASTNode{Type: "root", Children: want}, got)
+	}
+	if !strings.HasPrefix(frontmatter.Value, "\nimport Layout") {
+		t.Errorf("expected the frontmatter to keep its value, got %q", frontmatter.Value)
+	}
+
+	// Positions are offsets in the whole file
+	result = PrintToJSON(code, doc, types.ParseOptions{FrontmatterAST: true, Position: true})
+	if err := json.Unmarshal(result.Output, &root); err != nil {
+		t.Fatal(err)
+	}
+	for _, child := range root.Children[0].Children {
+		start, end := child.Position.Start.Offset, child.Position.End.Offset
+		if code[start:end] != child.Value {
+			t.Errorf("expected the position of %q to match, got %q", child.Value, code[start:end])
+		}
+	}
+	if pos := root.Children[0].Children[1].Position.Start; pos.Line != 3 || pos.Column != 1 {
+		t.Errorf("expected the second import to start at 3:1, got %d:%d", pos.Line, pos.Column)
+	}
+}
@@ -0,0 +1,60 @@
+package printer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/transform"
+)
+
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestPrintToJSWriter(t *testing.T) {
+	rows := strings.Repeat("<tr><td>{row.name}</td><td class=\"é\">{row.value}</td></tr>\r\n", 5000)
+	source := "---\nconst rows = Astro.props.rows;\n---\n<table>\n" + rows + "</table>"
+	doc, err := astro.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := transform.TransformOptions{Filename: "/src/pages/table.astro"}
+	transform.Transform(doc, opts)
+
+	w := &countingWriter{}
+	chunk, err := PrintToJSWriter(w, source, doc, 0, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := PrintToJS(source, doc, 0, opts)
+	if w.String() != string(result.Output) {
+		t.Error("expected the written code to match PrintToJS")
+	}
+	if fmt.Sprintf("%+v", chunk) != fmt.Sprintf("%+v", result.SourceMapChunk) {
+		t.Error("expected the source map to match PrintToJS")
+	}
+	// The code is written as it's printed, instead of all at once at the end
+	if w.writes < 2 {
+		t.Errorf("expected the code to be written in pieces, got %d writes", w.writes)
+	}
+
+	if _, err := PrintToJSWriter(failingWriter{}, source, doc, 0, opts); err == nil || err.Error() != "disk full" {
+		t.Errorf("expected the write error, got %v", err)
+	}
+}
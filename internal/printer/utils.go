@@ -1,10 +1,17 @@
 package printer
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/iancoleman/strcase"
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/js_scanner"
+	"github.com/withastro/compiler/internal/transform"
 )
 
 func escapeText(src string) string {
@@ -53,3 +60,309 @@ func escapeSingleQuote(str string) string {
 func encodeDoubleQuote(str string) string {
 	return strings.Replace(str, `"`, "&quot;", -1)
 }
+
+// quoteJSString returns str as a double-quoted JS string literal
+func quoteJSString(str string) string {
+	// JSON strings are valid JS strings, and the encoder also escapes U+2028 and U+2029
+	b, _ := json.Marshal(str)
+	return string(b)
+}
+
+// getSlotName returns the JS expression for the slot named by a `name` or
+// `slot` attribute, and whether that expression is a static string literal.
+func getSlotName(a astro.Attribute) (string, bool) {
+	name, dynamic := transform.GetSlotName(a)
+	if dynamic {
+		return name, false
+	}
+	return quoteJSString(name), true
+}
+
+// getClassList merges the `class` and `class:list` attributes of n into
+// static class names, which are deduplicated, and dynamic JS expressions
+// which must be resolved at runtime.
+func getClassList(n *astro.Node) ([]string, []string) {
+	static := make([]string, 0)
+	dynamic := make([]string, 0)
+	seen := make(map[string]bool)
+	addStatic := func(value string) {
+		for _, name := range strings.Fields(value) {
+			if !seen[name] {
+				seen[name] = true
+				static = append(static, name)
+			}
+		}
+	}
+	var addExpression func(expr []byte, isList bool)
+	addExpression = func(expr []byte, isList bool) {
+		if value, ok := js_scanner.GetStaticString(expr); ok {
+			addStatic(value)
+			return
+		}
+		if isList {
+			if items, ok := js_scanner.GetArrayElements(expr); ok {
+				for _, item := range items {
+					addExpression(item, true)
+				}
+				return
+			}
+		}
+		if expr := strings.TrimSpace(string(expr)); expr != "" {
+			dynamic = append(dynamic, expr)
+		}
+	}
+
+	for _, a := range n.Attr {
+		if !transform.IsClassAttr(a) {
+			continue
+		}
+		isList := a.Key == "class:list"
+		switch a.Type {
+		case astro.QuotedAttribute:
+			addStatic(a.Val)
+		case astro.ExpressionAttribute:
+			addExpression([]byte(a.Val), isList)
+		case astro.TemplateLiteralAttribute:
+			addExpression([]byte("`"+a.Val+"`"), isList)
+		case astro.ShorthandAttribute:
+			addExpression([]byte(a.Key), false)
+		}
+	}
+	return static, dynamic
+}
+
+// getClassListValue returns the JS value of the merged `class` and `class:list`
+// attributes of n. Static class names are joined at compile time.
+func getClassListValue(n *astro.Node) string {
+	static, dynamic := getClassList(n)
+	value := quoteJSString(strings.Join(static, " "))
+	if len(dynamic) == 0 {
+		return value
+	}
+	items := dynamic
+	if len(static) > 0 {
+		items = append([]string{value}, dynamic...)
+	}
+	return fmt.Sprintf("%s([%s])", CLASS_LIST, strings.Join(items, ", "))
+}
+
+// needsClassList returns true if the `class:list` directive on n has dynamic
+// parts, which are merged at runtime by the classList helper
+func needsClassList(n *astro.Node) bool {
+	if n.Type != astro.ElementNode || !transform.HasClassList(n) {
+		return false
+	}
+	_, dynamic := getClassList(n)
+	return len(dynamic) > 0
+}
+
+// needsSerializeStyle returns true if n is an element with a `style` object
+// literal that is serialized at runtime by the serializeStyle helper
+func needsSerializeStyle(n *astro.Node) bool {
+	if n.Type != astro.ElementNode || n.Component || n.CustomElement || n.Fragment {
+		return false
+	}
+	for _, attr := range n.Attr {
+		if attr.Key != "style" || attr.Type != astro.ExpressionAttribute {
+			continue
+		}
+		if declarations, ok := getStyleObject([]byte(attr.Val)); ok && !isStaticStyle(declarations) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNode returns true if match returns true for n or any of its descendants
+func hasNode(n *astro.Node, match func(*astro.Node) bool) bool {
+	if match(n) {
+		return true
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if hasNode(c, match) {
+			return true
+		}
+	}
+	return false
+}
+
+type styleDeclaration struct {
+	// Key is the CSS property name, or empty if it can't be resolved statically
+	Key    string
+	Value  string
+	Static bool
+	// Empty is true for empty strings, which are left out like at runtime
+	Empty bool
+}
+
+// getStyleObject returns the declarations of a `style` object literal like
+// `{ color: 'red', fontSize: size + 'px' }`. The second return value is false
+// if the source is not an object literal.
+func getStyleObject(source []byte) ([]styleDeclaration, bool) {
+	properties, ok := js_scanner.GetObjectProperties(source)
+	if !ok {
+		return nil, false
+	}
+	declarations := make([]styleDeclaration, 0, len(properties))
+	for _, property := range properties {
+		decl := styleDeclaration{}
+		key, value, ok := splitObjectProperty(property)
+		if !ok {
+			// Spread, shorthand, computed and method properties are resolved at runtime
+			declarations = append(declarations, decl)
+			continue
+		}
+		decl.Key = toCSSPropertyName(key)
+		if str, ok := js_scanner.GetStaticString(value); ok {
+			decl.Value = strings.TrimSpace(str)
+			decl.Static = true
+			decl.Empty = str == ""
+		} else if isNumericLiteral(value) {
+			decl.Value = string(value)
+			if needsStyleUnit(decl.Key) && decl.Value != "0" {
+				decl.Value += "px"
+			}
+			decl.Static = true
+		}
+		declarations = append(declarations, decl)
+	}
+	return declarations, true
+}
+
+// isStaticStyle returns true if every declaration can be serialized at compile time
+func isStaticStyle(declarations []styleDeclaration) bool {
+	for _, decl := range declarations {
+		if !decl.Static {
+			return false
+		}
+	}
+	return true
+}
+
+// unitlessStyleProperties are the CSS properties that take plain numbers. Numbers
+// used for any other property are in pixels, like in React's `style` prop.
+// Keep this in sync with `serializeStyle` in the runtime.
+var unitlessStyleProperties = map[string]bool{
+	"animation-iteration-count": true,
+	"aspect-ratio":              true,
+	"border-image-outset":       true,
+	"border-image-slice":        true,
+	"border-image-width":        true,
+	"box-flex":                  true,
+	"box-flex-group":            true,
+	"box-ordinal-group":         true,
+	"column-count":              true,
+	"columns":                   true,
+	"fill-opacity":              true,
+	"flex":                      true,
+	"flex-grow":                 true,
+	"flex-negative":             true,
+	"flex-order":                true,
+	"flex-positive":             true,
+	"flex-shrink":               true,
+	"flood-opacity":             true,
+	"font-weight":               true,
+	"grid-area":                 true,
+	"grid-column":               true,
+	"grid-column-end":           true,
+	"grid-column-span":          true,
+	"grid-column-start":         true,
+	"grid-row":                  true,
+	"grid-row-end":              true,
+	"grid-row-span":             true,
+	"grid-row-start":            true,
+	"line-clamp":                true,
+	"line-height":               true,
+	"opacity":                   true,
+	"order":                     true,
+	"orphans":                   true,
+	"scale":                     true,
+	"stop-opacity":              true,
+	"stroke-dasharray":          true,
+	"stroke-dashoffset":         true,
+	"stroke-miterlimit":         true,
+	"stroke-opacity":            true,
+	"stroke-width":              true,
+	"tab-size":                  true,
+	"widows":                    true,
+	"z-index":                   true,
+	"zoom":                      true,
+}
+
+// needsStyleUnit returns true if a number used for property needs a `px` unit.
+// Custom properties are never given a unit.
+func needsStyleUnit(property string) bool {
+	if strings.HasPrefix(property, "--") {
+		return false
+	}
+	for _, prefix := range []string{"-webkit-", "-moz-", "-ms-", "-o-"} {
+		property = strings.TrimPrefix(property, prefix)
+	}
+	return !unitlessStyleProperties[property]
+}
+
+// splitObjectProperty splits a `key: value` object property with a static key
+func splitObjectProperty(property []byte) (string, []byte, bool) {
+	i := bytes.IndexByte(property, ':')
+	if i == -1 {
+		return "", nil, false
+	}
+	key := bytes.TrimSpace(property[:i])
+	value := bytes.TrimSpace(property[i+1:])
+	if str, ok := js_scanner.GetStaticString(key); ok && key[0] != '`' {
+		return str, value, true
+	}
+	if isIdentifier(key) {
+		return string(key), value, true
+	}
+	return "", nil, false
+}
+
+func isIdentifier(source []byte) bool {
+	if len(source) == 0 {
+		return false
+	}
+	for i, c := range source {
+		isAlpha := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c == '_' || c == '$'
+		if !isAlpha && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isNumericLiteral(source []byte) bool {
+	// ParseFloat also accepts identifiers like `Infinity` and `NaN`
+	digits := source
+	if len(digits) > 0 && digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || !(digits[0] == '.' || (digits[0] >= '0' && digits[0] <= '9')) {
+		return false
+	}
+	_, err := strconv.ParseFloat(string(source), 64)
+	return err == nil
+}
+
+// toCSSPropertyName converts a camelCased style key like `fontSize` or
+// `WebkitTransition` into a CSS property name like `font-size` or
+// `-webkit-transition`. Custom properties are returned as-is.
+func toCSSPropertyName(key string) string {
+	if strings.HasPrefix(key, "--") {
+		return key
+	}
+	var b strings.Builder
+	for _, c := range key {
+		if c >= 'A' && c <= 'Z' {
+			b.WriteByte('-')
+			b.WriteRune(c + ('a' - 'A'))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	name := b.String()
+	if strings.HasPrefix(name, "ms-") {
+		return "-" + name
+	}
+	return name
+}
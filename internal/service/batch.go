@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+
+	astro "github.com/withastro/compiler/internal"
+)
+
+// BatchOptions configure TransformBatch
+type BatchOptions struct {
+	// Options are shared by every file, except Sourcefile, which is set to the path of each file
+	Options Options
+	// Concurrency is the number of files compiled at once. Defaults to runtime.GOMAXPROCS(0).
+	Concurrency int
+	// Cache is used to skip compiling unchanged files, if it's set
+	Cache *Cache
+}
+
+// A BatchResult is the result of compiling a single file in a batch
+type BatchResult struct {
+	Path   string
+	Source string
+	Result TransformResult
+	// Err is set if the file couldn't be read or compiled. Diagnostics that
+	// don't prevent compilation are in Result.Diagnostics instead.
+	Err error
+}
+
+// TransformBatch reads and compiles every file in paths on a pool of
+// goroutines, and calls fn with each result in the same order as paths.
+// Results are passed to fn as soon as every earlier file is done, so fn
+// should be fast to avoid holding up the pool.
+//
+// If fn returns an error, or ctx is canceled, no more files are compiled and
+// the error is returned. A file that fails to compile doesn't stop the batch.
+func TransformBatch(ctx context.Context, paths []string, options BatchOptions, fn func(BatchResult) error) error {
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Each file's result is sent on its own channel, so results can be
+	// received in order even though files finish out of order
+	results := make([]chan BatchResult, len(paths))
+	for i := range results {
+		results[i] = make(chan BatchResult, 1)
+	}
+	// Limit how far the pool can get ahead of fn, so results waiting on a slow
+	// file don't pile up in memory
+	pending := make(chan struct{}, concurrency*2)
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] <- transformFile(ctx, paths[i], options)
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range paths {
+			select {
+			case pending <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var err error
+	for i := range paths {
+		var result BatchResult
+		select {
+		case result = <-results[i]:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		if err != nil {
+			break
+		}
+		<-pending
+		if err = fn(result); err != nil {
+			break
+		}
+	}
+	cancel()
+	wg.Wait()
+	return err
+}
+
+// transformFile reads and compiles a single file. Each call only uses its own
+// document and options, so it's safe to call concurrently.
+func transformFile(ctx context.Context, path string, options BatchOptions) (result BatchResult) {
+	result.Path = path
+	defer func() {
+		if r := recover(); r != nil {
+			result.Err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Source = string(source)
+	fileOptions := options.Options
+	fileOptions.Sourcefile = path
+	result.Result, result.Err = TransformCached(ctx, options.Cache, result.Source, fileOptions.TransformOptions(astro.HashFromSource(result.Source)))
+	return result
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+)
+
+// These cover scoped styles, hoisted scripts, components, slots and
+// diagnostics, so `go test -race` can catch state shared between files
+var batchSources = []string{
+	"---\nimport Component from './Component.astro';\nconst { title } = Astro.props;\n---\n<Component client:load title={title} />\n<style>h1 { color: red; } .a :global(.b) { margin: 0; }</style>\n<h1 class=\"title\">{title}</h1>",
+	"<script>console.log('hoisted');</script>\n<script is:inline>console.log('inline');</script>\n<div><slot name=\"header\" /><slot /></div>",
+	"---\nexport interface Props { name: string }\nconst { name } = Astro.props;\n---\n<style define:vars={{ color: 'red' }}>p { color: var(--color); }</style>\n<p>Hello {name}</p>\n<Unknown client:lod />",
+	"<html><head><title>Page</title></head><body>{[1, 2].map(i => <li>{i}</li>)}</body></html>",
+}
+
+func TestTransformBatch(t *testing.T) {
+	dir := t.TempDir()
+	paths := make([]string, 0)
+	for i := 0; i < 40; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("Page%d.astro", i))
+		if err := os.WriteFile(path, []byte(batchSources[i%len(batchSources)]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	missing := filepath.Join(dir, "Missing.astro")
+	paths = append(paths[:10], append([]string{missing}, paths[10:]...)...)
+
+	options := BatchOptions{Options: Options{SourceMap: "external", StaticExtraction: true}, Concurrency: 4}
+	i := 0
+	err := TransformBatch(context.Background(), paths, options, func(r BatchResult) error {
+		if r.Path != paths[i] {
+			t.Errorf("result %d: expected %s, got %s", i, paths[i], r.Path)
+		}
+		i++
+		if r.Path == missing {
+			if !errors.Is(r.Err, os.ErrNotExist) {
+				t.Errorf("expected a read error for %s, got %v", missing, r.Err)
+			}
+			return nil
+		}
+		if r.Err != nil {
+			t.Fatalf("%s: %v", r.Path, r.Err)
+		}
+		fileOptions := options.Options
+		fileOptions.Sourcefile = r.Path
+		expected, err := Transform(context.Background(), r.Source, fileOptions.TransformOptions(astro.HashFromSource(r.Source)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Result.Code != expected.Code || r.Result.Map != expected.Map || fmt.Sprint(r.Result.CSS) != fmt.Sprint(expected.CSS) || len(r.Result.Diagnostics) != len(expected.Diagnostics) {
+			t.Errorf("%s: the batch result doesn't match Transform", r.Path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i != len(paths) {
+		t.Errorf("expected %d results, got %d", len(paths), i)
+	}
+}
+
+func TestTransformBatchStop(t *testing.T) {
+	dir := t.TempDir()
+	paths := make([]string, 0)
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("Page%d.astro", i))
+		if err := os.WriteFile(path, []byte(batchSources[0]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := TransformBatch(context.Background(), paths, BatchOptions{Concurrency: 2}, func(r BatchResult) error {
+		calls++
+		if calls == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("expected the error returned by fn, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected fn to be called 3 times, got %d", calls)
+	}
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/withastro/compiler/internal/transform"
+	"github.com/withastro/compiler/internal/xxhash"
+)
+
+// DefaultCacheMaxSize is the default size limit of a Cache in bytes
+const DefaultCacheMaxSize = 256 << 20
+
+// CacheOptions configure OpenCache
+type CacheOptions struct {
+	Dir string
+	// MaxSize is the size limit of the cache in bytes. When the cache grows
+	// past it, the least recently used entries are removed until it's under
+	// 80% of the limit. Defaults to DefaultCacheMaxSize.
+	MaxSize int64
+	// Version identifies the compiler, so entries written by other versions
+	// aren't used. Defaults to a hash of the running executable.
+	Version string
+}
+
+// A Cache stores TransformResults on disk, so unchanged files don't need to
+// be parsed or printed again. It's safe for concurrent use, and entries are
+// written atomically, so processes can share a directory.
+type Cache struct {
+	dir     string
+	maxSize int64
+	version string
+
+	mu   sync.Mutex
+	size int64
+}
+
+// OpenCache opens the cache in options.Dir, creating it if needed
+func OpenCache(options CacheOptions) (*Cache, error) {
+	if err := os.MkdirAll(options.Dir, 0755); err != nil {
+		return nil, err
+	}
+	c := &Cache{dir: options.Dir, maxSize: options.MaxSize, version: options.Version}
+	if c.maxSize <= 0 {
+		c.maxSize = DefaultCacheMaxSize
+	}
+	if c.version == "" {
+		version, err := executableVersion()
+		if err != nil {
+			return nil, err
+		}
+		c.version = version
+	}
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		c.size += entry.Size()
+	}
+	return c, nil
+}
+
+var (
+	executableVersionOnce  sync.Once
+	executableVersionValue string
+	executableVersionErr   error
+)
+
+// executableVersion hashes the running executable, so every build of the
+// compiler has its own cache entries
+func executableVersion() (string, error) {
+	executableVersionOnce.Do(func() {
+		path, err := os.Executable()
+		if err != nil {
+			executableVersionErr = err
+			return
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			executableVersionErr = err
+			return
+		}
+		defer f.Close()
+		h := xxhash.New()
+		if _, err := io.Copy(h, f); err != nil {
+			executableVersionErr = err
+			return
+		}
+		executableVersionValue = hex.EncodeToString(h.Sum(nil))
+	})
+	return executableVersionValue, executableVersionErr
+}
+
+// Key returns the cache key for compiling source with opts. It combines the
+// compiler version, a hash of the source and every field of opts. ok is false
+// if the result can't be cached, because preprocessors can't be part of a key.
+func (c *Cache) Key(source string, opts transform.TransformOptions) (key string, ok bool) {
+	if opts.PreprocessStyle != nil || opts.PreprocessScript != nil {
+		return "", false
+	}
+	options, err := json.Marshal(opts)
+	if err != nil {
+		return "", false
+	}
+	h := xxhash.New()
+	h.Write([]byte(c.version))
+	h.Write([]byte{0})
+	h.Write(options)
+	h.Write([]byte{0})
+	h.Write([]byte(source))
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Get returns the result stored for key
+func (c *Cache) Get(key string) (TransformResult, bool) {
+	var result TransformResult
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return result, false
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return result, false
+	}
+	// Entries are evicted by modification time, so used entries are kept longest
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return result, true
+}
+
+// Put stores result for key, and evicts old entries if the cache is too big
+func (c *Cache) Put(key string, result TransformResult) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size += int64(len(data))
+	if c.size > c.maxSize {
+		return c.evict()
+	}
+	return nil
+}
+
+// evict removes the least recently used entries until the cache is under 80%
+// of its size limit. The size is recomputed from disk, because other
+// processes may be using the same directory.
+func (c *Cache) evict() error {
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	c.size = 0
+	for _, entry := range entries {
+		c.size += entry.Size()
+	}
+	target := c.maxSize / 5 * 4
+	for _, entry := range entries {
+		if c.size <= target {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		c.size -= entry.Size()
+	}
+	return nil
+}
+
+func (c *Cache) entries() ([]os.FileInfo, error) {
+	dir, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]os.FileInfo, 0, len(dir))
+	for _, entry := range dir {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// The entry was removed by another process
+			continue
+		}
+		entries = append(entries, info)
+	}
+	return entries, nil
+}
+
+// TransformCached is like Transform, but uses the result stored in cache if
+// there is one. Results are only stored if the file compiles. cache may be nil.
+func TransformCached(ctx context.Context, cache *Cache, source string, transformOptions transform.TransformOptions) (TransformResult, error) {
+	if cache == nil {
+		return Transform(ctx, source, transformOptions)
+	}
+	key, ok := cache.Key(source, transformOptions)
+	if !ok {
+		return Transform(ctx, source, transformOptions)
+	}
+	if result, ok := cache.Get(key); ok {
+		return result, nil
+	}
+	result, err := Transform(ctx, source, transformOptions)
+	if err != nil {
+		return result, err
+	}
+	// A cache that can't be written shouldn't fail the build
+	cache.Put(key, result)
+	return result, nil
+}
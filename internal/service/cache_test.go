@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/withastro/compiler/internal/transform"
+)
+
+func TestCacheKey(t *testing.T) {
+	cache, err := OpenCache(CacheOptions{Dir: t.TempDir(), Version: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := OpenCache(CacheOptions{Dir: t.TempDir(), Version: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := "<h1>Hello</h1>"
+	options := Options{Sourcefile: "/src/Page.astro"}.TransformOptions("hash")
+	key, ok := cache.Key(source, options)
+	if !ok {
+		t.Fatal("expected the options to be cacheable")
+	}
+
+	changedOptions := options
+	changedOptions.StaticExtraction = true
+	changedDirectives := options
+	changedDirectives.ClientDirectives = []string{"load"}
+	keys := map[string]string{
+		"source":     mustKey(t, cache, "<h1>Hello!</h1>", options),
+		"options":    mustKey(t, cache, source, changedOptions),
+		"directives": mustKey(t, cache, source, changedDirectives),
+		"version":    mustKey(t, other, source, options),
+	}
+	for name, changed := range keys {
+		if changed == key {
+			t.Errorf("expected changing the %s to change the key", name)
+		}
+	}
+	if again := mustKey(t, cache, source, options); again != key {
+		t.Errorf("expected the same key for the same input, got %s and %s", key, again)
+	}
+
+	withPreprocessor := options
+	withPreprocessor.PreprocessScript = testScriptPreprocessor{}
+	if _, ok := cache.Key(source, withPreprocessor); ok {
+		t.Error("expected options with a preprocessor not to be cacheable")
+	}
+}
+
+type testScriptPreprocessor struct{}
+
+func (testScriptPreprocessor) Process(ctx context.Context, content string, attrs map[string]string) (string, error) {
+	return content, nil
+}
+
+func mustKey(t *testing.T, cache *Cache, source string, options transform.TransformOptions) string {
+	t.Helper()
+	key, ok := cache.Key(source, options)
+	if !ok {
+		t.Fatal("expected the options to be cacheable")
+	}
+	return key
+}
+
+func TestTransformCached(t *testing.T) {
+	cache, err := OpenCache(CacheOptions{Dir: t.TempDir(), Version: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := "---\nconst name = 'world';\n---\n<h1>Hello {name}</h1>"
+	options := Options{SourceMap: "both"}.TransformOptions("hash")
+
+	result, err := TransformCached(context.Background(), cache, source, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := TransformCached(context.Background(), cache, source, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(result) != fmt.Sprint(cached) {
+		t.Errorf("expected the cached result to match\nwant: %v\ngot:  %v", result, cached)
+	}
+
+	// Hits shouldn't compile the file at all
+	key := mustKey(t, cache, source, options)
+	if err := cache.Put(key, TransformResult{Code: "cached"}); err != nil {
+		t.Fatal(err)
+	}
+	cached, err = TransformCached(context.Background(), cache, source, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.Code != "cached" {
+		t.Errorf("expected the stored result, got %q", cached.Code)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	entry := TransformResult{Code: strings.Repeat("a", 1000)}
+	// Each entry is a little over 1000 bytes, so only 4 fit
+	cache, err := OpenCache(CacheOptions{Dir: dir, MaxSize: 5000, Version: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 4; i++ {
+		key := fmt.Sprintf("entry%d", i)
+		if err := cache.Put(key, entry); err != nil {
+			t.Fatal(err)
+		}
+		// Make the order of the entries predictable
+		modTime := start.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(filepath.Join(dir, key+".json"), modTime, modTime)
+	}
+	// Using the oldest entry should keep it
+	if _, ok := cache.Get("entry0"); !ok {
+		t.Fatal("expected entry0 to be cached")
+	}
+	if err := cache.Put("entry4", entry); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		if _, ok := cache.Get(fmt.Sprintf("entry%d", i)); ok {
+			got = append(got, fmt.Sprintf("entry%d", i))
+		}
+	}
+	want := []string{"entry0", "entry3", "entry4"}
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("\nwant: %v\ngot:  %v", want, got)
+	}
+
+	// Reopening the cache should count the existing entries
+	reopened, err := OpenCache(CacheOptions{Dir: dir, MaxSize: 5000, Version: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.size != cache.size {
+		t.Errorf("expected a size of %d, got %d", cache.size, reopened.size)
+	}
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"encoding/json"
+
+	t "github.com/withastro/compiler/internal/t"
+	"github.com/withastro/compiler/internal/transform"
+)
+
+// Options are the options accepted by `transform`, using the same names as
+// the TransformOptions in the JS API. The WASM build reads them from a JS
+// object, and `astro serve` decodes them from JSON.
+type Options struct {
+	Sourcefile       string          `json:"sourcefile"`
+	Pathname         string          `json:"pathname"`
+	InternalURL      string          `json:"internalURL"`
+	SourceMap        SourceMapOption `json:"sourcemap"`
+	Site             string          `json:"site"`
+	ProjectRoot      string          `json:"projectRoot"`
+	StaticExtraction bool            `json:"experimentalStaticExtraction"`
+	Directives       []Directive     `json:"directives"`
+	ClientDirectives []string        `json:"clientDirectives"`
+}
+
+type Directive struct {
+	Namespace string `json:"namespace"`
+	Specifier string `json:"specifier"`
+	Export    string `json:"export"`
+	// Target is "element", "component", or empty for both
+	Target string `json:"target"`
+}
+
+// SourceMapOption is "external", "inline" or "both". `true` is decoded as "both".
+type SourceMapOption string
+
+func (s *SourceMapOption) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if err := json.Unmarshal(data, &enabled); err == nil {
+		*s = ""
+		if enabled {
+			*s = "both"
+		}
+		return nil
+	}
+	return json.Unmarshal(data, (*string)(s))
+}
+
+// TransformOptions fills in the defaults for any missing options. The
+// preprocessors are left unset, because they can't be decoded from JSON.
+func (o Options) TransformOptions(hash string) transform.TransformOptions {
+	filename := o.Sourcefile
+	if filename == "" {
+		filename = "<stdin>"
+	}
+
+	pathname := o.Pathname
+	if pathname == "" {
+		pathname = "<stdin>"
+	}
+
+	internalURL := o.InternalURL
+	if internalURL == "" {
+		internalURL = "astro/internal"
+	}
+
+	site := o.Site
+	if site == "" {
+		site = "https://astro.build"
+	}
+
+	projectRoot := o.ProjectRoot
+	if projectRoot == "" {
+		projectRoot = "."
+	}
+
+	directives := make([]transform.Directive, 0, len(o.Directives))
+	for _, directive := range o.Directives {
+		target := transform.ElementTarget | transform.ComponentTarget
+		switch directive.Target {
+		case "element":
+			target = transform.ElementTarget
+		case "component":
+			target = transform.ComponentTarget
+		}
+		directives = append(directives, transform.Directive{
+			Namespace: directive.Namespace,
+			Specifier: directive.Specifier,
+			Export:    directive.Export,
+			Target:    target,
+		})
+	}
+
+	return transform.TransformOptions{
+		Scope:            hash,
+		Filename:         filename,
+		Pathname:         pathname,
+		InternalURL:      internalURL,
+		SourceMap:        string(o.SourceMap),
+		Site:             site,
+		ProjectRoot:      projectRoot,
+		StaticExtraction: o.StaticExtraction,
+		Directives:       directives,
+		ClientDirectives: o.ClientDirectives,
+	}
+}
+
+// ParseOptions are the options accepted by `parse`
+type ParseOptions struct {
+	Sourcefile string `json:"sourcefile"`
+	// Position defaults to true
+	Position       *bool `json:"position"`
+	FrontmatterAST bool  `json:"frontmatterAST"`
+	ExpressionAST  bool  `json:"expressionAST"`
+}
+
+func (o ParseOptions) ParseOptions() t.ParseOptions {
+	position := true
+	if o.Position != nil {
+		position = *o.Position
+	}
+	return t.ParseOptions{
+		Position:       position,
+		FrontmatterAST: o.FrontmatterAST,
+		ExpressionAST:  o.ExpressionAST,
+	}
+}
+
+// Filename returns the sourcefile used in errors
+func (o ParseOptions) Filename() string {
+	if o.Sourcefile == "" {
+		return "<stdin>"
+	}
+	return o.Sourcefile
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/printer"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	ParseErrorCode     = -32700
+	InvalidRequestCode = -32600
+	MethodNotFoundCode = -32601
+	InvalidParamsCode  = -32602
+	// CompileErrorCode is used when a file can't be compiled. The error data is a printer.CompileError.
+	CompileErrorCode = -32000
+)
+
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+type ResponseError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// TransformParams are the params of a `transform` request
+type TransformParams struct {
+	Source  string  `json:"source"`
+	Options Options `json:"options"`
+}
+
+// ParseParams are the params of a `parse` request
+type ParseParams struct {
+	Source  string       `json:"source"`
+	Options ParseOptions `json:"options"`
+}
+
+// TokenizeParams are the params of a `tokenize` request
+type TokenizeParams struct {
+	Source string `json:"source"`
+}
+
+// FromJSONParams are the params of a `fromJSON` request
+type FromJSONParams struct {
+	AST string `json:"ast"`
+}
+
+// Serve reads newline-delimited JSON-RPC 2.0 requests from r and writes a
+// response for each one to w, one per line. Requests are handled
+// concurrently, so responses may be written out of order and must be matched
+// to requests by their id. Requests without an id are notifications and
+// don't get a response.
+//
+// Serve returns when r reaches EOF and every response has been written.
+func Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	reader := bufio.NewReader(r)
+	var mu sync.Mutex
+	encoder := json.NewEncoder(w)
+	var writeErr error
+	write := func(response Response) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := encoder.Encode(response); err != nil && writeErr == nil {
+			writeErr = err
+		}
+	}
+
+	var wg sync.WaitGroup
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && !isBlank(line) {
+			wg.Add(1)
+			go func(line []byte) {
+				defer wg.Done()
+				if response, ok := handle(ctx, line); ok {
+					write(response)
+				}
+			}(line)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			wg.Wait()
+			return err
+		}
+	}
+	wg.Wait()
+	return writeErr
+}
+
+// handle runs a single request. ok is false if the request is a notification.
+func handle(ctx context.Context, line []byte) (response Response, ok bool) {
+	var request Request
+	if err := json.Unmarshal(line, &request); err != nil {
+		return Response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &ResponseError{Code: ParseErrorCode, Message: err.Error()}}, true
+	}
+	response = Response{JSONRPC: "2.0", ID: request.ID}
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		response.ID = nullID(request.ID)
+		response.Error = &ResponseError{Code: InvalidRequestCode, Message: "invalid JSON-RPC 2.0 request"}
+		return response, true
+	}
+
+	result, rpcErr := call(ctx, request.Method, request.Params)
+	if request.ID == nil {
+		return Response{}, false
+	}
+	response.Result = result
+	response.Error = rpcErr
+	return response, true
+}
+
+func call(ctx context.Context, method string, params json.RawMessage) (result interface{}, rpcErr *ResponseError) {
+	var source, filename string
+	// A panic would otherwise stop the server, and every request in flight
+	defer func() {
+		if r := recover(); r != nil {
+			result = nil
+			rpcErr = compileError(source, filename, fmt.Errorf("%v", r))
+		}
+	}()
+
+	switch method {
+	case "transform":
+		var p TransformParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		source = p.Source
+		transformOptions := p.Options.TransformOptions(astro.HashFromSource(p.Source))
+		filename = transformOptions.Filename
+		result, err := Transform(ctx, p.Source, transformOptions)
+		if err != nil {
+			return nil, compileError(source, filename, err)
+		}
+		return result, nil
+	case "parse":
+		var p ParseParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		source, filename = p.Source, p.Options.Filename()
+		result, err := Parse(p.Source, p.Options.ParseOptions())
+		if err != nil {
+			return nil, compileError(source, filename, err)
+		}
+		return result, nil
+	case "fromJSON":
+		var p FromJSONParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		source, filename = p.AST, "<stdin>"
+		result, err := FromJSON(p.AST)
+		if err != nil {
+			return nil, compileError(source, filename, err)
+		}
+		return result, nil
+	case "tokenize":
+		var p TokenizeParams
+		if err := unmarshalParams(params, &p); err != nil {
+			return nil, err
+		}
+		source, filename = p.Source, "<stdin>"
+		result, err := Tokenize(p.Source)
+		if err != nil {
+			return nil, compileError(source, filename, err)
+		}
+		return result, nil
+	}
+	return nil, &ResponseError{Code: MethodNotFoundCode, Message: fmt.Sprintf("unknown method %q", method)}
+}
+
+func unmarshalParams(params json.RawMessage, v interface{}) *ResponseError {
+	if len(params) == 0 {
+		return &ResponseError{Code: InvalidParamsCode, Message: "missing params"}
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &ResponseError{Code: InvalidParamsCode, Message: err.Error()}
+	}
+	return nil
+}
+
+func compileError(source string, filename string, err error) *ResponseError {
+	e := printer.PrintError(source, filename, err)
+	return &ResponseError{Code: CompileErrorCode, Message: e.Message, Data: e}
+}
+
+// nullID returns id, or null if the request didn't have one, because error
+// responses to invalid requests must always have an id
+func nullID(id json.RawMessage) json.RawMessage {
+	if id == nil {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+func isBlank(line []byte) bool {
+	for _, c := range line {
+		if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+			return false
+		}
+	}
+	return true
+}
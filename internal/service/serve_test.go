@@ -0,0 +1,195 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+func TestServe(t *testing.T) {
+	requests := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"parse","params":{"source":"<div>Hello</div>","options":{"position":false}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tokenize","params":{"source":"<a href=\"/\">link</a>"}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"minify","params":{}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"transform"}`,
+		`{"id":5,"method":"parse","params":{"source":""}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"fromJSON","params":{"ast":"{\"type\":\"root\",\"children\":[{\"type\":\"element\",\"name\":\"div\",\"attributes\":[],\"children\":[{\"type\":\"text\",\"value\":\"Hello\"}]}]}"}}`,
+		`{"jsonrpc":"2.0","id":7,"method":"fromJSON","params":{"ast":"{\"type\":\"text\"}"}}`,
+		`not json`,
+		``,
+		`{"jsonrpc":"2.0","method":"parse","params":{"source":"notification"}}`,
+	}
+	var out bytes.Buffer
+	if err := Serve(context.Background(), strings.NewReader(strings.Join(requests, "\n")), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := decodeResponses(t, out.Bytes())
+	want := map[string]string{
+		"1":    `{"ast":"{\"type\":\"root\",\"children\":[{\"type\":\"element\",\"name\":\"div\",\"attributes\":[],\"children\":[{\"type\":\"text\",\"value\":\"Hello\"}]}]}"}`,
+		"2":    `{"tokens":[{"type":"StartTag","data":"a","start":1,"attributes":[{"key":"href","val":"/","type":"quoted"}]},{"type":"Text","data":"link","start":12},{"type":"EndTag","data":"a","start":18}]}`,
+		"3":    `error -32601`,
+		"4":    `error -32602`,
+		"5":    `error -32600`,
+		"6":    `{"code":"\u003cdiv\u003eHello\u003c/div\u003e"}`,
+		"7":    `error -32000`,
+		"null": `error -32700`,
+	}
+	if len(responses) != len(want) {
+		t.Errorf("expected %d responses, got %d: %s", len(want), len(responses), out.String())
+	}
+	for id, expected := range want {
+		response, ok := responses[id]
+		if !ok {
+			t.Errorf("missing response for id %s", id)
+			continue
+		}
+		got := string(response.Result)
+		if response.Error != nil {
+			got = fmt.Sprintf("error %d", response.Error.Code)
+		}
+		if got != expected {
+			t.Errorf("id %s\nwant: %s\ngot:  %s", id, expected, got)
+		}
+	}
+}
+
+func TestServeConcurrentTransforms(t *testing.T) {
+	const count = 50
+	var in bytes.Buffer
+	sources := make([]string, count)
+	for i := 0; i < count; i++ {
+		sources[i] = fmt.Sprintf("---\nconst name = 'Component%d';\n---\n<style>h1 { color: red; }</style>\n<h1 class={name}>Hello {name}</h1>\n", i)
+		params, _ := json.Marshal(TransformParams{Source: sources[i], Options: Options{Sourcefile: fmt.Sprintf("/src/Component%d.astro", i), SourceMap: "external"}})
+		fmt.Fprintf(&in, `{"jsonrpc":"2.0","id":%d,"method":"transform","params":%s}`+"\n", i, params)
+	}
+	var out bytes.Buffer
+	if err := Serve(context.Background(), &in, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	responses := decodeResponses(t, out.Bytes())
+	if len(responses) != count {
+		t.Fatalf("expected %d responses, got %d", count, len(responses))
+	}
+	for i, source := range sources {
+		response := responses[fmt.Sprint(i)]
+		if response.Error != nil {
+			t.Fatalf("id %d: %s", i, response.Error.Message)
+		}
+		options := Options{Sourcefile: fmt.Sprintf("/src/Component%d.astro", i), SourceMap: "external"}
+		expected, err := Transform(context.Background(), source, options.TransformOptions(astro.HashFromSource(source)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got TransformResult
+		if err := json.Unmarshal(response.Result, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Code != expected.Code || got.Map != expected.Map {
+			t.Errorf("id %d: the served result doesn't match Transform", i)
+		}
+	}
+}
+
+func TestServeStdoutIsJSON(t *testing.T) {
+	sources := []string{
+		"<style global>h1 { color: red; }</style>\n<h1>Hello</h1>\n",
+		"<script hoist>console.log('hi')</script>\n<h1>Hello</h1>\n",
+		"---\nconst content = '<p>Hello</p>';\n---\n<div set:html={content}>overwritten</div>\n",
+		"---\nconst url = '/script.js';\n---\n<script src={url}></script>\n",
+	}
+	var in bytes.Buffer
+	for i, source := range sources {
+		params, _ := json.Marshal(TransformParams{Source: source, Options: Options{Sourcefile: "/src/Component.astro", StaticExtraction: true}})
+		fmt.Fprintf(&in, `{"jsonrpc":"2.0","id":%d,"method":"transform","params":%s}`+"\n", i, params)
+	}
+
+	// Warnings used to be printed to stdout, where they corrupted the response stream
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+	serveErr := Serve(context.Background(), &in, w)
+	w.Close()
+	os.Stdout = stdout
+	data := <-out
+	if serveErr != nil {
+		t.Fatal(serveErr)
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != len(sources) {
+		t.Fatalf("expected %d lines, got %d: %s", len(sources), len(lines), data)
+	}
+	for _, line := range lines {
+		var response testResponse
+		if err := json.Unmarshal(line, &response); err != nil {
+			t.Fatalf("stdout line is not JSON %s: %v", line, err)
+		}
+		if response.Error != nil {
+			t.Fatalf("id %s: %s", response.ID, response.Error.Message)
+		}
+		var result TransformResult
+		if err := json.Unmarshal(response.Result, &result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Diagnostics) != 1 || result.Diagnostics[0].Severity != int(loc.WarningType) {
+			t.Errorf("id %s: expected a single warning diagnostic, got %+v", response.ID, result.Diagnostics)
+		}
+	}
+}
+
+func TestSourceMapOption(t *testing.T) {
+	tests := map[string]SourceMapOption{
+		`{"sourcemap":true}`:       "both",
+		`{"sourcemap":false}`:      "",
+		`{"sourcemap":"inline"}`:   "inline",
+		`{"sourcemap":"external"}`: "external",
+		`{}`:                       "",
+	}
+	for input, want := range tests {
+		var options Options
+		if err := json.Unmarshal([]byte(input), &options); err != nil {
+			t.Fatal(err)
+		}
+		if options.SourceMap != want {
+			t.Errorf("%s: want %q, got %q", input, want, options.SourceMap)
+		}
+	}
+}
+
+type testResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *ResponseError  `json:"error"`
+}
+
+func decodeResponses(t *testing.T, data []byte) map[string]testResponse {
+	t.Helper()
+	responses := make(map[string]testResponse)
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var response testResponse
+		if err := json.Unmarshal(line, &response); err != nil {
+			t.Fatalf("invalid response %s: %v", line, err)
+		}
+		responses[string(response.ID)] = response
+	}
+	return responses
+}
@@ -0,0 +1,312 @@
+// Package service implements the `transform`, `parse` and `tokenize` APIs
+// shared by the WASM build and `astro serve`. Results use the same shape as
+// the JS API, so they have both `js` tags for the WASM build and `json` tags.
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/printer"
+	t "github.com/withastro/compiler/internal/t"
+	"github.com/withastro/compiler/internal/transform"
+)
+
+type HoistedScript struct {
+	Code string `js:"code" json:"code"`
+	Src  string `js:"src" json:"src"`
+	Type string `js:"type" json:"type"`
+}
+
+type DiagnosticLocation struct {
+	File   string `js:"file" json:"file"`
+	Line   int    `js:"line" json:"line"`
+	Column int    `js:"column" json:"column"`
+	Length int    `js:"length" json:"length"`
+}
+
+type DiagnosticMessage struct {
+	Severity int                `js:"severity" json:"severity"`
+	Code     int                `js:"code" json:"code"`
+	Text     string             `js:"text" json:"text"`
+	Location DiagnosticLocation `js:"location" json:"location"`
+}
+
+type ManifestProp struct {
+	Name        string `js:"name" json:"name"`
+	Type        string `js:"type" json:"type"`
+	Optional    bool   `js:"optional" json:"optional"`
+	Description string `js:"description" json:"description"`
+}
+
+type ManifestSlot struct {
+	Name     string `js:"name" json:"name"`
+	Dynamic  bool   `js:"dynamic" json:"dynamic"`
+	Fallback bool   `js:"fallback" json:"fallback"`
+}
+
+type ManifestPassedSlot struct {
+	Component string `js:"component" json:"component"`
+	Specifier string `js:"specifier" json:"specifier"`
+	Name      string `js:"name" json:"name"`
+	Dynamic   bool   `js:"dynamic" json:"dynamic"`
+}
+
+type ManifestComponent struct {
+	Name      string `js:"name" json:"name"`
+	Specifier string `js:"specifier" json:"specifier"`
+	Export    string `js:"export" json:"export"`
+	Directive string `js:"directive" json:"directive"`
+}
+
+type ComponentManifest struct {
+	Name                 string               `js:"name" json:"name"`
+	Props                []ManifestProp       `js:"props" json:"props"`
+	Slots                []ManifestSlot       `js:"slots" json:"slots"`
+	PassedSlots          []ManifestPassedSlot `js:"passedSlots" json:"passedSlots"`
+	HydratedComponents   []ManifestComponent  `js:"hydratedComponents" json:"hydratedComponents"`
+	ClientOnlyComponents []ManifestComponent  `js:"clientOnlyComponents" json:"clientOnlyComponents"`
+	Scripts              []HoistedScript      `js:"scripts" json:"scripts"`
+	StyleCount           int                  `js:"styleCount" json:"styleCount"`
+}
+
+type TransformResult struct {
+	Code        string              `js:"code" json:"code"`
+	Map         string              `js:"map" json:"map"`
+	CSS         []string            `js:"css" json:"css"`
+	Scripts     []HoistedScript     `js:"scripts" json:"scripts"`
+	Diagnostics []DiagnosticMessage `js:"diagnostics" json:"diagnostics"`
+	Manifest    ComponentManifest   `js:"manifest" json:"manifest"`
+	PropsSchema string              `js:"propsSchema" json:"propsSchema"`
+}
+
+type ParseResult struct {
+	AST string `js:"ast" json:"ast"`
+}
+
+type FromJSONResult struct {
+	Code string `js:"code" json:"code"`
+}
+
+type TokenAttribute struct {
+	Key  string `json:"key"`
+	Val  string `json:"val"`
+	Type string `json:"type"`
+}
+
+type Token struct {
+	Type       string           `json:"type"`
+	Data       string           `json:"data"`
+	Start      int              `json:"start"`
+	Attributes []TokenAttribute `json:"attributes,omitempty"`
+}
+
+type TokenizeResult struct {
+	Tokens []Token `json:"tokens"`
+}
+
+// Transform parses, preprocesses and transforms source. Only native
+// preprocessors are run, see transform.PreprocessStyles.
+func Transform(ctx context.Context, source string, transformOptions transform.TransformOptions) (TransformResult, error) {
+	doc, err := astro.Parse(strings.NewReader(source))
+	if err != nil {
+		return TransformResult{}, err
+	}
+
+	// Hoist styles and scripts to the top-level
+	transform.ExtractStyles(doc)
+
+	if err := transform.PreprocessStyles(ctx, doc, transformOptions); err != nil {
+		return TransformResult{}, err
+	}
+	if err := transform.PreprocessScripts(ctx, doc, transformOptions); err != nil {
+		return TransformResult{}, err
+	}
+
+	return TransformDocument(source, doc, transformOptions), nil
+}
+
+// TransformDocument transforms and prints doc, which must already be parsed and preprocessed
+func TransformDocument(source string, doc *astro.Node, transformOptions transform.TransformOptions) TransformResult {
+	// Perform CSS and element scoping as needed
+	transform.Transform(doc, transformOptions)
+
+	css := []string{}
+	scripts := []HoistedScript{}
+	// Only perform static CSS extraction if the flag is passed in.
+	if transformOptions.StaticExtraction {
+		css_result := printer.PrintCSS(source, doc, transformOptions)
+		for _, bytes := range css_result.Output {
+			css = append(css, string(bytes))
+		}
+
+		// Append hoisted scripts
+		for _, node := range doc.Scripts {
+			src := astro.GetAttribute(node, "src")
+			script := HoistedScript{
+				Src:  "",
+				Code: "",
+				Type: "",
+			}
+			if src != nil {
+				script.Type = "external"
+				script.Src = src.Val
+			} else if node.FirstChild != nil {
+				script.Type = "inline"
+				script.Code = node.FirstChild.Data
+			}
+			scripts = append(scripts, script)
+		}
+	}
+
+	result := printer.PrintToJS(source, doc, len(css), transformOptions)
+	propsSchema, _ := printer.PrintPropsSchema(doc, transformOptions)
+
+	code := string(result.Output)
+	sourcemap := ""
+	switch transformOptions.SourceMap {
+	case "external":
+		sourcemap = createSourceMapString(source, result, transformOptions)
+	case "both":
+		sourcemap = createSourceMapString(source, result, transformOptions)
+		code += "\n" + inlineSourceMap(sourcemap)
+	case "inline":
+		code += "\n" + inlineSourceMap(createSourceMapString(source, result, transformOptions))
+	}
+
+	return TransformResult{
+		CSS:         css,
+		Code:        code,
+		Map:         sourcemap,
+		Scripts:     scripts,
+		Diagnostics: makeDiagnostics(source, doc, transformOptions),
+		Manifest:    makeManifest(doc, transformOptions),
+		PropsSchema: string(propsSchema),
+	}
+}
+
+// Parse parses source and prints its AST as JSON
+func Parse(source string, parseOptions t.ParseOptions) (ParseResult, error) {
+	doc, err := astro.Parse(strings.NewReader(source))
+	if err != nil {
+		return ParseResult{}, err
+	}
+	result := printer.PrintToJSON(source, doc, parseOptions)
+	return ParseResult{
+		AST: string(result.Output),
+	}, nil
+}
+
+// FromJSON rebuilds a document from an AST printed by Parse, which may have
+// been changed since, and prints it back to source. The source can be passed
+// to Transform.
+func FromJSON(ast string) (FromJSONResult, error) {
+	doc, err := printer.ParseJSON([]byte(ast))
+	if err != nil {
+		return FromJSONResult{}, err
+	}
+	var b strings.Builder
+	astro.PrintToSource(&b, doc)
+	return FromJSONResult{
+		Code: b.String(),
+	}, nil
+}
+
+// Tokenize returns the tokens of source, without building a tree
+func Tokenize(source string) (TokenizeResult, error) {
+	z := astro.NewTokenizer(strings.NewReader(source))
+	tokens := make([]Token, 0)
+	for {
+		if z.Next() == astro.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return TokenizeResult{}, err
+			}
+			return TokenizeResult{Tokens: tokens}, nil
+		}
+		tok := z.Token()
+		token := Token{
+			Type:  tok.Type.String(),
+			Data:  tok.Data,
+			Start: tok.Loc.Start,
+		}
+		for _, attr := range tok.Attr {
+			token.Attributes = append(token.Attributes, TokenAttribute{
+				Key:  attr.Key,
+				Val:  attr.Val,
+				Type: attr.Type.String(),
+			})
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+func makeDiagnostics(source string, doc *astro.Node, transformOptions transform.TransformOptions) []DiagnosticMessage {
+	diagnostics := make([]DiagnosticMessage, 0)
+	for _, d := range printer.PrintDiagnostics(source, transformOptions.Filename, doc.Diagnostics) {
+		diagnostics = append(diagnostics, DiagnosticMessage{
+			Severity: d.Severity,
+			Code:     d.Code,
+			Text:     d.Text,
+			Location: DiagnosticLocation{
+				File:   d.Location.File,
+				Line:   d.Location.Line,
+				Column: d.Location.Column,
+				Length: d.Location.Length,
+			},
+		})
+	}
+	return diagnostics
+}
+
+func makeManifest(doc *astro.Node, transformOptions transform.TransformOptions) ComponentManifest {
+	m := printer.PrintManifest(doc, transformOptions)
+	manifest := ComponentManifest{
+		Name:                 m.Name,
+		Props:                make([]ManifestProp, 0, len(m.Props)),
+		Slots:                make([]ManifestSlot, 0, len(m.Slots)),
+		PassedSlots:          make([]ManifestPassedSlot, 0, len(m.PassedSlots)),
+		HydratedComponents:   make([]ManifestComponent, 0, len(m.HydratedComponents)),
+		ClientOnlyComponents: make([]ManifestComponent, 0, len(m.ClientOnlyComponents)),
+		Scripts:              make([]HoistedScript, 0, len(m.Scripts)),
+		StyleCount:           m.StyleCount,
+	}
+	for _, prop := range m.Props {
+		manifest.Props = append(manifest.Props, ManifestProp(prop))
+	}
+	for _, slot := range m.Slots {
+		manifest.Slots = append(manifest.Slots, ManifestSlot(slot))
+	}
+	for _, slot := range m.PassedSlots {
+		manifest.PassedSlots = append(manifest.PassedSlots, ManifestPassedSlot(slot))
+	}
+	for _, component := range m.HydratedComponents {
+		manifest.HydratedComponents = append(manifest.HydratedComponents, ManifestComponent(component))
+	}
+	for _, component := range m.ClientOnlyComponents {
+		manifest.ClientOnlyComponents = append(manifest.ClientOnlyComponents, ManifestComponent(component))
+	}
+	for _, script := range m.Scripts {
+		manifest.Scripts = append(manifest.Scripts, HoistedScript{Type: script.Type, Src: script.Src, Code: script.Code})
+	}
+	return manifest
+}
+
+func createSourceMapString(source string, result printer.PrintResult, transformOptions transform.TransformOptions) string {
+	sourcesContent, _ := json.Marshal(source)
+	return fmt.Sprintf(`{
+  "version": 3,
+  "sources": ["%s"],
+  "sourcesContent": [%s],
+  "mappings": "%s",
+  "names": []
+}`, transformOptions.Filename, string(sourcesContent), string(result.SourceMapChunk.Buffer))
+}
+
+func inlineSourceMap(sourcemap string) string {
+	return `//# sourceMappingURL=data:application/json;charset=utf-8;base64,` + base64.StdEncoding.EncodeToString([]byte(sourcemap))
+}
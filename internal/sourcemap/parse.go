@@ -0,0 +1,84 @@
+package sourcemap
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf16"
+)
+
+type sourceMapJSON struct {
+	Version  int      `json:"version"`
+	Sources  []string `json:"sources"`
+	Mappings string   `json:"mappings"`
+}
+
+// ParseSourceMap parses a version 3 source map, like the ones returned by
+// style preprocessors. Only the sources and mappings are read.
+func ParseSourceMap(data []byte) (*SourceMap, error) {
+	var raw sourceMapJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Version != 3 {
+		return nil, fmt.Errorf("unsupported source map version %d", raw.Version)
+	}
+
+	sm := &SourceMap{Sources: raw.Sources}
+	mappings := utf16.Encode([]rune(raw.Mappings))
+	generatedLine := 0
+	generatedColumn := 0
+	sourceIndex := 0
+	originalLine := 0
+	originalColumn := 0
+	current := 0
+	for current < len(mappings) {
+		switch mappings[current] {
+		case ';':
+			generatedLine++
+			generatedColumn = 0
+			current++
+			continue
+		case ',':
+			current++
+			continue
+		}
+
+		// Every value is relative to the previous segment, except the generated
+		// column which is reset at the start of each line
+		values := make([]int, 0, 5)
+		for current < len(mappings) && mappings[current] != ',' && mappings[current] != ';' {
+			value, n, ok := DecodeVLQUTF16(mappings[current:])
+			if !ok {
+				return nil, fmt.Errorf("invalid VLQ value at offset %d of mappings", current)
+			}
+			values = append(values, value)
+			current += n
+		}
+
+		switch len(values) {
+		case 1:
+			// Segments without an original location don't map anywhere
+			generatedColumn += values[0]
+			continue
+		case 4, 5:
+			generatedColumn += values[0]
+			sourceIndex += values[1]
+			originalLine += values[2]
+			originalColumn += values[3]
+		default:
+			return nil, fmt.Errorf("invalid segment with %d values in mappings", len(values))
+		}
+
+		if generatedColumn < 0 || sourceIndex < 0 || originalLine < 0 || originalColumn < 0 {
+			return nil, fmt.Errorf("invalid negative position in mappings")
+		}
+		sm.Mappings = append(sm.Mappings, Mapping{
+			GeneratedLine:   generatedLine,
+			GeneratedColumn: generatedColumn,
+			SourceIndex:     sourceIndex,
+			OriginalLine:    originalLine,
+			OriginalColumn:  originalColumn,
+		})
+	}
+	return sm, nil
+}
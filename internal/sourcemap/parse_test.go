@@ -0,0 +1,65 @@
+package sourcemap
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseSourceMap(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []Mapping
+		err    bool
+	}{
+		{
+			name:   "single line",
+			source: `{"version":3,"sources":["style.scss"],"names":[],"mappings":"AAAA,EAAE;AACA"}`,
+			want: []Mapping{
+				{GeneratedLine: 0, GeneratedColumn: 0, OriginalLine: 0, OriginalColumn: 0},
+				{GeneratedLine: 0, GeneratedColumn: 2, OriginalLine: 0, OriginalColumn: 2},
+				{GeneratedLine: 1, GeneratedColumn: 0, OriginalLine: 1, OriginalColumn: 2},
+			},
+		},
+		{
+			name:   "empty lines and generated-only segments",
+			source: `{"version":3,"sources":["a.scss","b.scss"],"mappings":";;EAEE,C,ECAF"}`,
+			want: []Mapping{
+				{GeneratedLine: 2, GeneratedColumn: 2, OriginalLine: 2, OriginalColumn: 2},
+				{GeneratedLine: 2, GeneratedColumn: 5, SourceIndex: 1, OriginalLine: 2, OriginalColumn: 0},
+			},
+		},
+		{
+			name:   "unsupported version",
+			source: `{"version":2,"mappings":""}`,
+			err:    true,
+		},
+		{
+			name:   "truncated value",
+			source: `{"version":3,"mappings":"AAAg"}`,
+			err:    true,
+		},
+		{
+			name:   "invalid segment",
+			source: `{"version":3,"mappings":"AA"}`,
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sm, err := ParseSourceMap([]byte(tt.source))
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %v", sm.Mappings)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(tt.want) != fmt.Sprint(sm.Mappings) {
+				t.Errorf("\nwant: %v\ngot:  %v", tt.want, sm.Mappings)
+			}
+		})
+	}
+}
@@ -565,14 +565,13 @@ type Chunk struct {
 }
 
 type ChunkBuilder struct {
-	inputSourceMap      *SourceMap
-	sourceMap           []byte
-	prevLoc             loc.Loc
-	prevState           SourceMapState
-	lastGeneratedUpdate int
-	generatedColumn     int
-	hasPrevState        bool
-	lineOffsetTables    []LineOffsetTable
+	inputSourceMap   *SourceMap
+	sourceMap        []byte
+	prevLoc          loc.Loc
+	prevState        SourceMapState
+	generatedColumn  int
+	hasPrevState     bool
+	lineOffsetTables []LineOffsetTable
 
 	// This is a workaround for a bug in the popular "source-map" library:
 	// https://github.com/mozilla/source-map/issues/261. The library will
@@ -584,6 +583,11 @@ type ChunkBuilder struct {
 	// to avoid replicating the previous mapping if we don't need to.
 	lineStartsWithMapping     bool
 	coverLinesWithoutMappings bool
+
+	// pendingOutput is the end of the output passed to AddOutput that can't be
+	// scanned yet, because it's a "\r" that may be followed by a "\n", or an
+	// incomplete UTF-8 sequence
+	pendingOutput string
 }
 
 func MakeChunkBuilder(inputSourceMap *SourceMap, lineOffsetTables []LineOffsetTable) ChunkBuilder {
@@ -637,7 +641,59 @@ func (b *ChunkBuilder) GetLineAndColumnForLocation(location loc.Loc) []int {
 	return []int{originalLine + 1, originalColumn + 1}
 }
 
-func (b *ChunkBuilder) AddSourceMapping(location loc.Loc, output []byte) {
+// OffsetLocation returns the location that is lines lines and columns UTF-16
+// code units after location. Like source map positions, columns are relative to
+// the column of location on its own line, and to the start of the line otherwise.
+func (b *ChunkBuilder) OffsetLocation(location loc.Loc, lines int, columns int) loc.Loc {
+	// Binary search to find the line
+	lineOffsetTables := b.lineOffsetTables
+	count := len(lineOffsetTables)
+	originalLine := 0
+	for count > 0 {
+		step := count / 2
+		i := originalLine + step
+		if lineOffsetTables[i].byteOffsetToStartOfLine <= location.Start {
+			originalLine = i + 1
+			count = count - step - 1
+		} else {
+			count = step
+		}
+	}
+	originalLine--
+
+	line := &lineOffsetTables[originalLine]
+	column := columns
+	if lines == 0 {
+		column += location.Start - line.byteOffsetToStartOfLine
+		if line.columnsForNonASCII != nil && location.Start-line.byteOffsetToStartOfLine >= line.byteOffsetToFirstNonASCII {
+			column = line.columnsForNonASCII[location.Start-line.byteOffsetToStartOfLine-line.byteOffsetToFirstNonASCII] + columns
+		}
+	}
+	if originalLine+lines >= len(lineOffsetTables) {
+		return location
+	}
+	line = &lineOffsetTables[originalLine+lines]
+
+	// Use the column to compute the byte offset
+	offset := column
+	if line.columnsForNonASCII != nil && column > line.byteOffsetToFirstNonASCII {
+		offset = line.byteOffsetToFirstNonASCII + len(line.columnsForNonASCII) - 1
+		for i, c := range line.columnsForNonASCII {
+			if c >= column {
+				offset = line.byteOffsetToFirstNonASCII + i
+				break
+			}
+		}
+	}
+	// Columns past the end of the line point at the end of the line
+	if next := originalLine + lines + 1; next < len(lineOffsetTables) && line.byteOffsetToStartOfLine+offset >= lineOffsetTables[next].byteOffsetToStartOfLine {
+		return loc.Loc{Start: lineOffsetTables[next].byteOffsetToStartOfLine - 1}
+	}
+	return loc.Loc{Start: line.byteOffsetToStartOfLine + offset}
+}
+
+// AddSourceMapping maps the end of the output passed to AddOutput so far to location
+func (b *ChunkBuilder) AddSourceMapping(location loc.Loc) {
 	if location == b.prevLoc {
 		return
 	}
@@ -666,7 +722,7 @@ func (b *ChunkBuilder) AddSourceMapping(location loc.Loc, output []byte) {
 		originalColumn = int(line.columnsForNonASCII[originalColumn-int(line.byteOffsetToFirstNonASCII)])
 	}
 
-	b.updateGeneratedLineAndColumn(output)
+	b.flushOutput()
 
 	// If this line doesn't start with a mapping and we're about to add a mapping
 	// that's not at the start, insert a mapping first so the line starts with one.
@@ -691,8 +747,8 @@ func (b *ChunkBuilder) AddSourceMapping(location loc.Loc, output []byte) {
 	b.lineStartsWithMapping = true
 }
 
-func (b *ChunkBuilder) GenerateChunk(output []byte) Chunk {
-	b.updateGeneratedLineAndColumn(output)
+func (b *ChunkBuilder) GenerateChunk() Chunk {
+	b.flushOutput()
 	shouldIgnore := true
 	for _, c := range b.sourceMap {
 		if c != ';' {
@@ -708,17 +764,43 @@ func (b *ChunkBuilder) GenerateChunk(output []byte) Chunk {
 	}
 }
 
-// Scan over the printed text since the last source mapping and update the
-// generated line and column numbers
-func (b *ChunkBuilder) updateGeneratedLineAndColumn(output []byte) {
-	for i, c := range string(output[b.lastGeneratedUpdate:]) {
+// AddOutput scans text, which was just printed after the output passed to
+// AddOutput before, to keep track of where the next mapping is generated. The
+// output doesn't need to be kept, so it can be streamed somewhere else.
+func (b *ChunkBuilder) AddOutput(text string) {
+	if b.pendingOutput != "" {
+		text = b.pendingOutput + text
+		b.pendingOutput = ""
+	}
+	b.updateGeneratedLineAndColumn(text, false)
+}
+
+// flushOutput scans the end of the output that was held back by AddOutput,
+// as if nothing will be printed after it
+func (b *ChunkBuilder) flushOutput() {
+	if b.pendingOutput != "" {
+		text := b.pendingOutput
+		b.pendingOutput = ""
+		b.updateGeneratedLineAndColumn(text, true)
+	}
+}
+
+// Scan over the printed text and update the generated line and column
+// numbers. Unless final is set, the end of text is held back if it depends on
+// what's printed next.
+func (b *ChunkBuilder) updateGeneratedLineAndColumn(text string, final bool) {
+	for i, c := range text {
 		switch c {
 		case '\r', '\n', '\u2028', '\u2029':
 			// Handle Windows-specific "\r\n" newlines
 			if c == '\r' {
-				newlineCheck := b.lastGeneratedUpdate + i + 1
-				if newlineCheck < len(output) && output[newlineCheck] == '\n' {
-					continue
+				if i+1 < len(text) {
+					if text[i+1] == '\n' {
+						continue
+					}
+				} else if !final {
+					b.pendingOutput = text[i:]
+					return
 				}
 			}
 
@@ -743,6 +825,11 @@ func (b *ChunkBuilder) updateGeneratedLineAndColumn(output []byte) {
 			b.lineStartsWithMapping = false
 
 		default:
+			// A character split between two calls is scanned once it's complete
+			if c == utf8.RuneError && !final && !utf8.FullRuneInString(text[i:]) {
+				b.pendingOutput = text[i:]
+				return
+			}
 			// Mozilla's "source-map" library counts columns using UTF-16 code units
 			if c <= 0xFFFF {
 				b.generatedColumn++
@@ -751,8 +838,6 @@ func (b *ChunkBuilder) updateGeneratedLineAndColumn(output []byte) {
 			}
 		}
 	}
-
-	b.lastGeneratedUpdate = len(output)
 }
 
 func (b *ChunkBuilder) appendMapping(currentState SourceMapState) {
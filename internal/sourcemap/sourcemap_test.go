@@ -0,0 +1,45 @@
+package sourcemap
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/withastro/compiler/internal/loc"
+)
+
+func TestChunkBuilderAddOutput(t *testing.T) {
+	source := "<div>\n  <p>héllo</p>\n</div>\n"
+	// Mappings are added before each piece, at the offset in the source
+	pieces := []struct {
+		text     string
+		location int
+	}{
+		{"const a = 1;\r\n", 0},
+		{"$$render`<div>", 0},
+		{"\r\n  <p>héllo 𝒳</p> ", 8},
+		{"\r", 10},
+		{"\n</div>`;\n", 23},
+	}
+
+	whole := MakeChunkBuilder(nil, GenerateLineOffsetTables(source, 4))
+	for _, piece := range pieces {
+		whole.AddSourceMapping(loc.Loc{Start: piece.location})
+		whole.AddOutput(piece.text)
+	}
+	expected := whole.GenerateChunk()
+
+	// Splitting the output anywhere, even inside a "\r\n" or a character,
+	// shouldn't change the mappings
+	split := MakeChunkBuilder(nil, GenerateLineOffsetTables(source, 4))
+	for _, piece := range pieces {
+		split.AddSourceMapping(loc.Loc{Start: piece.location})
+		for i := 0; i < len(piece.text); i++ {
+			split.AddOutput(piece.text[i : i+1])
+		}
+	}
+	got := split.GenerateChunk()
+
+	if fmt.Sprintf("%+v", expected) != fmt.Sprintf("%+v", got) {
+		t.Errorf("\nwant: %+v\ngot:  %+v", expected, got)
+	}
+}
@@ -2,4 +2,8 @@ package t
 
 type ParseOptions struct {
 	Position bool
+	// FrontmatterAST breaks the frontmatter down into imports, exports and the code between them
+	FrontmatterAST bool
+	// ExpressionAST parses the JS of each expression into an ESTree tree
+	ExpressionAST bool
 }
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strconv"
 	"strings"
 	"unicode"
@@ -395,7 +396,7 @@ func (z *Tokenizer) skipWhiteSpace() {
 	for {
 		c := z.readByte()
 		if z.err != nil {
-			fmt.Printf("Unexpected character in skipWhiteSpace: \"%v\"\n", string(c))
+			fmt.Fprintf(os.Stderr, "Unexpected character in skipWhiteSpace: \"%v\"\n", string(c))
 			return
 		}
 		if !unicode.IsSpace(rune(c)) {
@@ -426,7 +427,7 @@ loop:
 	for {
 		c := z.readByte()
 		if z.err != nil {
-			fmt.Printf("Unexpected character in loop: \"%v\"\n", string(c))
+			fmt.Fprintf(os.Stderr, "Unexpected character in loop: \"%v\"\n", string(c))
 			break loop
 		}
 		if c != '<' {
@@ -467,7 +468,7 @@ func (z *Tokenizer) readRawEndTag() bool {
 	}
 	c := z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in readRawEndTag: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in readRawEndTag: %v\n", string(c))
 		return false
 	}
 	switch c {
@@ -491,7 +492,7 @@ func (z *Tokenizer) readScript() {
 scriptData:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptData: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptData: %v\n", string(c))
 		return
 	}
 	if c == '<' {
@@ -502,7 +503,7 @@ scriptData:
 scriptDataLessThanSign:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataLessThanSign: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataLessThanSign: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -516,7 +517,7 @@ scriptDataLessThanSign:
 
 scriptDataEndTagOpen:
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEndTagOpen: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataEndTagOpen: %v\n", string(c))
 		return
 	}
 	if z.readRawEndTag() {
@@ -527,7 +528,7 @@ scriptDataEndTagOpen:
 scriptDataEscapeStart:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscapeStart: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataEscapeStart: %v\n", string(c))
 		return
 	}
 	if c == '-' {
@@ -539,7 +540,7 @@ scriptDataEscapeStart:
 scriptDataEscapeStartDash:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscapeStartDash: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataEscapeStartDash: %v\n", string(c))
 		return
 	}
 	if c == '-' {
@@ -551,7 +552,7 @@ scriptDataEscapeStartDash:
 scriptDataEscaped:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscaped: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataEscaped: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -563,7 +564,7 @@ scriptDataEscaped:
 	goto scriptDataEscaped
 
 scriptDataEscapedDash:
-	fmt.Printf("Unexpected character in scriptDataEscapedDash: %v\n", string(c))
+	fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataEscapedDash: %v\n", string(c))
 	c = z.readByte()
 	if z.err != nil {
 		return
@@ -579,7 +580,7 @@ scriptDataEscapedDash:
 scriptDataEscapedDashDash:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscapedDashDash: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataEscapedDashDash: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -595,7 +596,7 @@ scriptDataEscapedDashDash:
 scriptDataEscapedLessThanSign:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscapedLessThanSign: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataEscapedLessThanSign: %v\n", string(c))
 		return
 	}
 	if c == '/' {
@@ -609,7 +610,7 @@ scriptDataEscapedLessThanSign:
 
 scriptDataEscapedEndTagOpen:
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataEscapedEndTagOpen: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataEscapedEndTagOpen: %v\n", string(c))
 		return
 	}
 	if z.readRawEndTag() || z.err != nil {
@@ -622,7 +623,7 @@ scriptDataDoubleEscapeStart:
 	for i := 0; i < len("script"); i++ {
 		c = z.readByte()
 		if z.err != nil {
-			fmt.Printf("Unexpected character in scriptDataDoubleEscapeStart: %v\n", string(c))
+			fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataDoubleEscapeStart: %v\n", string(c))
 			return
 		}
 		if c != "script"[i] && c != "SCRIPT"[i] {
@@ -644,7 +645,7 @@ scriptDataDoubleEscapeStart:
 scriptDataDoubleEscaped:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataDoubleEscaped: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataDoubleEscaped: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -658,7 +659,7 @@ scriptDataDoubleEscaped:
 scriptDataDoubleEscapedDash:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataDoubleEscapedDash: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataDoubleEscapedDash: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -672,7 +673,7 @@ scriptDataDoubleEscapedDash:
 scriptDataDoubleEscapedDashDash:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataDoubleEscapedDashDash: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataDoubleEscapedDashDash: %v\n", string(c))
 		return
 	}
 	switch c {
@@ -688,7 +689,7 @@ scriptDataDoubleEscapedDashDash:
 scriptDataDoubleEscapedLessThanSign:
 	c = z.readByte()
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataDoubleEscapedLessThanSign: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataDoubleEscapedLessThanSign: %v\n", string(c))
 		return
 	}
 	if c == '/' {
@@ -703,7 +704,7 @@ scriptDataDoubleEscapeEnd:
 		goto scriptDataEscaped
 	}
 	if z.err != nil {
-		fmt.Printf("Unexpected character in scriptDataDoubleEscapeEnd: %v\n", string(c))
+		fmt.Fprintf(os.Stderr, "Unexpected character in scriptDataDoubleEscapeEnd: %v\n", string(c))
 		return
 	}
 	goto scriptDataDoubleEscaped
@@ -0,0 +1,163 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/js_scanner"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+// DefaultClientDirectives are the hydration directives supported by Astro
+var DefaultClientDirectives = []string{"load", "idle", "visible", "media", "only"}
+
+// Hydration directives that require a value, and what that value should be
+var clientDirectiveValues = map[string]string{
+	"media": "a media query, like client:media=\"(max-width: 50em)\"",
+	"only":  "a framework hint, like client:only=\"react\"",
+}
+
+// IsClientDirective returns true if `client:<name>` is a known hydration directive
+func IsClientDirective(opts TransformOptions, name string) bool {
+	for _, directive := range getClientDirectives(opts) {
+		if directive == name {
+			return true
+		}
+	}
+	return false
+}
+
+func getClientDirectives(opts TransformOptions) []string {
+	if opts.ClientDirectives == nil {
+		return DefaultClientDirectives
+	}
+	return opts.ClientDirectives
+}
+
+// ValidateClientDirectives reports `client:*` directives that are unknown, are
+// missing a required value, or are used on something that can't be hydrated.
+func ValidateClientDirectives(doc *astro.Node, opts TransformOptions) {
+	typeImports := getTypeOnlyImports(doc)
+	walk(doc, func(n *astro.Node) {
+		if n.Type != astro.ElementNode || n.Expression {
+			return
+		}
+		for _, attr := range n.Attr {
+			if !strings.HasPrefix(attr.Key, "client:") {
+				continue
+			}
+			name := attr.Key[len("client:"):]
+			if !IsClientDirective(opts, name) {
+				text := fmt.Sprintf("Unknown hydration directive \"%s\".", attr.Key)
+				if suggestion := suggestClientDirective(opts, name); suggestion != "" {
+					text += fmt.Sprintf(" Did you mean \"client:%s\"?", suggestion)
+				}
+				doc.AppendDiagnostic(loc.Diagnostic{
+					Severity: loc.ErrorType,
+					Code:     loc.ERROR_UNKNOWN_CLIENT_DIRECTIVE,
+					Text:     text,
+					Range:    GetAttrRange(attr),
+				})
+				continue
+			}
+			if expected, ok := clientDirectiveValues[name]; ok && !hasAttrValue(attr) {
+				doc.AppendDiagnostic(loc.Diagnostic{
+					Severity: loc.ErrorType,
+					Code:     loc.ERROR_MISSING_DIRECTIVE_VALUE,
+					Text:     fmt.Sprintf("The \"%s\" directive requires %s.", attr.Key, expected),
+					Range:    GetAttrRange(attr),
+				})
+			}
+			if !n.Component && !n.CustomElement {
+				target := fmt.Sprintf("<%s>", n.Data)
+				if n.Fragment {
+					target = "<Fragment>"
+				}
+				doc.AppendDiagnostic(loc.Diagnostic{
+					Severity: loc.ErrorType,
+					Code:     loc.ERROR_UNSUPPORTED_DIRECTIVE_TARGET,
+					Text:     fmt.Sprintf("The \"%s\" directive can not be used on %s. Only framework components and custom elements can be hydrated.", attr.Key, target),
+					Range:    GetAttrRange(attr),
+				})
+			} else if n.Component && typeImports[strings.Split(n.Data, ".")[0]] {
+				doc.AppendDiagnostic(loc.Diagnostic{
+					Severity: loc.ErrorType,
+					Code:     loc.ERROR_HYDRATED_TYPE_IMPORT,
+					Text:     fmt.Sprintf("The \"%s\" directive can not be used on %s because it is imported as a type.", attr.Key, n.Data),
+					Range:    GetAttrRange(attr),
+				})
+			}
+		}
+	})
+}
+
+func hasAttrValue(attr astro.Attribute) bool {
+	switch attr.Type {
+	case astro.QuotedAttribute, astro.ExpressionAttribute, astro.TemplateLiteralAttribute:
+		return strings.TrimSpace(attr.Val) != ""
+	case astro.ShorthandAttribute:
+		return true
+	}
+	return false
+}
+
+// getTypeOnlyImports returns the local names of every type-only import in the frontmatter
+func getTypeOnlyImports(doc *astro.Node) map[string]bool {
+	names := make(map[string]bool)
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != astro.FrontmatterNode || c.FirstChild == nil {
+			continue
+		}
+		source := []byte(c.FirstChild.Data)
+		pos, statement := js_scanner.NextImportStatement(source, 0)
+		for pos != -1 {
+			for _, imported := range statement.Imports {
+				if imported.IsType {
+					names[imported.LocalName] = true
+				}
+			}
+			pos, statement = js_scanner.NextImportStatement(source, pos)
+		}
+	}
+	return names
+}
+
+// suggestClientDirective returns the known directive closest to name, if it looks like a typo
+func suggestClientDirective(opts TransformOptions, name string) string {
+	suggestion := ""
+	best := 3
+	for _, directive := range getClientDirectives(opts) {
+		if distance := editDistance(name, directive); distance < best {
+			suggestion = directive
+			best = distance
+		}
+	}
+	return suggestion
+}
+
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,109 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+func TestValidateClientDirectives(t *testing.T) {
+	tests := []struct {
+		name       string
+		source     string
+		directives []string
+		want       []loc.Diagnostic
+	}{
+		{
+			name:   "known",
+			source: `<A client:load /><B client:idle /><C client:visible /><D client:media="(max-width: 50em)" /><E client:only="react" /><my-element client:load />`,
+			want:   []loc.Diagnostic{},
+		},
+		{
+			name:   "unknown",
+			source: `<Component client:viisble />`,
+			want: []loc.Diagnostic{
+				{Severity: loc.ErrorType, Code: loc.ERROR_UNKNOWN_CLIENT_DIRECTIVE, Range: loc.Range{Loc: loc.Loc{Start: 11}, Len: 14}},
+			},
+		},
+		{
+			name:       "configured",
+			source:     `<A client:hover /><B client:load />`,
+			directives: []string{"hover"},
+			want: []loc.Diagnostic{
+				{Severity: loc.ErrorType, Code: loc.ERROR_UNKNOWN_CLIENT_DIRECTIVE, Range: loc.Range{Loc: loc.Loc{Start: 21}, Len: 11}},
+			},
+		},
+		{
+			name:   "missing media query",
+			source: `<Component client:media />`,
+			want: []loc.Diagnostic{
+				{Severity: loc.ErrorType, Code: loc.ERROR_MISSING_DIRECTIVE_VALUE, Range: loc.Range{Loc: loc.Loc{Start: 11}, Len: 12}},
+			},
+		},
+		{
+			name:   "missing framework hint",
+			source: `<Component client:only="" />`,
+			want: []loc.Diagnostic{
+				{Severity: loc.ErrorType, Code: loc.ERROR_MISSING_DIRECTIVE_VALUE, Range: loc.Range{Loc: loc.Loc{Start: 11}, Len: 14}},
+			},
+		},
+		{
+			name:   "html element",
+			source: `<div client:load />`,
+			want: []loc.Diagnostic{
+				{Severity: loc.ErrorType, Code: loc.ERROR_UNSUPPORTED_DIRECTIVE_TARGET, Range: loc.Range{Loc: loc.Loc{Start: 5}, Len: 11}},
+			},
+		},
+		{
+			name:   "type import",
+			source: "---\nimport type A from './A';\nimport { type B, C } from './B';\n---\n<A client:load /><B client:load /><C client:load />",
+			want: []loc.Diagnostic{
+				{Severity: loc.ErrorType, Code: loc.ERROR_HYDRATED_TYPE_IMPORT, Range: loc.Range{Loc: loc.Loc{Start: 72}, Len: 11}},
+				{Severity: loc.ErrorType, Code: loc.ERROR_HYDRATED_TYPE_IMPORT, Range: loc.Range{Loc: loc.Loc{Start: 89}, Len: 11}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			Transform(doc, TransformOptions{ClientDirectives: tt.directives})
+			got := make([]loc.Diagnostic, 0)
+			for _, d := range doc.Diagnostics {
+				if d.Code == loc.WARNING_UNDEFINED_IDENTIFIER {
+					continue
+				}
+				// Only compare the position and kind of each diagnostic
+				d.Text = ""
+				got = append(got, d)
+			}
+			if fmt.Sprint(tt.want) != fmt.Sprint(got) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestClientDirectiveSuggestion(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"viisble", "visible"},
+		{"laod", "load"},
+		{"idel", "idle"},
+		{"hover", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := suggestClientDirective(TransformOptions{}, tt.name); got != tt.want {
+				t.Errorf("suggestClientDirective(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
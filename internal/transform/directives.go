@@ -0,0 +1,227 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+// DirectiveTarget is a bitmask of the nodes a Directive can be used on
+type DirectiveTarget uint32
+
+const (
+	ElementTarget DirectiveTarget = 1 << iota
+	ComponentTarget
+)
+
+// A Directive is a user-defined namespaced attribute, like `analytics:track`,
+// that is compiled to a call to a runtime helper.
+//
+// On elements, the helper is called as `helper(name, value)` and its result is
+// printed into the start tag with no separating space, so it should return
+// serialized attributes with a leading space, like ` data-track="click"`, or
+// an empty string.
+// On components, the helper is called the same way and its result is spread
+// into the component props, so it should return an object.
+type Directive struct {
+	// Namespace is the part of the attribute key before the colon, e.g. `analytics`
+	Namespace string
+	// Specifier is the module that exports the runtime helper
+	Specifier string
+	// Export is the name of the runtime helper export. Defaults to `default`.
+	Export string
+	Target DirectiveTarget
+}
+
+// LocalName returns the identifier the runtime helper is imported as. Hyphens
+// and underscores are dropped, so `foo-bar` and `foo_bar` share a local name
+// and can't both be registered.
+func (d Directive) LocalName() string {
+	name := ""
+	for _, part := range strings.FieldsFunc(d.Namespace, func(r rune) bool { return r == '-' || r == '_' }) {
+		name += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return fmt.Sprintf("$$directive%s", name)
+}
+
+// Namespaces that are handled by the compiler, or are valid in HTML and SVG
+var knownNamespaces = map[string]bool{
+	"client": true,
+	"class":  true,
+	"define": true,
+	"is":     true,
+	"set":    true,
+	"xlink":  true,
+	"xml":    true,
+	"xmlns":  true,
+}
+
+// Directives that are consumed by the compiler and never printed as attributes
+var compilerDirectives = map[string]bool{
+	"class:list":  true,
+	"define:vars": true,
+	"is:raw":      true,
+	"set:html":    true,
+	"set:text":    true,
+}
+
+// IsCompilerDirective returns true if attr is consumed by the compiler and should not be printed
+func IsCompilerDirective(attr astro.Attribute) bool {
+	return compilerDirectives[attr.Key]
+}
+
+// GetDirectiveNamespace returns the namespace of a directive attribute like
+// `analytics:track`, or an empty string if attr is not namespaced.
+func GetDirectiveNamespace(attr astro.Attribute) string {
+	if attr.Namespace != "" {
+		return ""
+	}
+	i := strings.IndexByte(attr.Key, ':')
+	if i <= 0 {
+		return ""
+	}
+	return attr.Key[:i]
+}
+
+// GetDirective returns the user-defined Directive registered for attr, if any.
+// Directives rejected by ValidateDirectiveOptions are never returned.
+func GetDirective(opts TransformOptions, attr astro.Attribute) (Directive, bool) {
+	namespace := GetDirectiveNamespace(attr)
+	if namespace == "" || knownNamespaces[namespace] {
+		return Directive{}, false
+	}
+	for i, directive := range opts.Directives {
+		if directive.Namespace == namespace {
+			if getDirectiveOptionError(opts, i) != "" {
+				return Directive{}, false
+			}
+			return directive, true
+		}
+	}
+	return Directive{}, false
+}
+
+// isValidDirectiveNamespace returns true if namespace starts with a letter and
+// only contains letters, digits, hyphens and underscores
+func isValidDirectiveNamespace(namespace string) bool {
+	if namespace == "" {
+		return false
+	}
+	for i, c := range namespace {
+		isLetter := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !isLetter && (i == 0 || !((c >= '0' && c <= '9') || c == '-' || c == '_')) {
+			return false
+		}
+	}
+	return true
+}
+
+// getDirectiveOptionError returns why the directive at index i of
+// opts.Directives can't be compiled, or an empty string if it can
+func getDirectiveOptionError(opts TransformOptions, i int) string {
+	directive := opts.Directives[i]
+	if !isValidDirectiveNamespace(directive.Namespace) {
+		return fmt.Sprintf("The directive namespace \"%s\" is invalid. Namespaces must start with a letter and only contain letters, digits, hyphens and underscores.", directive.Namespace)
+	}
+	if knownNamespaces[directive.Namespace] {
+		return fmt.Sprintf("The directive namespace \"%s\" is reserved.", directive.Namespace)
+	}
+	for _, other := range opts.Directives[:i] {
+		if other.Namespace == directive.Namespace {
+			return fmt.Sprintf("The directive namespace \"%s\" is registered more than once.", directive.Namespace)
+		}
+		if other.LocalName() == directive.LocalName() {
+			return fmt.Sprintf("The directive namespaces \"%s\" and \"%s\" can not both be registered, because they only differ by hyphens or underscores.", other.Namespace, directive.Namespace)
+		}
+	}
+	return ""
+}
+
+// ValidateDirectiveOptions reports registered directives that can't be
+// compiled: invalid or reserved namespaces, and namespaces that would be
+// imported under the same local name as an earlier directive
+func ValidateDirectiveOptions(doc *astro.Node, opts TransformOptions) {
+	for i := range opts.Directives {
+		if text := getDirectiveOptionError(opts, i); text != "" {
+			doc.AppendDiagnostic(loc.Diagnostic{
+				Severity: loc.ErrorType,
+				Code:     loc.ERROR_INVALID_DIRECTIVE_OPTION,
+				Text:     text,
+			})
+		}
+	}
+}
+
+// SupportsNode returns true if the directive can be used on n
+func (d Directive) SupportsNode(n *astro.Node) bool {
+	if n.Component || n.CustomElement || n.Fragment {
+		return d.Target&ComponentTarget != 0
+	}
+	return d.Target&ElementTarget != 0
+}
+
+// GetUsedDirectives returns the user-defined directives used in the document, in registration order
+func GetUsedDirectives(doc *astro.Node, opts TransformOptions) []Directive {
+	if len(opts.Directives) == 0 {
+		return nil
+	}
+	used := make(map[string]bool)
+	walk(doc, func(n *astro.Node) {
+		for _, attr := range n.Attr {
+			if directive, ok := GetDirective(opts, attr); ok && directive.SupportsNode(n) {
+				used[directive.Namespace] = true
+			}
+		}
+	})
+	directives := make([]Directive, 0)
+	for _, directive := range opts.Directives {
+		if used[directive.Namespace] {
+			directives = append(directives, directive)
+			// Only import each namespace once
+			delete(used, directive.Namespace)
+		}
+	}
+	return directives
+}
+
+// ValidateDirectives reports namespaced attributes on n that aren't registered,
+// and registered directives that are used on an unsupported node.
+func ValidateDirectives(doc *astro.Node, n *astro.Node, opts TransformOptions) {
+	if n.Type != astro.ElementNode || n.Expression {
+		return
+	}
+	for _, attr := range n.Attr {
+		namespace := GetDirectiveNamespace(attr)
+		if namespace == "" || knownNamespaces[namespace] {
+			continue
+		}
+		directive, ok := GetDirective(opts, attr)
+		if !ok {
+			// Namespaces like `x-on:click` are left alone for client-side frameworks
+			if strings.ContainsRune(namespace, '-') {
+				continue
+			}
+			doc.AppendDiagnostic(loc.Diagnostic{
+				Severity: loc.WarningType,
+				Code:     loc.WARNING_UNKNOWN_DIRECTIVE,
+				Text:     fmt.Sprintf("Unknown directive \"%s\". Register the \"%s\" namespace in the compiler options to use it as a directive.", attr.Key, namespace),
+				Range:    GetAttrRange(attr),
+			})
+			continue
+		}
+		if !directive.SupportsNode(n) {
+			target := "HTML elements"
+			if n.Component || n.CustomElement || n.Fragment {
+				target = "components"
+			}
+			doc.AppendDiagnostic(loc.Diagnostic{
+				Severity: loc.ErrorType,
+				Code:     loc.ERROR_UNSUPPORTED_DIRECTIVE_TARGET,
+				Text:     fmt.Sprintf("The \"%s\" directive can not be used on %s.", attr.Key, target),
+				Range:    GetAttrRange(attr),
+			})
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+func TestValidateDirectives(t *testing.T) {
+	directives := []Directive{
+		{Namespace: "analytics", Specifier: "analytics", Target: ElementTarget | ComponentTarget},
+		{Namespace: "tooltip", Specifier: "tooltip", Target: ElementTarget},
+	}
+	tests := []struct {
+		name   string
+		source string
+		want   []loc.Diagnostic
+	}{
+		{
+			name:   "registered",
+			source: `<div analytics:track="a" tooltip:text={b} />`,
+			want:   []loc.Diagnostic{},
+		},
+		{
+			name:   "built-in",
+			source: `<div set:html={a} class:list={b} is:raw define:vars={c} />`,
+			want:   []loc.Diagnostic{},
+		},
+		{
+			name:   "framework namespace",
+			source: `<div x-on:click="a" />`,
+			want:   []loc.Diagnostic{},
+		},
+		{
+			name:   "unregistered",
+			source: `<div foo:bar="baz" />`,
+			want: []loc.Diagnostic{
+				{Severity: loc.WarningType, Code: loc.WARNING_UNKNOWN_DIRECTIVE, Range: loc.Range{Loc: loc.Loc{Start: 5}, Len: 13}},
+			},
+		},
+		{
+			name:   "unsupported target",
+			source: `<Component tooltip:text={a} analytics:track />`,
+			want: []loc.Diagnostic{
+				{Severity: loc.ErrorType, Code: loc.ERROR_UNSUPPORTED_DIRECTIVE_TARGET, Range: loc.Range{Loc: loc.Loc{Start: 11}, Len: 16}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			Transform(doc, TransformOptions{Directives: directives})
+			got := make([]loc.Diagnostic, 0)
+			for _, d := range doc.Diagnostics {
+				if d.Code == loc.WARNING_UNDEFINED_IDENTIFIER {
+					continue
+				}
+				// Only compare the position and kind of each diagnostic
+				d.Text = ""
+				got = append(got, d)
+			}
+			if fmt.Sprint(tt.want) != fmt.Sprint(got) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
+
+func TestValidateDirectiveOptions(t *testing.T) {
+	directives := []Directive{
+		{Namespace: "analytics", Specifier: "analytics"},
+		{Namespace: "foo-bar", Specifier: "foo-bar"},
+		{Namespace: "foo_bar", Specifier: "foo_bar"},
+		{Namespace: "analytics", Specifier: "other"},
+		{Namespace: "a.b", Specifier: "a.b"},
+		{Namespace: "1st", Specifier: "1st"},
+		{Namespace: "client", Specifier: "client"},
+	}
+	doc, err := astro.Parse(strings.NewReader(`<div />`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ValidateDirectiveOptions(doc, TransformOptions{Directives: directives})
+	want := []string{
+		`The directive namespaces "foo-bar" and "foo_bar" can not both be registered, because they only differ by hyphens or underscores.`,
+		`The directive namespace "analytics" is registered more than once.`,
+		`The directive namespace "a.b" is invalid. Namespaces must start with a letter and only contain letters, digits, hyphens and underscores.`,
+		`The directive namespace "1st" is invalid. Namespaces must start with a letter and only contain letters, digits, hyphens and underscores.`,
+		`The directive namespace "client" is reserved.`,
+	}
+	got := make([]string, 0)
+	for _, d := range doc.Diagnostics {
+		if d.Code != loc.ERROR_INVALID_DIRECTIVE_OPTION {
+			t.Errorf("unexpected diagnostic %v", d)
+		}
+		got = append(got, d.Text)
+	}
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("\n  want: %v\n  got:  %v", want, got)
+	}
+}
@@ -0,0 +1,165 @@
+package transform
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/js_scanner"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+// Built-in classes that Astro knows how to serialize
+var serializableConstructors = map[string]bool{
+	"Date":   true,
+	"Map":    true,
+	"Set":    true,
+	"RegExp": true,
+	"URL":    true,
+}
+
+// Initializers are followed through at most this many variables, to avoid cycles
+const maxBindingDepth = 8
+
+const unknownSpread = "a spread of an object whose contents are not known at compile time"
+
+// ValidateHydratedProps warns about props passed to hydrated components that
+// can't be serialized for the browser, like functions and class instances.
+func ValidateHydratedProps(doc *astro.Node) {
+	declarations := getFrontmatterDeclarations(doc)
+	components := make([]*astro.Node, 0, len(doc.HydratedComponents)+len(doc.ClientOnlyComponents))
+	components = append(components, doc.HydratedComponents...)
+	components = append(components, doc.ClientOnlyComponents...)
+	for _, n := range components {
+		for _, attr := range n.Attr {
+			if strings.HasPrefix(attr.Key, "client:") || strings.HasPrefix(attr.Key, "set:") || attr.Key == "slot" {
+				continue
+			}
+			var problem string
+			var name string
+			r := loc.Range{Loc: attr.ValLoc, Len: len(attr.Val)}
+			switch attr.Type {
+			case astro.ExpressionAttribute:
+				name = attr.Key
+				problem = getSerializationProblem([]byte(attr.Val), declarations, 0)
+			case astro.ShorthandAttribute:
+				name = strings.TrimSpace(attr.Key)
+				problem = getSerializationProblem([]byte(name), declarations, 0)
+				r = loc.Range{Loc: attr.KeyLoc, Len: len(attr.Key)}
+			case astro.SpreadAttribute:
+				name = "..." + strings.TrimSpace(attr.Key)
+				problem = getSpreadProblem([]byte(attr.Key), declarations, 0)
+				r = loc.Range{Loc: attr.KeyLoc, Len: len(attr.Key)}
+			}
+			if problem == "" {
+				continue
+			}
+			consequence := "which can not be serialized for the browser"
+			if strings.HasSuffix(problem, unknownSpread) {
+				consequence = "which may not be serializable for the browser"
+			}
+			doc.AppendDiagnostic(loc.Diagnostic{
+				Severity: loc.WarningType,
+				Code:     loc.WARNING_NON_SERIALIZABLE_PROP,
+				Text:     fmt.Sprintf("The \"%s\" prop of hydrated component <%s> is %s, %s.", name, n.Data, problem, consequence),
+				Range:    r,
+			})
+		}
+	}
+}
+
+func getFrontmatterDeclarations(doc *astro.Node) map[string]js_scanner.Declaration {
+	declarations := make(map[string]js_scanner.Declaration)
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != astro.FrontmatterNode || c.FirstChild == nil {
+			continue
+		}
+		for _, declaration := range js_scanner.GetTopLevelDeclarations([]byte(c.FirstChild.Data)) {
+			declarations[declaration.Name] = declaration
+		}
+	}
+	return declarations
+}
+
+// getSerializationProblem describes why the expression can't be serialized,
+// or returns an empty string if it can be or if that can't be determined.
+func getSerializationProblem(expr []byte, declarations map[string]js_scanner.Declaration, depth int) string {
+	expr = bytes.TrimSpace(expr)
+	if len(expr) == 0 || depth > maxBindingDepth {
+		return ""
+	}
+	if js_scanner.IsFunctionExpression(expr) {
+		return "a function"
+	}
+	if constructor, ok := js_scanner.GetNewExpression(expr); ok {
+		if serializableConstructors[constructor] {
+			return ""
+		}
+		return fmt.Sprintf("an instance of %s", constructor)
+	}
+	if declaration, ok := declarations[string(expr)]; ok {
+		switch declaration.Kind {
+		case js_scanner.FunctionDeclaration:
+			return fmt.Sprintf("the function %s", declaration.Name)
+		case js_scanner.ClassDeclaration:
+			return fmt.Sprintf("the class %s", declaration.Name)
+		}
+		// Destructured variables share their initializer, which can't be followed
+		if !declaration.Destructured {
+			return getSerializationProblem(declaration.Init, declarations, depth+1)
+		}
+		return ""
+	}
+	if properties, ok := js_scanner.GetObjectProperties(expr); ok {
+		for _, property := range properties {
+			kind, _, value := js_scanner.GetObjectProperty(property)
+			var problem string
+			switch kind {
+			case js_scanner.MethodProperty:
+				problem = "a function"
+			case js_scanner.SpreadProperty:
+				problem = getSpreadProblem(value, declarations, depth)
+			default:
+				problem = getSerializationProblem(value, declarations, depth)
+			}
+			if problem != "" {
+				return fmt.Sprintf("an object containing %s", problem)
+			}
+		}
+		return ""
+	}
+	if elements, ok := js_scanner.GetArrayElements(expr); ok {
+		for _, element := range elements {
+			var problem string
+			if bytes.HasPrefix(element, []byte("...")) {
+				problem = getSpreadProblem(element[3:], declarations, depth)
+			} else {
+				problem = getSerializationProblem(element, declarations, depth)
+			}
+			if problem != "" {
+				return fmt.Sprintf("an array containing %s", problem)
+			}
+		}
+	}
+	return ""
+}
+
+// getSpreadProblem is like getSerializationProblem, but also reports spreading
+// objects whose contents aren't known at compile time.
+func getSpreadProblem(expr []byte, declarations map[string]js_scanner.Declaration, depth int) string {
+	expr = bytes.TrimSpace(expr)
+	if depth > maxBindingDepth {
+		return ""
+	}
+	if _, ok := js_scanner.GetObjectProperties(expr); ok {
+		return getSerializationProblem(expr, declarations, depth)
+	}
+	if _, ok := js_scanner.GetArrayElements(expr); ok {
+		return getSerializationProblem(expr, declarations, depth)
+	}
+	if declaration, ok := declarations[string(expr)]; ok && declaration.Kind == js_scanner.VariableDeclaration && !declaration.Destructured {
+		return getSpreadProblem(declaration.Init, declarations, depth+1)
+	}
+	return unknownSpread
+}
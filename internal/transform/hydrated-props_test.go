@@ -0,0 +1,93 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+func TestValidateHydratedProps(t *testing.T) {
+	frontmatter := `---
+import Counter from './Counter';
+function increment() {}
+class Store {}
+const handler = () => {};
+const options = { step: 1, format: increment };
+const data = { count: 1 };
+const { count } = data;
+---
+`
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "serializable",
+			source: `<Counter client:load count={1} label="a" items={[1, 2]} data={data} date={new Date()} {count} values={list.map((v) => v * 2)} />`,
+			want:   []string{},
+		},
+		{
+			name:   "arrow function",
+			source: `<Counter client:load onClick={() => count++} />`,
+			want:   []string{"() => count++: a function"},
+		},
+		{
+			name:   "frontmatter function",
+			source: `<Counter client:visible onClick={increment} {handler} />`,
+			want:   []string{"increment: the function increment", "handler: a function"},
+		},
+		{
+			name:   "new expression",
+			source: `<Counter client:idle store={new Store()} />`,
+			want:   []string{"new Store(): an instance of Store"},
+		},
+		{
+			name:   "nested",
+			source: `<Counter client:load options={options} list={[{ fn() {} }]} />`,
+			want:   []string{"options: an object containing the function increment", "[{ fn() {} }]: an array containing an object containing a function"},
+		},
+		{
+			name:   "spread",
+			source: `<Counter client:load {...data} {...Astro.props} />`,
+			want:   []string{"Astro.props: " + unknownSpread},
+		},
+		{
+			name:   "client only",
+			source: `<Counter client:only="react" onClick={() => {}} />`,
+			want:   []string{"() => {}: a function"},
+		},
+		{
+			name:   "not hydrated",
+			source: `<Counter onClick={() => {}} />`,
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := frontmatter + tt.source
+			doc, err := astro.Parse(strings.NewReader(source))
+			if err != nil {
+				t.Error(err)
+			}
+			Transform(doc, TransformOptions{})
+			got := make([]string, 0)
+			for _, d := range doc.Diagnostics {
+				if d.Code != loc.WARNING_NON_SERIALIZABLE_PROP {
+					continue
+				}
+				// Each warning should point at the attribute value
+				value := source[d.Range.Loc.Start : d.Range.Loc.Start+d.Range.Len]
+				problem := strings.SplitN(d.Text, " is ", 2)[1]
+				problem = problem[:strings.LastIndex(problem, ", which")]
+				got = append(got, fmt.Sprintf("%s: %s", value, problem))
+			}
+			if fmt.Sprint(tt.want) != fmt.Sprint(got) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/js_scanner"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+// Globals that can be referenced by templates without being declared
+var knownGlobals = map[string]bool{
+	// Astro
+	"Astro":    true,
+	"Fragment": true,
+	// JavaScript
+	"AggregateError":       true,
+	"Array":                true,
+	"ArrayBuffer":          true,
+	"BigInt":               true,
+	"BigInt64Array":        true,
+	"BigUint64Array":       true,
+	"Boolean":              true,
+	"DataView":             true,
+	"Date":                 true,
+	"decodeURI":            true,
+	"decodeURIComponent":   true,
+	"encodeURI":            true,
+	"encodeURIComponent":   true,
+	"Error":                true,
+	"escape":               true,
+	"eval":                 true,
+	"EvalError":            true,
+	"FinalizationRegistry": true,
+	"Float32Array":         true,
+	"Float64Array":         true,
+	"Function":             true,
+	"globalThis":           true,
+	"Infinity":             true,
+	"Int16Array":           true,
+	"Int32Array":           true,
+	"Int8Array":            true,
+	"Intl":                 true,
+	"isFinite":             true,
+	"isNaN":                true,
+	"JSON":                 true,
+	"Map":                  true,
+	"Math":                 true,
+	"NaN":                  true,
+	"Number":               true,
+	"Object":               true,
+	"parseFloat":           true,
+	"parseInt":             true,
+	"Promise":              true,
+	"Proxy":                true,
+	"RangeError":           true,
+	"ReferenceError":       true,
+	"Reflect":              true,
+	"RegExp":               true,
+	"Set":                  true,
+	"String":               true,
+	"Symbol":               true,
+	"SyntaxError":          true,
+	"TypeError":            true,
+	"Uint16Array":          true,
+	"Uint32Array":          true,
+	"Uint8Array":           true,
+	"Uint8ClampedArray":    true,
+	"undefined":            true,
+	"unescape":             true,
+	"URIError":             true,
+	"WeakMap":              true,
+	"WeakRef":              true,
+	"WeakSet":              true,
+	// Browser and Node
+	"AbortController": true,
+	"AbortSignal":     true,
+	"atob":            true,
+	"Blob":            true,
+	"btoa":            true,
+	"Buffer":          true,
+	"clearInterval":   true,
+	"clearTimeout":    true,
+	"console":         true,
+	"crypto":          true,
+	"CustomEvent":     true,
+	"document":        true,
+	"Event":           true,
+	"EventTarget":     true,
+	"fetch":           true,
+	"File":            true,
+	"FormData":        true,
+	"global":          true,
+	"Headers":         true,
+	"localStorage":    true,
+	"location":        true,
+	"navigator":       true,
+	"performance":     true,
+	"process":         true,
+	"queueMicrotask":  true,
+	"ReadableStream":  true,
+	"Request":         true,
+	"require":         true,
+	"Response":        true,
+	"sessionStorage":  true,
+	"setInterval":     true,
+	"setTimeout":      true,
+	"structuredClone": true,
+	"TextDecoder":     true,
+	"TextEncoder":     true,
+	"URL":             true,
+	"URLSearchParams": true,
+	"window":          true,
+}
+
+// ValidateIdentifiers warns about identifiers in template expressions and
+// component names that aren't declared in the frontmatter or known globals.
+func ValidateIdentifiers(doc *astro.Node) {
+	bindings := getFrontmatterBindings(doc)
+	walkTemplateReferences(doc, func(name string, start loc.Loc, shadowed bool) {
+		if shadowed || bindings[name] || knownGlobals[name] {
+			return
+		}
+		doc.AppendDiagnostic(loc.Diagnostic{
+			Severity: loc.WarningType,
+			Code:     loc.WARNING_UNDEFINED_IDENTIFIER,
+			Text:     fmt.Sprintf("\"%s\" is not defined. Import or declare it in the frontmatter.", name),
+			Range:    loc.Range{Loc: start, Len: len(name)},
+		})
+	})
+}
+
+// walkTemplateReferences calls cb for every identifier read by the template:
+// component names and identifiers in expressions and expression attributes,
+// including the attributes of extracted styles and scripts.
+// shadowed is true if the identifier refers to a binding declared by an
+// enclosing expression, like the parameter of `items.map((item) => ...)`.
+func walkTemplateReferences(doc *astro.Node, cb func(name string, start loc.Loc, shadowed bool)) {
+	var visit func(n *astro.Node, scope map[string]bool)
+	visit = func(n *astro.Node, scope map[string]bool) {
+		if n.Type != astro.ElementNode {
+			return
+		}
+		if n.Component && !n.Fragment && len(n.Loc) > 0 {
+			name := strings.Split(n.Data, ".")[0]
+			cb(name, n.Loc[0], scope[name])
+		}
+		for _, attr := range n.Attr {
+			switch attr.Type {
+			case astro.ExpressionAttribute:
+				visitReferences(attr.Val, attr.ValLoc, scope, cb)
+			case astro.ShorthandAttribute, astro.SpreadAttribute:
+				visitReferences(attr.Key, attr.KeyLoc, scope, cb)
+			case astro.TemplateLiteralAttribute:
+				// Offset by the opening backtick, which isn't part of the value
+				visitReferences("`"+attr.Val+"`", loc.Loc{Start: attr.ValLoc.Start - 1}, scope, cb)
+			}
+		}
+		// Raw elements, scripts and styles don't contain expressions
+		if n.Data == "script" || n.Data == "style" || hasTruthyAttr(n, "is:raw") {
+			return
+		}
+		if n.Expression {
+			// Bindings declared by an expression are visible to every expression nested inside of it
+			locals := make([]string, 0)
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == astro.TextNode {
+					locals = append(locals, js_scanner.GetLocalBindings([]byte(c.Data))...)
+				}
+			}
+			if len(locals) > 0 {
+				nested := make(map[string]bool, len(scope)+len(locals))
+				for name := range scope {
+					nested[name] = true
+				}
+				for _, name := range locals {
+					nested[name] = true
+				}
+				scope = nested
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == astro.TextNode && len(c.Loc) > 0 {
+					visitReferences(c.Data, c.Loc[0], scope, cb)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c, scope)
+		}
+	}
+	scope := make(map[string]bool)
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		visit(c, scope)
+	}
+	// Extracted styles and hoisted scripts have been removed from the tree, but
+	// their attributes, like `define:vars`, are still read by the template
+	for _, n := range append(append([]*astro.Node{}, doc.Styles...), doc.Scripts...) {
+		if n.Parent == nil {
+			visit(n, scope)
+		}
+	}
+}
+
+func visitReferences(source string, start loc.Loc, scope map[string]bool, cb func(name string, start loc.Loc, shadowed bool)) {
+	locals := make(map[string]bool)
+	for _, name := range js_scanner.GetLocalBindings([]byte(source)) {
+		locals[name] = true
+	}
+	for _, reference := range js_scanner.GetReferences([]byte(source)) {
+		cb(reference.Name, loc.Loc{Start: start.Start + reference.Start}, scope[reference.Name] || locals[reference.Name])
+	}
+}
+
+// getFrontmatterBindings returns the names imported or declared at the top level of the frontmatter
+func getFrontmatterBindings(doc *astro.Node) map[string]bool {
+	bindings := make(map[string]bool)
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != astro.FrontmatterNode || c.FirstChild == nil {
+			continue
+		}
+		source := []byte(c.FirstChild.Data)
+		pos, statement := js_scanner.NextImportStatement(source, 0)
+		for pos != -1 {
+			for _, imported := range statement.Imports {
+				bindings[imported.LocalName] = true
+			}
+			pos, statement = js_scanner.NextImportStatement(source, pos)
+		}
+		for _, declaration := range js_scanner.GetTopLevelDeclarations(source) {
+			bindings[declaration.Name] = true
+		}
+	}
+	return bindings
+}
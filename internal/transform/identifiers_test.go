@@ -0,0 +1,95 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+func TestValidateIdentifiers(t *testing.T) {
+	frontmatter := `---
+import Layout from '../layouts/Layout.astro';
+import * as icons from '../icons';
+import { format as formatDate } from 'date-fns';
+const { title, items = [] } = Astro.props;
+const [first] = items;
+function slugify(str) {}
+let count = 0;
+---
+`
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name:   "declared",
+			source: `<Layout title={title}><h1>{formatDate(first.date)}</h1><p>{slugify(title)} {count + 1}</p><icons.Star /></Layout>`,
+			want:   []string{},
+		},
+		{
+			name:   "globals",
+			source: `<Fragment><p>{Astro.url.pathname} {JSON.stringify(items)} {new Date().getFullYear()} {Math.max(count, 1)}</p></Fragment>`,
+			want:   []string{},
+		},
+		{
+			name:   "component typo",
+			source: `<Compnent /><Layuot.Header />`,
+			want:   []string{"Compnent", "Layuot"},
+		},
+		{
+			name:   "expression",
+			source: `<h1>{titel}</h1><p>{items.length > 0 ? itmes[0] : fallback}</p>`,
+			want:   []string{"titel", "itmes", "fallback"},
+		},
+		{
+			name:   "attributes",
+			source: `<a href={url} {target} {...props} title={{ text: title }.text} class=` + "`a ${cls}`" + `></a>`,
+			want:   []string{"url", "target", "props", "cls"},
+		},
+		{
+			name:   "nested scopes",
+			source: `<ul>{items.map((item, i) => <li data-index={i}>{item.name} {index}</li>)}</ul>`,
+			want:   []string{"index"},
+		},
+		{
+			name:   "local declarations",
+			source: `<p>{(() => { const local = 1; return local + other; })()}</p>`,
+			want:   []string{"other"},
+		},
+		{
+			name:   "properties",
+			source: `<p>{title.length} {items?.at(0)} {({ key: 'value' }).key}</p>`,
+			want:   []string{},
+		},
+		{
+			name:   "raw",
+			source: `<script>console.log(unknown)</script><style>a { color: red }</style><p is:raw>{unknown}</p>`,
+			want:   []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := frontmatter + tt.source
+			doc, err := astro.Parse(strings.NewReader(source))
+			if err != nil {
+				t.Error(err)
+			}
+			Transform(doc, TransformOptions{})
+			got := make([]string, 0)
+			for _, d := range doc.Diagnostics {
+				if d.Code != loc.WARNING_UNDEFINED_IDENTIFIER {
+					continue
+				}
+				// Each warning should point at the identifier itself
+				got = append(got, source[d.Range.Loc.Start:d.Range.Loc.Start+d.Range.Len])
+			}
+			if fmt.Sprint(tt.want) != fmt.Sprint(got) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+	a "golang.org/x/net/html/atom"
+)
+
+// A StylePreprocessor transforms the contents of a <style> element, like
+// compiling `<style lang="scss">` to CSS. attrs holds the element's static
+// attributes. Returning an empty code string leaves the style unchanged, and
+// sourcemap may be empty if the preprocessor doesn't generate one.
+type StylePreprocessor interface {
+	Process(ctx context.Context, content string, attrs map[string]string) (code string, sourcemap string, err error)
+}
+
+// A ScriptPreprocessor transforms the contents of a <script> element before it
+// is hoisted, like stripping TypeScript. attrs holds the element's static
+// attributes. Returning an empty code string leaves the script unchanged.
+type ScriptPreprocessor interface {
+	Process(ctx context.Context, content string, attrs map[string]string) (code string, err error)
+}
+
+// A PreprocessorError is returned when a StylePreprocessor or ScriptPreprocessor fails
+type PreprocessorError struct {
+	Err error
+	// Element is the name of the element that failed, either "style" or "script"
+	Element string
+	// Loc is the location of the element
+	Loc loc.Loc
+}
+
+func (e *PreprocessorError) Error() string {
+	return fmt.Sprintf("unable to preprocess <%s> at offset %d: %s", e.Element, e.Loc.Start, e.Err)
+}
+
+func (e *PreprocessorError) Unwrap() error {
+	return e.Err
+}
+
+type preprocessResult struct {
+	code      string
+	sourcemap string
+	err       error
+}
+
+type preprocessFunc func(ctx context.Context, content string, attrs map[string]string) (code string, sourcemap string, err error)
+
+// PreprocessStyles runs opts.PreprocessStyle on every style in doc.Styles
+// concurrently, so it must be called after ExtractStyles and before Transform.
+// It does nothing unless opts.PreprocessStyle is a StylePreprocessor.
+//
+// Every failure is reported as a diagnostic, and the first failure in document
+// order is returned as a *PreprocessorError. Styles that fail are left unchanged.
+func PreprocessStyles(ctx context.Context, doc *astro.Node, opts TransformOptions) error {
+	preprocessor, ok := opts.PreprocessStyle.(StylePreprocessor)
+	if !ok {
+		return nil
+	}
+	results := preprocess(ctx, doc.Styles, preprocessor.Process)
+	return applyPreprocessResults(doc, doc.Styles, results, loc.ERROR_STYLE_PREPROCESSOR)
+}
+
+// PreprocessScripts runs opts.PreprocessScript on every script returned by
+// GetPreprocessableScripts concurrently. It must be called before Transform,
+// which hoists scripts. It does nothing unless opts.PreprocessScript is a ScriptPreprocessor.
+//
+// Errors are handled like PreprocessStyles.
+func PreprocessScripts(ctx context.Context, doc *astro.Node, opts TransformOptions) error {
+	preprocessor, ok := opts.PreprocessScript.(ScriptPreprocessor)
+	if !ok {
+		return nil
+	}
+	scripts := GetPreprocessableScripts(doc)
+	results := preprocess(ctx, scripts, func(ctx context.Context, content string, attrs map[string]string) (string, string, error) {
+		code, err := preprocessor.Process(ctx, content, attrs)
+		return code, "", err
+	})
+	return applyPreprocessResults(doc, scripts, results, loc.ERROR_SCRIPT_PREPROCESSOR)
+}
+
+// GetPreprocessableScripts returns every <script> element whose contents can
+// be preprocessed, in document order. Scripts that set their contents with a
+// `set:*` directive, and external scripts without contents, are skipped.
+func GetPreprocessableScripts(doc *astro.Node) []*astro.Node {
+	scripts := make([]*astro.Node, 0)
+	walk(doc, func(n *astro.Node) {
+		if n.Type == astro.ElementNode && n.DataAtom == a.Script && !HasSetDirective(n) && n.FirstChild != nil {
+			scripts = append(scripts, n)
+		}
+	})
+	return scripts
+}
+
+// preprocess runs fn on the contents of every node concurrently. A panic in fn
+// is returned as the error of that node.
+func preprocess(ctx context.Context, nodes []*astro.Node, fn preprocessFunc) []preprocessResult {
+	results := make([]preprocessResult, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		if n.FirstChild == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, n *astro.Node) {
+			defer wg.Done()
+			// A panic in a goroutine can't be recovered by the caller, and would stop the whole process
+			defer func() {
+				if r := recover(); r != nil {
+					results[i] = preprocessResult{err: fmt.Errorf("preprocessor panicked: %v", r)}
+				}
+			}()
+			code, sourcemap, err := fn(ctx, n.FirstChild.Data, GetStaticAttrs(n))
+			results[i] = preprocessResult{code, sourcemap, err}
+		}(i, n)
+	}
+	wg.Wait()
+	return results
+}
+
+func applyPreprocessResults(doc *astro.Node, nodes []*astro.Node, results []preprocessResult, code loc.DiagnosticCode) error {
+	var first *PreprocessorError
+	for i, n := range nodes {
+		result := results[i]
+		if result.err != nil {
+			err := &PreprocessorError{Err: result.err, Element: n.Data, Loc: n.Loc[0]}
+			// doc.Styles isn't in document order
+			if first == nil || err.Loc.Start < first.Loc.Start {
+				first = err
+			}
+			doc.AppendDiagnostic(loc.Diagnostic{
+				Severity: loc.ErrorType,
+				Code:     code,
+				Text:     fmt.Sprintf("Unable to preprocess <%s>: %s", n.Data, result.err),
+				Range:    loc.Range{Loc: n.Loc[0], Len: len(n.Data)},
+			})
+			continue
+		}
+		if result.code == "" {
+			continue
+		}
+		n.FirstChild.Data = result.code
+		if result.sourcemap != "" {
+			if doc.StyleSourceMaps == nil {
+				doc.StyleSourceMaps = make(map[*astro.Node]string)
+			}
+			doc.StyleSourceMaps[n] = result.sourcemap
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	return first
+}
+
+// GetStaticAttrs returns the quoted and empty attributes of n, which are the
+// only attributes whose values are known at compile time. Empty attributes are
+// given the value "true".
+func GetStaticAttrs(n *astro.Node) map[string]string {
+	attrs := make(map[string]string)
+	for _, attr := range n.Attr {
+		switch attr.Type {
+		case astro.QuotedAttribute:
+			attrs[attr.Key] = attr.Val
+		case astro.EmptyAttribute:
+			attrs[attr.Key] = "true"
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,175 @@
+package transform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+type testStylePreprocessor struct {
+	// Every call waits until this many calls have started, to test that styles are processed concurrently
+	concurrency int
+	mu          sync.Mutex
+	started     int
+	ready       chan struct{}
+}
+
+func (p *testStylePreprocessor) Process(ctx context.Context, content string, attrs map[string]string) (string, string, error) {
+	p.mu.Lock()
+	p.started++
+	if p.started == p.concurrency {
+		close(p.ready)
+	}
+	p.mu.Unlock()
+	select {
+	case <-p.ready:
+	case <-time.After(time.Second):
+		return "", "", errors.New("styles were not processed concurrently")
+	}
+
+	switch attrs["lang"] {
+	case "scss":
+		return strings.ReplaceAll(content, "$color", "red"), `{"version":3,"sources":["style.scss"],"mappings":"AAAA"}`, nil
+	case "fail":
+		return "", "", errors.New("Undefined variable")
+	}
+	return "", "", nil
+}
+
+func TestPreprocessStyles(t *testing.T) {
+	source := `<style lang="scss">a { color: $color; }</style>
+<style lang="fail">b { color: $missing; }</style>
+<style is:global>c { color: blue; }</style>`
+	doc, err := astro.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ExtractStyles(doc)
+	preprocessor := &testStylePreprocessor{concurrency: 3, ready: make(chan struct{})}
+	err = PreprocessStyles(context.Background(), doc, TransformOptions{PreprocessStyle: preprocessor})
+
+	var preprocessorErr *PreprocessorError
+	if !errors.As(err, &preprocessorErr) {
+		t.Fatalf("expected a PreprocessorError, got %v", err)
+	}
+	if want := strings.Index(source, `style lang="fail"`); preprocessorErr.Loc.Start != want {
+		t.Errorf("expected the error at offset %d, got %d", want, preprocessorErr.Loc.Start)
+	}
+	if len(doc.Diagnostics) != 1 || doc.Diagnostics[0].Code != loc.ERROR_STYLE_PREPROCESSOR {
+		t.Errorf("expected a single ERROR_STYLE_PREPROCESSOR diagnostic, got %v", doc.Diagnostics)
+	}
+
+	got := make([]string, 0)
+	for _, style := range doc.Styles {
+		got = append(got, style.FirstChild.Data)
+	}
+	// doc.Styles is in reverse order
+	want := []string{"c { color: blue; }", "b { color: $missing; }", "a { color: red; }"}
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("\nwant: %v\ngot:  %v", want, got)
+	}
+	if len(doc.StyleSourceMaps) != 1 || doc.StyleSourceMaps[doc.Styles[2]] == "" {
+		t.Errorf("expected a source map for the preprocessed style, got %v", doc.StyleSourceMaps)
+	}
+}
+
+func TestPreprocessStylesWithoutPreprocessor(t *testing.T) {
+	doc, err := astro.Parse(strings.NewReader(`<style lang="scss">a { color: $color; }</style>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ExtractStyles(doc)
+	// The WASM build passes JS functions, which are handled by the caller
+	if err := PreprocessStyles(context.Background(), doc, TransformOptions{PreprocessStyle: func() {}}); err != nil {
+		t.Error(err)
+	}
+	if got := doc.Styles[0].FirstChild.Data; got != "a { color: $color; }" {
+		t.Errorf("expected the style to be unchanged, got %q", got)
+	}
+}
+
+type testScriptPreprocessor struct{}
+
+func (testScriptPreprocessor) Process(ctx context.Context, content string, attrs map[string]string) (string, error) {
+	if attrs["lang"] == "fail" {
+		return "", errors.New("Unexpected token")
+	}
+	return strings.ReplaceAll(content, ": string", ""), nil
+}
+
+func TestPreprocessScripts(t *testing.T) {
+	source := `<script>const a: string = 'a';</script>
+<div><script is:inline>const b: string = 'b';</script></div>
+<script set:html={code}></script>
+<script src="/external.js"></script>
+<script lang="fail">const c: string = 'c';</script>`
+	doc, err := astro.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = PreprocessScripts(context.Background(), doc, TransformOptions{PreprocessScript: testScriptPreprocessor{}})
+	var preprocessorErr *PreprocessorError
+	if !errors.As(err, &preprocessorErr) || preprocessorErr.Element != "script" {
+		t.Fatalf("expected a PreprocessorError for a script, got %v", err)
+	}
+	if len(doc.Diagnostics) != 1 || doc.Diagnostics[0].Code != loc.ERROR_SCRIPT_PREPROCESSOR {
+		t.Errorf("expected a single ERROR_SCRIPT_PREPROCESSOR diagnostic, got %v", doc.Diagnostics)
+	}
+
+	got := make([]string, 0)
+	for _, script := range GetPreprocessableScripts(doc) {
+		got = append(got, script.FirstChild.Data)
+	}
+	want := []string{"const a = 'a';", "const b = 'b';", "const c: string = 'c';"}
+	if fmt.Sprint(want) != fmt.Sprint(got) {
+		t.Errorf("\nwant: %v\ngot:  %v", want, got)
+	}
+
+	// Hoisted scripts should contain the preprocessed code
+	Transform(doc, TransformOptions{})
+	if len(doc.Scripts) != 2 || doc.Scripts[1].FirstChild.Data != "const a = 'a';" {
+		t.Errorf("expected the preprocessed script to be hoisted, got %v", doc.Scripts)
+	}
+}
+
+type panickingStylePreprocessor struct{}
+
+func (panickingStylePreprocessor) Process(ctx context.Context, content string, attrs map[string]string) (string, string, error) {
+	if attrs["lang"] == "panic" {
+		panic("nil map")
+	}
+	return strings.ToUpper(content), "", nil
+}
+
+func TestPreprocessStylesPanic(t *testing.T) {
+	source := `<style lang="panic">a { color: red; }</style>
+<style>b { color: blue; }</style>`
+	doc, err := astro.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ExtractStyles(doc)
+	err = PreprocessStyles(context.Background(), doc, TransformOptions{PreprocessStyle: panickingStylePreprocessor{}})
+
+	var preprocessorErr *PreprocessorError
+	if !errors.As(err, &preprocessorErr) || !strings.Contains(err.Error(), "nil map") {
+		t.Fatalf("expected a PreprocessorError for the panic, got %v", err)
+	}
+	if len(doc.Diagnostics) != 1 || doc.Diagnostics[0].Code != loc.ERROR_STYLE_PREPROCESSOR {
+		t.Errorf("expected a single ERROR_STYLE_PREPROCESSOR diagnostic, got %v", doc.Diagnostics)
+	}
+	// doc.Styles is in reverse order
+	if got := doc.Styles[0].FirstChild.Data; got != "B { COLOR: BLUE; }" {
+		t.Errorf("expected the other style to be preprocessed, got %q", got)
+	}
+	if got := doc.Styles[1].FirstChild.Data; got != "a { color: red; }" {
+		t.Errorf("expected the style to be unchanged, got %q", got)
+	}
+}
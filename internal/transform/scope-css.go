@@ -1,28 +1,36 @@
 package transform
 
 import (
-
-	// "strings"
-
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+	"github.com/withastro/compiler/internal/sourcemap"
 	"github.com/withastro/compiler/lib/esbuild/css_parser"
 	"github.com/withastro/compiler/lib/esbuild/css_printer"
 	"github.com/withastro/compiler/lib/esbuild/logger"
+	esbuild_sourcemap "github.com/withastro/compiler/lib/esbuild/sourcemap"
 	a "golang.org/x/net/html/atom"
 )
 
 // Take a slice of DOM nodes, and scope CSS within every <style> tag
 func ScopeStyle(styles []*astro.Node, opts TransformOptions) bool {
+	return scopeStyles(styles, nil, opts)
+}
+
+// scopeStyles is like ScopeStyle, but also updates the source maps returned by
+// style preprocessors so they map the scoped CSS back to the original source
+func scopeStyles(styles []*astro.Node, sourcemaps map[*astro.Node]string, opts TransformOptions) bool {
 	didScope := false
 outer:
 	for _, n := range styles {
 		if n.DataAtom != a.Style {
 			continue
 		}
+		// Deprecated `<style global>` is reported by ValidateGlobalStyles
 		if hasTruthyAttr(n, "global") {
-			fmt.Printf("Found `<style global>` in %s! Please migrate to the `is:global` directive.\n", opts.Filename)
 			continue outer
 		}
 		if hasTruthyAttr(n, "is:global") {
@@ -39,9 +47,54 @@ outer:
 		// Use vendored version of esbuild internals to parse AST
 		tree := css_parser.Parse(logger.Log{AddMsg: func(msg logger.Msg) {}}, logger.Source{Contents: n.FirstChild.Data}, css_parser.Options{MinifySyntax: false, MinifyWhitespace: true})
 		// esbuild's internal `css_printer` has been modified to emit Astro scoped styles
-		result := css_printer.Print(tree, css_printer.Options{MinifyWhitespace: true, Scope: opts.Scope})
+		options := css_printer.Options{MinifyWhitespace: true, Scope: opts.Scope}
+		input, err := sourcemap.ParseSourceMap([]byte(sourcemaps[n]))
+		if err == nil {
+			options.AddSourceMappings = true
+			options.InputSourceMap = toESBuildSourceMap(input)
+			options.LineOffsetTables = esbuild_sourcemap.GenerateLineOffsetTables(n.FirstChild.Data, int32(strings.Count(n.FirstChild.Data, "\n")+1))
+		}
+		result := css_printer.Print(tree, options)
 		n.FirstChild.Data = string(result.CSS)
+		if options.AddSourceMappings {
+			sources, _ := json.Marshal(input.Sources)
+			sourcemaps[n] = fmt.Sprintf(`{"version":3,"sources":%s,"mappings":"%s"}`, sources, result.SourceMapChunk.Buffer)
+		}
 	}
 
 	return didScope
 }
+
+func toESBuildSourceMap(sm *sourcemap.SourceMap) *esbuild_sourcemap.SourceMap {
+	mappings := make([]esbuild_sourcemap.Mapping, len(sm.Mappings))
+	for i, mapping := range sm.Mappings {
+		mappings[i] = esbuild_sourcemap.Mapping{
+			GeneratedLine:   int32(mapping.GeneratedLine),
+			GeneratedColumn: int32(mapping.GeneratedColumn),
+			SourceIndex:     int32(mapping.SourceIndex),
+			OriginalLine:    int32(mapping.OriginalLine),
+			OriginalColumn:  int32(mapping.OriginalColumn),
+		}
+	}
+	return &esbuild_sourcemap.SourceMap{Sources: sm.Sources, Mappings: mappings}
+}
+
+// ValidateGlobalStyles warns about extracted styles that still use the deprecated `global` attribute
+func ValidateGlobalStyles(doc *astro.Node) {
+	for _, n := range doc.Styles {
+		if !hasTruthyAttr(n, "global") {
+			continue
+		}
+		for _, attr := range n.Attr {
+			if attr.Key == "global" {
+				doc.AppendDiagnostic(loc.Diagnostic{
+					Severity: loc.WarningType,
+					Code:     loc.WARNING_DEPRECATED_DIRECTIVE,
+					Text:     "Found `<style global>`! Please migrate to the `is:global` directive.",
+					Range:    GetAttrRange(attr),
+				})
+				break
+			}
+		}
+	}
+}
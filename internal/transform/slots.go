@@ -0,0 +1,120 @@
+package transform
+
+import (
+	"strings"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+	a "golang.org/x/net/html/atom"
+)
+
+// SlotDeclaration describes a <slot> rendered by the current component
+type SlotDeclaration struct {
+	Name        string
+	Dynamic     bool
+	HasFallback bool
+	Loc         loc.Loc
+}
+
+// SlotUsage describes a slot="..." passed to a child component
+type SlotUsage struct {
+	Component string
+	Name      string
+	Dynamic   bool
+	Loc       loc.Loc
+}
+
+// GetDeclaredSlots returns every slot the component renders, in authored order.
+// Static slot names are deduplicated, dynamic names are returned as-is.
+func GetDeclaredSlots(doc *astro.Node) []SlotDeclaration {
+	slots := make([]SlotDeclaration, 0)
+	seen := make(map[string]int)
+	walk(doc, func(n *astro.Node) {
+		if !IsSlot(n) {
+			return
+		}
+		name, dynamic := "default", false
+		location := loc.Loc{}
+		if len(n.Loc) > 0 {
+			location = n.Loc[0]
+		}
+		for _, attr := range n.Attr {
+			if attr.Key == "name" {
+				name, dynamic = GetSlotName(attr)
+				location = attr.KeyLoc
+			}
+		}
+		hasFallback := hasSlotFallback(n)
+		if !dynamic {
+			if i, ok := seen[name]; ok {
+				slots[i].HasFallback = slots[i].HasFallback || hasFallback
+				return
+			}
+			seen[name] = len(slots)
+		}
+		slots = append(slots, SlotDeclaration{
+			Name:        name,
+			Dynamic:     dynamic,
+			HasFallback: hasFallback,
+			Loc:         location,
+		})
+	})
+	return slots
+}
+
+// GetPassedSlots returns every named slot passed to a child component, in authored order.
+// Only children with an explicit slot attribute are included.
+func GetPassedSlots(doc *astro.Node) []SlotUsage {
+	usages := make([]SlotUsage, 0)
+	walk(doc, func(n *astro.Node) {
+		if n.Type != astro.ElementNode || n.Parent == nil || !n.Parent.Component {
+			return
+		}
+		for _, attr := range n.Attr {
+			if attr.Key != "slot" {
+				continue
+			}
+			name, dynamic := GetSlotName(attr)
+			usages = append(usages, SlotUsage{
+				Component: n.Parent.Data,
+				Name:      name,
+				Dynamic:   dynamic,
+				Loc:       attr.KeyLoc,
+			})
+		}
+	})
+	return usages
+}
+
+// IsSlot returns true if n is a <slot> element
+func IsSlot(n *astro.Node) bool {
+	return n.Type == astro.ElementNode && n.DataAtom == a.Slot && !n.Expression
+}
+
+// GetSlotName returns the slot named by a `name` or `slot` attribute and
+// whether that name is a dynamic JS expression rather than a static string.
+func GetSlotName(attr astro.Attribute) (string, bool) {
+	switch attr.Type {
+	case astro.QuotedAttribute:
+		return attr.Val, false
+	case astro.ExpressionAttribute:
+		if strings.TrimSpace(attr.Val) == "" {
+			return "default", false
+		}
+		return strings.TrimSpace(attr.Val), true
+	case astro.ShorthandAttribute:
+		return strings.TrimSpace(attr.Key), true
+	case astro.TemplateLiteralAttribute:
+		return "`" + strings.TrimSpace(attr.Val) + "`", true
+	}
+	return "default", false
+}
+
+func hasSlotFallback(n *astro.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == astro.ElementNode || (c.Type == astro.TextNode && strings.TrimSpace(c.Data) != "") {
+			return true
+		}
+	}
+	return false
+}
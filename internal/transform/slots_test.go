@@ -0,0 +1,100 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+)
+
+func TestSlots(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		declared []string
+		passed   []string
+	}{
+		{
+			name:     "default",
+			source:   `<div><slot /></div>`,
+			declared: []string{"default"},
+			passed:   []string{},
+		},
+		{
+			name:     "fallback",
+			source:   `<div><slot name="a"><p>Fallback</p></slot></div>`,
+			declared: []string{"a (fallback)"},
+			passed:   []string{},
+		},
+		{
+			name:     "whitespace is not fallback",
+			source:   "<div><slot name=\"a\">\n\t</slot></div>",
+			declared: []string{"a"},
+			passed:   []string{},
+		},
+		{
+			name:     "deduplicated",
+			source:   `<div><slot name="a" /><slot name="a">Fallback</slot></div>`,
+			declared: []string{"a (fallback)"},
+			passed:   []string{},
+		},
+		{
+			name:     "dynamic",
+			source:   `<div><slot name={name} /><slot name={name} /></div>`,
+			declared: []string{"{name}", "{name}"},
+			passed:   []string{},
+		},
+		{
+			name:     "passed",
+			source:   `<Component><div slot="a" /><div slot={b} /><div /></Component>`,
+			declared: []string{},
+			passed:   []string{"Component.a", "Component.{b}"},
+		},
+		{
+			name:     "custom element slots are not passed",
+			source:   `<my-element><div slot="a" /></my-element>`,
+			declared: []string{},
+			passed:   []string{},
+		},
+		{
+			name:     "forwarded",
+			source:   `<Component><slot name="a" slot="b" /></Component>`,
+			declared: []string{"a"},
+			passed:   []string{"Component.b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			declared := make([]string, 0)
+			for _, slot := range GetDeclaredSlots(doc) {
+				name := slot.Name
+				if slot.Dynamic {
+					name = "{" + name + "}"
+				}
+				if slot.HasFallback {
+					name += " (fallback)"
+				}
+				declared = append(declared, name)
+			}
+			passed := make([]string, 0)
+			for _, usage := range GetPassedSlots(doc) {
+				name := usage.Name
+				if usage.Dynamic {
+					name = "{" + name + "}"
+				}
+				passed = append(passed, usage.Component+"."+name)
+			}
+			if fmt.Sprint(tt.declared) != fmt.Sprint(declared) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want declared: %v\n  got declared:  %v", tt.name, tt.declared, declared))
+			}
+			if fmt.Sprint(tt.passed) != fmt.Sprint(passed) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want passed: %v\n  got passed:  %v", tt.name, tt.passed, passed))
+			}
+		})
+	}
+}
@@ -11,27 +11,43 @@ import (
 )
 
 type TransformOptions struct {
-	Scope            string
-	Filename         string
-	Pathname         string
-	InternalURL      string
-	SourceMap        string
-	Site             string
-	ProjectRoot      string
-	PreprocessStyle  interface{}
+	Scope       string
+	Filename    string
+	Pathname    string
+	InternalURL string
+	SourceMap   string
+	Site        string
+	ProjectRoot string
+	// PreprocessStyle transforms the contents of <style> elements before they are scoped.
+	// Native callers should set it to a StylePreprocessor, the WASM build to a JS function.
+	PreprocessStyle interface{}
+	// PreprocessScript transforms the contents of <script> elements before they are hoisted.
+	// Native callers should set it to a ScriptPreprocessor, the WASM build to a JS function.
+	PreprocessScript interface{}
 	StaticExtraction bool
+	Directives       []Directive
+	// ClientDirectives are the hydration directives that may be used as `client:<name>`.
+	// Defaults to DefaultClientDirectives.
+	ClientDirectives []string
 }
 
 func Transform(doc *astro.Node, opts TransformOptions) *astro.Node {
-	shouldScope := len(doc.Styles) > 0 && ScopeStyle(doc.Styles, opts)
+	shouldScope := len(doc.Styles) > 0 && scopeStyles(doc.Styles, doc.StyleSourceMaps, opts)
+	ValidateClientDirectives(doc, opts)
+	ValidateDirectiveOptions(doc, opts)
+	ValidateIdentifiers(doc)
+	ValidateGlobalStyles(doc)
 	walk(doc, func(n *astro.Node) {
 		ExtractScript(doc, n, &opts)
-		AddComponentProps(doc, n)
+		AddComponentProps(doc, n, &opts)
+		ValidateDirectives(doc, n, opts)
 		if shouldScope {
 			ScopeElement(n, opts)
 		}
 	})
 	NormalizeSetDirectives(doc)
+	ValidateHydratedProps(doc)
+	ValidateUnusedBindings(doc)
 
 	// Important! Remove scripts from original location *after* walking the doc
 	for _, script := range doc.Scripts {
@@ -98,8 +114,8 @@ func NormalizeSetDirectives(doc *astro.Node) {
 				Data:       "astro:expression",
 				Expression: true,
 			}
-			loc := make([]loc.Loc, 1)
-			loc = append(loc, directive.ValLoc)
+			locs := make([]loc.Loc, 1)
+			locs = append(locs, directive.ValLoc)
 			data := directive.Val
 			if directive.Key == "set:html" {
 				data = fmt.Sprintf("$$unescapeHTML(%s)", data)
@@ -107,7 +123,7 @@ func NormalizeSetDirectives(doc *astro.Node) {
 			expr.AppendChild(&astro.Node{
 				Type: astro.TextNode,
 				Data: data,
-				Loc:  loc,
+				Loc:  locs,
 			})
 
 			shouldWarn := false
@@ -119,7 +135,12 @@ func NormalizeSetDirectives(doc *astro.Node) {
 				n.RemoveChild(c)
 			}
 			if shouldWarn {
-				fmt.Printf("<%s> uses the \"%s\" directive, but has child nodes which will be overwritten. Remove the child nodes to suppress this warning.\n", n.Data, directive.Key)
+				doc.AppendDiagnostic(loc.Diagnostic{
+					Severity: loc.WarningType,
+					Code:     loc.WARNING_SET_WITH_CHILDREN,
+					Text:     fmt.Sprintf("<%s> uses the \"%s\" directive, but has child nodes which will be overwritten. Remove the child nodes to suppress this warning.", n.Data, directive.Key),
+					Range:    GetAttrRange(*directive),
+				})
 			}
 			n.AppendChild(expr)
 		}
@@ -149,13 +170,23 @@ func ExtractScript(doc *astro.Node, n *astro.Node, opts *TransformOptions) {
 			shouldAdd := true
 			for _, attr := range n.Attr {
 				if attr.Key == "hoist" {
-					fmt.Printf("%s: <script hoist> is no longer needed. You may remove the `hoist` attribute.\n", opts.Filename)
+					doc.AppendDiagnostic(loc.Diagnostic{
+						Severity: loc.WarningType,
+						Code:     loc.WARNING_DEPRECATED_DIRECTIVE,
+						Text:     "<script hoist> is no longer needed. You may remove the `hoist` attribute.",
+						Range:    GetAttrRange(attr),
+					})
 				}
 				if attr.Key == "src" {
 					if attr.Type == astro.ExpressionAttribute {
 						if opts.StaticExtraction {
 							shouldAdd = false
-							fmt.Printf("%s: <script> uses the expression {%s} on the src attribute and will be ignored. Use a string literal on the src attribute instead.\n", opts.Filename, attr.Val)
+							doc.AppendDiagnostic(loc.Diagnostic{
+								Severity: loc.WarningType,
+								Code:     loc.WARNING_IGNORED_SCRIPT,
+								Text:     fmt.Sprintf("<script> uses the expression {%s} on the src attribute and will be ignored. Use a string literal on the src attribute instead.", attr.Val),
+								Range:    GetAttrRange(attr),
+							})
 						}
 						break
 					}
@@ -170,7 +201,7 @@ func ExtractScript(doc *astro.Node, n *astro.Node, opts *TransformOptions) {
 	}
 }
 
-func AddComponentProps(doc *astro.Node, n *astro.Node) {
+func AddComponentProps(doc *astro.Node, n *astro.Node, opts *TransformOptions) {
 	if n.Type == astro.ElementNode && (n.Component || n.CustomElement) {
 		for _, attr := range n.Attr {
 			id := n.Data
@@ -181,6 +212,10 @@ func AddComponentProps(doc *astro.Node, n *astro.Node) {
 			if strings.HasPrefix(attr.Key, "client:") {
 				parts := strings.Split(attr.Key, ":")
 				directive := parts[1]
+				// Unknown directives are reported by ValidateClientDirectives
+				if !IsClientDirective(*opts, directive) {
+					continue
+				}
 
 				// Add the hydration directive so it can be extracted statically.
 				doc.HydrationDirectives[directive] = true
@@ -0,0 +1,105 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/js_scanner"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+// An UnusedBinding is an import or top-level frontmatter declaration that is never read
+type UnusedBinding struct {
+	Name string
+	// Specifier is the module an unused import is imported from. It is empty for declarations.
+	Specifier string
+	// IsType is true for type-only imports
+	IsType bool
+	Loc    loc.Loc
+}
+
+// GetUnusedBindings returns the imports and top-level declarations in the
+// frontmatter that are not read by the frontmatter or the template, in
+// authored order. Exported declarations, and names starting with an
+// underscore, are always considered used.
+func GetUnusedBindings(doc *astro.Node) []UnusedBinding {
+	unused := make([]UnusedBinding, 0)
+	frontmatter := getFrontmatter(doc)
+	if frontmatter == nil || len(frontmatter.Loc) == 0 {
+		return unused
+	}
+	source := []byte(frontmatter.Data)
+
+	// Every binding is read once by its own declaration, so count how many
+	// times each name is read and where it is first read
+	reads := make(map[string]int)
+	first := make(map[string]int)
+	for _, reference := range js_scanner.GetReferences(source) {
+		if _, ok := first[reference.Name]; !ok {
+			first[reference.Name] = reference.Start
+		}
+		reads[reference.Name]++
+	}
+	walkTemplateReferences(doc, func(name string, start loc.Loc, shadowed bool) {
+		if !shadowed {
+			reads[name]++
+		}
+	})
+
+	declared := make(map[string]int)
+	bindings := make([]UnusedBinding, 0)
+	pos, statement := js_scanner.NextImportStatement(source, 0)
+	for pos != -1 {
+		for _, imported := range statement.Imports {
+			declared[imported.LocalName]++
+			bindings = append(bindings, UnusedBinding{Name: imported.LocalName, Specifier: statement.Specifier, IsType: imported.IsType})
+		}
+		pos, statement = js_scanner.NextImportStatement(source, pos)
+	}
+	for _, declaration := range js_scanner.GetTopLevelDeclarations(source) {
+		declared[declaration.Name]++
+		if declaration.Exported {
+			// Exports are read by other modules
+			reads[declaration.Name]++
+			continue
+		}
+		bindings = append(bindings, UnusedBinding{Name: declaration.Name})
+	}
+
+	for _, binding := range bindings {
+		if reads[binding.Name] > declared[binding.Name] || strings.HasPrefix(binding.Name, "_") {
+			continue
+		}
+		binding.Loc = loc.Loc{Start: frontmatter.Loc[0].Start + first[binding.Name]}
+		unused = append(unused, binding)
+	}
+	return unused
+}
+
+// ValidateUnusedBindings warns about imports and top-level frontmatter declarations that are never used
+func ValidateUnusedBindings(doc *astro.Node) {
+	for _, binding := range GetUnusedBindings(doc) {
+		d := loc.Diagnostic{
+			Severity: loc.WarningType,
+			Code:     loc.WARNING_UNUSED_VARIABLE,
+			Text:     fmt.Sprintf("\"%s\" is declared but never used.", binding.Name),
+			Range:    loc.Range{Loc: binding.Loc, Len: len(binding.Name)},
+		}
+		if binding.Specifier != "" {
+			d.Code = loc.WARNING_UNUSED_IMPORT
+			d.Text = fmt.Sprintf("\"%s\" is imported from \"%s\" but never used.", binding.Name, binding.Specifier)
+		}
+		doc.AppendDiagnostic(d)
+	}
+}
+
+// getFrontmatter returns the text node that holds the frontmatter source, if any
+func getFrontmatter(doc *astro.Node) *astro.Node {
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == astro.FrontmatterNode && c.FirstChild != nil {
+			return c.FirstChild
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
+)
+
+func TestValidateUnusedBindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{
+			name: "used in template",
+			source: `---
+import Layout from '../layouts/Layout.astro';
+import * as icons from '../icons';
+import { format } from 'date-fns';
+const { title, date } = Astro.props;
+---
+<Layout {title}><icons.Star /><time>{format(date)}</time></Layout>`,
+			want: []string{},
+		},
+		{
+			name: "used in frontmatter",
+			source: `---
+import { slugify } from '../utils';
+const { title } = Astro.props;
+const slug = slugify(title);
+---
+<a href={slug}>Link</a>`,
+			want: []string{},
+		},
+		{
+			name: "unused imports",
+			source: `---
+import Card from '../components/Card.astro';
+import { a, b as c } from '../utils';
+import '../styles/global.css';
+---
+<p>{a}</p>`,
+			want: []string{"import Card", "import c"},
+		},
+		{
+			name: "unused type imports",
+			source: `---
+import type { Props } from '../types';
+import { type Post, getPosts } from '../posts';
+const posts: Post[] = await getPosts();
+---
+<p>{posts.length}</p>`,
+			want: []string{"import Props"},
+		},
+		{
+			name: "unused variables",
+			source: `---
+const { title, description } = Astro.props;
+const count = 1;
+function helper() {}
+class Model {}
+---
+<h1>{title}</h1>`,
+			want: []string{"description", "count", "helper", "Model"},
+		},
+		{
+			name: "only read by itself",
+			source: `---
+let count = 0;
+count = count + 1;
+---
+`,
+			want: []string{},
+		},
+		{
+			name: "shadowed in template",
+			source: `---
+import { item } from '../data';
+const items = [];
+---
+<ul>{items.map((item) => <li>{item}</li>)}</ul>`,
+			want: []string{"import item"},
+		},
+		{
+			name: "used in define:vars",
+			source: `---
+import { accent } from '../theme';
+const color = 'red';
+const message = 'hi';
+const unused = 1;
+---
+<style define:vars={{ color, accent }}>h1 { color: var(--color); }</style>
+<script define:vars={{ message }}>console.log(message)</script>
+<h1>Hello</h1>`,
+			want: []string{"unused"},
+		},
+		{
+			name: "labels",
+			source: `---
+const outer = 1;
+const items = [];
+---
+<p>{(() => { outer: for (const item of items) { break outer; } })()}</p>`,
+			want: []string{"outer"},
+		},
+		{
+			name: "exports and underscores",
+			source: `---
+import _ from 'lodash';
+const _unused = 1;
+export const prerender = true;
+export async function getStaticPaths() {
+  return [];
+}
+---
+`,
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := astro.Parse(strings.NewReader(tt.source))
+			if err != nil {
+				t.Error(err)
+			}
+			ExtractStyles(doc)
+			Transform(doc, TransformOptions{})
+			got := make([]string, 0)
+			for _, d := range doc.Diagnostics {
+				// Each warning should point at the first occurrence of the binding
+				name := tt.source[d.Range.Loc.Start : d.Range.Loc.Start+d.Range.Len]
+				switch d.Code {
+				case loc.WARNING_UNUSED_IMPORT:
+					got = append(got, "import "+name)
+				case loc.WARNING_UNUSED_VARIABLE:
+					got = append(got, name)
+				}
+			}
+			if fmt.Sprint(tt.want) != fmt.Sprint(got) {
+				t.Error(fmt.Sprintf("\nFAIL: %s\n  want: %v\n  got:  %v", tt.name, tt.want, got))
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package transform
 
 import (
 	astro "github.com/withastro/compiler/internal"
+	"github.com/withastro/compiler/internal/loc"
 )
 
 func hasTruthyAttr(n *astro.Node, key string) bool {
@@ -24,6 +25,15 @@ func HasInlineDirective(n *astro.Node) bool {
 	return HasAttr(n, "is:inline")
 }
 
+func HasClassList(n *astro.Node) bool {
+	return HasAttr(n, "class:list")
+}
+
+// IsClassAttr returns true for the attributes that are merged by the `class:list` directive
+func IsClassAttr(attr astro.Attribute) bool {
+	return attr.Key == "class" || attr.Key == "class:list"
+}
+
 func HasAttr(n *astro.Node, key string) bool {
 	for _, attr := range n.Attr {
 		if attr.Key == key {
@@ -52,3 +62,16 @@ func GetQuotedAttr(n *astro.Node, key string) string {
 	}
 	return ""
 }
+
+// GetAttrRange returns the range of attr in the source text, from the start of
+// its key to the end of its value
+func GetAttrRange(attr astro.Attribute) loc.Range {
+	switch attr.Type {
+	case astro.QuotedAttribute, astro.ExpressionAttribute, astro.TemplateLiteralAttribute:
+		// Include the closing quote, brace or backtick
+		if end := attr.ValLoc.Start + len(attr.Val) + 1; attr.ValLoc.Start > attr.KeyLoc.Start {
+			return loc.Range{Loc: attr.KeyLoc, Len: end - attr.KeyLoc.Start}
+		}
+	}
+	return loc.Range{Loc: attr.KeyLoc, Len: len(attr.Key)}
+}
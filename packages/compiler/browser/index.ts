@@ -9,9 +9,29 @@ export const parse: typeof types.parse = (input, options) => {
   return ensureServiceIsRunning().parse(input, options);
 };
 
+export const transformSync: typeof types.transformSync = (input, options) => {
+  return ensureServiceIsRunning().transformSync(input, options);
+};
+
+export const parseSync: typeof types.parseSync = (input, options) => {
+  return ensureServiceIsRunning().parseSync(input, options);
+};
+
+export const fromJSON: typeof types.fromJSON = (ast) => {
+  return ensureServiceIsRunning().fromJSON(ast);
+};
+
+export const fromJSONSync: typeof types.fromJSONSync = (ast) => {
+  return ensureServiceIsRunning().fromJSONSync(ast);
+};
+
 interface Service {
   transform: typeof types.transform;
   parse: typeof types.parse;
+  transformSync: typeof types.transformSync;
+  parseSync: typeof types.parseSync;
+  fromJSON: typeof types.fromJSON;
+  fromJSONSync: typeof types.fromJSONSync;
 }
 
 let initializePromise: Promise<Service> | undefined;
@@ -64,5 +84,18 @@ const startRunningService = async (wasmURL: string): Promise<Service> => {
   return {
     transform: (input, options) => new Promise((resolve) => resolve(service.transform(input, options || {}))),
     parse: (input, options) => new Promise((resolve) => resolve(service.parse(input, options || {}))).then((result: any) => ({ ...result, ast: JSON.parse(result.ast) })),
+    transformSync: (input, options) => unwrapSync(service.transformSync(input, options || {})),
+    parseSync: (input, options) => {
+      const result = unwrapSync(service.parseSync(input, options || {}));
+      return { ...result, ast: JSON.parse(result.ast) };
+    },
+    fromJSON: (ast) => new Promise((resolve) => resolve(service.fromJSON(JSON.stringify(ast)))),
+    fromJSONSync: (ast) => unwrapSync(service.fromJSONSync(JSON.stringify(ast))),
   };
 };
+
+// The sync exports return errors instead of throwing them across the WASM boundary
+const unwrapSync = (result: any) => {
+  if (result instanceof Error) throw result;
+  return result;
+};
@@ -207,6 +207,96 @@ export const spreadAttributes = (values: Record<any, any>) => {
   return output;
 };
 
+const toClassNames = (value: any): string[] => {
+  if (!value) {
+    return [];
+  }
+  if (Array.isArray(value)) {
+    return value.flatMap(toClassNames);
+  }
+  if (typeof value === 'object') {
+    return Object.keys(value).filter((key) => value[key]).flatMap(toClassNames);
+  }
+  return String(value).split(/\s+/).filter(Boolean);
+};
+
+export const classList = (values: any[]) => {
+  return Array.from(new Set(toClassNames(values))).join(' ');
+};
+
+// Keep this in sync with `unitlessStyleProperties` in the compiler
+const unitlessStyleProperties = new Set([
+  'animation-iteration-count',
+  'aspect-ratio',
+  'border-image-outset',
+  'border-image-slice',
+  'border-image-width',
+  'box-flex',
+  'box-flex-group',
+  'box-ordinal-group',
+  'column-count',
+  'columns',
+  'fill-opacity',
+  'flex',
+  'flex-grow',
+  'flex-negative',
+  'flex-order',
+  'flex-positive',
+  'flex-shrink',
+  'flood-opacity',
+  'font-weight',
+  'grid-area',
+  'grid-column',
+  'grid-column-end',
+  'grid-column-span',
+  'grid-column-start',
+  'grid-row',
+  'grid-row-end',
+  'grid-row-span',
+  'grid-row-start',
+  'line-clamp',
+  'line-height',
+  'opacity',
+  'order',
+  'orphans',
+  'scale',
+  'stop-opacity',
+  'stroke-dasharray',
+  'stroke-dashoffset',
+  'stroke-miterlimit',
+  'stroke-opacity',
+  'stroke-width',
+  'tab-size',
+  'widows',
+  'z-index',
+  'zoom',
+]);
+
+const toCSSPropertyName = (key: string) => {
+  if (key.startsWith('--')) {
+    return key;
+  }
+  const name = key.replace(/[A-Z]/g, (c) => `-${c.toLowerCase()}`);
+  return name.startsWith('ms-') ? `-${name}` : name;
+};
+
+export const serializeStyle = (style: Record<string, any>) => {
+  const declarations: string[] = [];
+  for (const [key, value] of Object.entries(style)) {
+    if (value == null || value === false || value === '') {
+      continue;
+    }
+    const property = toCSSPropertyName(key);
+    let serialized = String(value).trim();
+    const unitless = property.startsWith('--') || unitlessStyleProperties.has(property.replace(/^-(webkit|moz|ms|o)-/, ''));
+    if (typeof value === 'number' && value !== 0 && !unitless) {
+      serialized += 'px';
+    }
+    declarations.push(`${property}:${serialized}`);
+  }
+  return declarations.join(';');
+};
+
 export const defineStyleVars = (astroId: string, vars: Record<any, any>) => {
   let output = '\n';
   for (const [key, value] of Object.entries(vars)) {
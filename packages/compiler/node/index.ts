@@ -1,6 +1,6 @@
-export type { PreprocessorResult, ParseOptions, TransformOptions, HoistedScript, TransformResult, ParseResult } from '../shared/types';
+export type { PreprocessorResult, ParseOptions, TransformOptions, DirectiveDefinition, HoistedScript, TransformResult, ComponentManifest, ManifestProp, ManifestSlot, ManifestComponent, DiagnosticMessage, DiagnosticLocation, CompileError, ParseResult, FromJSONResult } from '../shared/types';
 import type * as types from '../shared/types';
-import { promises as fs } from 'fs';
+import { promises as fs, readFileSync } from 'fs';
 import Go from './wasm_exec.js';
 import { fileURLToPath } from 'url';
 
@@ -12,6 +12,22 @@ export const parse: typeof types.parse = async (input, options) => {
   return getService().then((service) => service.parse(input, options));
 };
 
+export const transformSync: typeof types.transformSync = (input, options) => {
+  return getServiceSync().transformSync(input, options);
+};
+
+export const parseSync: typeof types.parseSync = (input, options) => {
+  return getServiceSync().parseSync(input, options);
+};
+
+export const fromJSON: typeof types.fromJSON = async (ast) => {
+  return getService().then((service) => service.fromJSON(ast));
+};
+
+export const fromJSONSync: typeof types.fromJSONSync = (ast) => {
+  return getServiceSync().fromJSONSync(ast);
+};
+
 export const compile = async (template: string): Promise<string> => {
   const { default: mod } = await import(`data:text/javascript;charset=utf-8;base64,${Buffer.from(template).toString('base64')}`);
   return mod;
@@ -20,9 +36,14 @@ export const compile = async (template: string): Promise<string> => {
 interface Service {
   transform: typeof types.transform;
   parse: typeof types.parse;
+  transformSync: typeof types.transformSync;
+  parseSync: typeof types.parseSync;
+  fromJSON: typeof types.fromJSON;
+  fromJSONSync: typeof types.fromJSONSync;
 }
 
 let longLivedService: Promise<Service> | undefined;
+let longLivedServiceSync: Service | undefined;
 
 let getService = (): Promise<Service> => {
   if (!longLivedService) {
@@ -36,6 +57,14 @@ let getService = (): Promise<Service> => {
   return longLivedService;
 };
 
+// The sync API can't wait for the async service, so it instantiates its own
+let getServiceSync = (): Service => {
+  if (!longLivedServiceSync) {
+    longLivedServiceSync = startRunningServiceSync();
+  }
+  return longLivedServiceSync;
+};
+
 const instantiateWASM = async (wasmURL: string, importObject: Record<string, any>): Promise<WebAssembly.WebAssemblyInstantiatedSource> => {
   let response = undefined;
 
@@ -52,9 +81,34 @@ const startRunningService = async (): Promise<Service> => {
   const go = new Go();
   const wasm = await instantiateWASM(fileURLToPath(new URL('../astro.wasm', import.meta.url)), go.importObject);
   go.run(wasm.instance);
+  return createService();
+};
+
+const startRunningServiceSync = (): Service => {
+  const go = new Go();
+  const wasm = readFileSync(fileURLToPath(new URL('../astro.wasm', import.meta.url)));
+  const instance = new WebAssembly.Instance(new WebAssembly.Module(wasm), go.importObject);
+  go.run(instance);
+  return createService();
+};
+
+const createService = (): Service => {
   const _service: any = (globalThis as any)['@astrojs/compiler'];
   return {
     transform: (input, options) => new Promise((resolve) => resolve(_service.transform(input, options || {}))),
     parse: (input, options) => new Promise((resolve) => resolve(_service.parse(input, options || {}))).then((result: any) => ({ ...result, ast: JSON.parse(result.ast) })),
+    transformSync: (input, options) => unwrapSync(_service.transformSync(input, options || {})),
+    parseSync: (input, options) => {
+      const result = unwrapSync(_service.parseSync(input, options || {}));
+      return { ...result, ast: JSON.parse(result.ast) };
+    },
+    fromJSON: (ast) => new Promise((resolve) => resolve(_service.fromJSON(JSON.stringify(ast)))),
+    fromJSONSync: (ast) => unwrapSync(_service.fromJSONSync(JSON.stringify(ast))),
   };
 };
+
+// The sync exports return errors instead of throwing them across the WASM boundary
+const unwrapSync = (result: any) => {
+  if (result instanceof Error) throw result;
+  return result;
+};
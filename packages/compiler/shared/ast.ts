@@ -79,8 +79,68 @@ export interface CommentNode extends LiteralNode {
 
 export interface FrontmatterNode extends LiteralNode {
   type: 'frontmatter';
+  /** Only set with the `frontmatterAST` option */
+  children?: (ImportDeclarationNode | ExportDeclarationNode | CodeNode)[];
+}
+
+export interface ModuleSpecifier {
+  kind: 'default' | 'named' | 'namespace';
+  /** The name in the other module, for named imports */
+  imported?: string;
+  /** The name other modules import, for named exports and `export * as a` */
+  exported?: string;
+  /** The local binding. Missing for `export default` expressions and `export *` */
+  local?: string;
+  /** `true` for `import { type A }` and `export { type A }` */
+  typeOnly?: boolean;
+}
+
+export interface ImportDeclarationNode extends BaseNode {
+  type: 'import';
+  value: string;
+  source: string;
+  specifiers: ModuleSpecifier[];
+  assertions?: string;
+  typeOnly?: boolean;
+}
+
+export interface ExportDeclarationNode extends BaseNode {
+  type: 'export';
+  value: string;
+  /** The module of re-exports like `export { a } from './a'` */
+  source?: string;
+  specifiers: ModuleSpecifier[];
+  assertions?: string;
+  typeOnly?: boolean;
+}
+
+/** Frontmatter code other than imports and exports */
+export interface CodeNode extends BaseNode {
+  type: 'code';
+  value: string;
 }
 
 export interface ExpressionNode extends ParentLikeNode {
   type: 'expression';
+  /** The ESTree tree of the JS, only set with the `expressionAST` option */
+  estree?: ESTreeNode;
+  /** Why the JS couldn't be parsed, with the `expressionAST` option */
+  estreeError?: string;
+}
+
+/** An ESTree node */
+export interface ESTreeNode {
+  type: string;
+  /** Where the node is in the source, unless the `position` option is false. Columns are 0-based. */
+  loc?: { start: { line: number; column: number }; end: { line: number; column: number } };
+  /** The start and end offsets of the node in the source */
+  range?: [number, number];
+  [key: string]: any;
+}
+
+/** Stands in for the elements of an expression in its ESTree tree */
+export interface AstroChildrenNode extends ESTreeNode {
+  type: 'AstroChildren';
+  /** The indices of the elements in the `children` of the expression */
+  children: number[];
 }
@@ -9,6 +9,31 @@ export interface PreprocessorResult {
 // eslint-disable-next-line @typescript-eslint/no-empty-interface
 export interface ParseOptions {
   position?: boolean;
+  /** Break the frontmatter down into its imports, exports and the code between them, as the `children` of the frontmatter node */
+  frontmatterAST?: boolean;
+  /** Parse the JS of each expression into an ESTree tree, as the `estree` of the expression node */
+  expressionAST?: boolean;
+}
+
+/**
+ * A custom namespaced directive, like `analytics:track`, that is compiled to a call to a runtime helper.
+ * The helper is called as `helper(name, value)`. On elements, its result is printed into the start tag with no separating space,
+ * so it must return serialized attributes with a leading space, like ` data-track="click"`, or an empty string.
+ * On components, its result is spread into the props, so it must return an object.
+ */
+export interface DirectiveDefinition {
+  /**
+   * The part of the attribute name before the colon, e.g. `analytics` for `analytics:track`.
+   * It must start with a letter and only contain letters, digits, hyphens and underscores.
+   * Namespaces that only differ by hyphens or underscores, like `foo-bar` and `foo_bar`, can't both be registered.
+   */
+  namespace: string;
+  /** The module that exports the runtime helper */
+  specifier: string;
+  /** The name of the runtime helper export. Defaults to `default` */
+  export?: string;
+  /** The nodes this directive can be used on. Defaults to `both` */
+  target?: 'element' | 'component' | 'both';
 }
 
 export interface TransformOptions {
@@ -23,7 +48,12 @@ export interface TransformOptions {
   as?: 'document' | 'fragment';
   projectRoot?: string;
   preprocessStyle?: (content: string, attrs: Record<string, string>) => Promise<PreprocessorResult>;
+  /** Transforms the contents of `<script>` elements before they are hoisted. */
+  preprocessScript?: (content: string, attrs: Record<string, string>) => Promise<PreprocessorResult>;
   experimentalStaticExtraction?: boolean;
+  directives?: DirectiveDefinition[];
+  /** The hydration directives that may be used as `client:<name>`. Defaults to `['load', 'idle', 'visible', 'media', 'only']` */
+  clientDirectives?: string[];
 }
 
 export type HoistedScript = { type: string } & (
@@ -37,21 +67,112 @@ export type HoistedScript = { type: string } & (
     }
 );
 
+/** 1 = Error, 2 = Warning, 3 = Information, 4 = Hint */
+export type DiagnosticSeverity = 1 | 2 | 3 | 4;
+
+export interface DiagnosticLocation {
+  file: string;
+  /** 1-based line number */
+  line: number;
+  /** 1-based column number, per-line */
+  column: number;
+  length: number;
+}
+
+export interface DiagnosticMessage {
+  severity: DiagnosticSeverity;
+  code: number;
+  text: string;
+  location: DiagnosticLocation;
+}
+
+/** The error that `transform` and `parse` reject with when a file can't be compiled */
+export interface CompileError extends Error {
+  code: number;
+  /** The location of the error. Missing if the error, like an internal compiler error, has no location */
+  loc?: {
+    file: string;
+    /** 1-based line number */
+    line: number;
+    /** 1-based column number, per-line */
+    column: number;
+  };
+  /** The lines of source text around the error, with the error marked */
+  frame?: string;
+}
+
+export interface ManifestProp {
+  name: string;
+  /** The source of the type annotation, e.g. `string` or `(id: string) => void` */
+  type: string;
+  optional: boolean;
+  /** The text of the JSDoc comment on the prop, if any */
+  description: string;
+}
+
+export interface ManifestSlot {
+  name: string;
+  /** `true` if the slot name is an expression, in which case `name` is its source */
+  dynamic: boolean;
+  fallback: boolean;
+}
+
+export interface ManifestPassedSlot {
+  /** The name of the child component the slot is passed to */
+  component: string;
+  /** The module the child component is imported from */
+  specifier: string;
+  name: string;
+  /** `true` if the slot name is an expression, in which case `name` is its source */
+  dynamic: boolean;
+}
+
+export interface ManifestComponent {
+  name: string;
+  /** The module the component is imported from. Empty for custom elements. */
+  specifier: string;
+  export: string;
+  /** The hydration directive, e.g. `load` for `client:load` */
+  directive: string;
+}
+
+export interface ComponentManifest {
+  name: string;
+  /** The members of the component's `Props` interface */
+  props: ManifestProp[];
+  slots: ManifestSlot[];
+  /** The named slots passed to child components, to compare with the `slots` of their manifests */
+  passedSlots: ManifestPassedSlot[];
+  hydratedComponents: ManifestComponent[];
+  clientOnlyComponents: ManifestComponent[];
+  scripts: HoistedScript[];
+  styleCount: number;
+}
+
 export interface TransformResult {
   css: string[];
   scripts: HoistedScript[];
   code: string;
   map: string;
+  diagnostics: DiagnosticMessage[];
+  manifest: ComponentManifest;
+  /** A JSON Schema describing the component's `Props`, or an empty string if it doesn't declare any */
+  propsSchema: string;
 }
 
 export interface ParseResult {
   ast: RootNode;
 }
 
+export interface FromJSONResult {
+  /** The source of the AST, which can be passed to `transform` */
+  code: string;
+}
+
 // This function transforms a single JavaScript file. It can be used to minify
 // JavaScript, convert TypeScript/JSX to JavaScript, or convert newer JavaScript
 // to older JavaScript. It returns a promise that is either resolved with a
-// "TransformResult" object or rejected with a "TransformFailure" object.
+// "TransformResult" object or rejected with a "CompileError" object.
 //
 // Works in node: yes
 // Works in browser: yes
@@ -59,6 +180,26 @@ export declare function transform(input: string, options?: TransformOptions): Pr
 
 export declare function parse(input: string, options?: ParseOptions): Promise<ParseResult>;
 
+// These are synchronous versions of "transform" and "parse". They throw a
+// "CompileError" instead of rejecting. "transformSync" throws if the
+// "preprocessStyle" or "preprocessScript" options are set, because
+// preprocessors are async.
+//
+// Works in node: yes
+// Works in browser: yes (after "initialize" has resolved)
+export declare function transformSync(input: string, options?: TransformOptions): TransformResult;
+
+export declare function parseSync(input: string, options?: ParseOptions): ParseResult;
+
+// This prints an AST returned by "parse", which may have been modified, back
+// to source. The source can be passed to "transform" to compile the AST.
+//
+// Works in node: yes
+// Works in browser: yes
+export declare function fromJSON(ast: RootNode): Promise<FromJSONResult>;
+
+export declare function fromJSONSync(ast: RootNode): FromJSONResult;
+
 // This configures the browser-based version of astro. It is necessary to
 // call this first and wait for the returned promise to be resolved before
 // making other API calls when using astro in the browser.
@@ -0,0 +1,53 @@
+import { test } from 'uvu';
+import * as assert from 'uvu/assert';
+import { parse } from '@astrojs/compiler';
+
+const FIXTURE = `<ul>{items.map((item) => <li>{item}</li>)}</ul>`;
+
+test('expressions have no ESTree by default', async () => {
+  const { ast } = await parse(FIXTURE);
+  const [ul] = ast.children;
+  if (ul.type !== 'element') throw new Error('Expected an element node');
+  assert.not.ok('estree' in ul.children[0]);
+});
+
+test('expressionAST parses the JS of expressions', async () => {
+  const { ast } = await parse(FIXTURE, { expressionAST: true, position: false });
+  const [ul] = ast.children;
+  if (ul.type !== 'element') throw new Error('Expected an element node');
+  const [expression] = ul.children;
+  if (expression.type !== 'expression') throw new Error('Expected an expression node');
+  assert.equal(expression.estree.type, 'CallExpression');
+  assert.equal(expression.estree.arguments[0].body, { type: 'AstroChildren', children: [1] });
+  assert.equal(expression.children[1].type, 'element');
+});
+
+test('expressionAST places the nodes in the source', async () => {
+  const { ast } = await parse(FIXTURE, { expressionAST: true });
+  const [ul] = ast.children;
+  if (ul.type !== 'element') throw new Error('Expected an element node');
+  const [expression] = ul.children;
+  if (expression.type !== 'expression') throw new Error('Expected an expression node');
+  const [arrow] = expression.estree.arguments;
+  assert.equal(FIXTURE.slice(...arrow.range), '(item) => <li>{item}</li>');
+  assert.equal(arrow.loc, { start: { line: 1, column: 15 }, end: { line: 1, column: 40 } });
+  assert.equal(FIXTURE.slice(...arrow.body.range), '<li>{item}</li>');
+});
+
+test('expressionAST reports TypeScript syntax it cannot parse', async () => {
+  const { ast } = await parse('{value as string}', { expressionAST: true });
+  const [expression] = ast.children;
+  if (expression.type !== 'expression') throw new Error('Expected an expression node');
+  assert.not.ok(expression.estree);
+  assert.equal(expression.estreeError, 'unexpected as in expression');
+});
+
+test('expressionAST reports JS that cannot be parsed', async () => {
+  const { ast } = await parse('{a +}', { expressionAST: true });
+  const [expression] = ast.children;
+  if (expression.type !== 'expression') throw new Error('Expected an expression node');
+  assert.not.ok(expression.estree);
+  assert.equal(expression.estreeError, 'unexpected ) in expression');
+});
+
+test.run();
@@ -0,0 +1,43 @@
+import { test } from 'uvu';
+import * as assert from 'uvu/assert';
+import { parse, fromJSON, fromJSONSync, transform } from '@astrojs/compiler';
+import { is } from '@astrojs/compiler/utils';
+
+const FIXTURE = `---
+let value = 'world';
+---
+
+<div class="greeting">Hello {value}</div>
+`;
+
+test('round trips an AST', async () => {
+  const { ast } = await parse(FIXTURE);
+  const { code } = await fromJSON(ast);
+  assert.equal((await parse(code, { position: false })).ast, (await parse(FIXTURE, { position: false })).ast);
+  assert.equal(fromJSONSync(ast), { code });
+});
+
+test('prints a modified AST', async () => {
+  const { ast } = await parse(FIXTURE);
+  const div = ast.children.find((node) => is.element(node) && node.name === 'div');
+  if (!div || !is.element(div)) throw new Error('Expected a <div> element');
+  div.name = 'h1';
+  div.attributes.push({ type: 'attribute', kind: 'empty', name: 'hidden', value: '' });
+  const { code } = await fromJSON(ast);
+  assert.match(code, '<h1 class="greeting" hidden>Hello {value}</h1>');
+
+  const result = await transform(code);
+  assert.match(result.code, '<h1 class="greeting" hidden>Hello ${value}</h1>');
+});
+
+test('rejects an invalid AST', async () => {
+  let error = 0;
+  try {
+    await fromJSON({ type: 'text', value: 'Hello' } as any);
+  } catch (e) {
+    error++;
+  }
+  assert.equal(error, 1, `Expected "fromJSON" to reject an AST without a root`);
+});
+
+test.run();
@@ -0,0 +1,33 @@
+import { test } from 'uvu';
+import * as assert from 'uvu/assert';
+import { parse } from '@astrojs/compiler';
+
+const FIXTURE = `---
+import Layout from '../layouts/Layout.astro';
+import { format } from './utils';
+const { title } = Astro.props;
+export const prerender = true;
+---
+<Layout title={format(title)} />
+`;
+
+test('frontmatter is only a value by default', async () => {
+  const { ast } = await parse(FIXTURE);
+  const [frontmatter] = ast.children;
+  assert.equal(frontmatter.type, 'frontmatter');
+  assert.not.ok('children' in frontmatter);
+});
+
+test('frontmatterAST breaks the frontmatter down', async () => {
+  const { ast } = await parse(FIXTURE, { frontmatterAST: true, position: false });
+  const [frontmatter] = ast.children;
+  if (frontmatter.type !== 'frontmatter') throw new Error('Expected a frontmatter node');
+  assert.equal(frontmatter.children, [
+    { type: 'import', value: "import Layout from '../layouts/Layout.astro';", source: '../layouts/Layout.astro', specifiers: [{ kind: 'default', local: 'Layout' }] },
+    { type: 'import', value: "import { format } from './utils';", source: './utils', specifiers: [{ kind: 'named', imported: 'format', local: 'format' }] },
+    { type: 'code', value: 'const { title } = Astro.props;' },
+    { type: 'export', value: 'export const prerender = true;', specifiers: [{ kind: 'named', exported: 'prerender', local: 'prerender' }] },
+  ]);
+});
+
+test.run();
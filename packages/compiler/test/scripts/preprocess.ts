@@ -0,0 +1,41 @@
+import { test } from 'uvu';
+import * as assert from 'uvu/assert';
+import { transform } from '@astrojs/compiler';
+
+const FIXTURE = `
+---
+---
+<script>
+const greeting: string = 'Hello';
+console.log(greeting);
+</script>
+<script is:inline>const name: string = 'world';</script>
+<div>Hello world!</div>
+`;
+
+async function preprocessScript(content: string) {
+  return { code: content.replace(/: string/g, '') };
+}
+
+let result;
+let staticResult;
+test.before(async () => {
+  result = await transform(FIXTURE, { preprocessScript });
+  staticResult = await transform(FIXTURE, { preprocessScript, experimentalStaticExtraction: true });
+});
+
+test('preprocesses hoisted scripts', () => {
+  assert.match(result.code, "const greeting = 'Hello';", 'Expected the hoisted script to be preprocessed.');
+  assert.not.match(result.code, 'greeting: string', 'Expected the type annotation to be removed.');
+});
+
+test('preprocesses inline scripts', () => {
+  assert.match(result.code, "const name = 'world';", 'Expected the inline script to be preprocessed.');
+});
+
+test('preprocesses extracted scripts', () => {
+  assert.equal(staticResult.scripts.length, 1);
+  assert.match(staticResult.scripts[0].code, "const greeting = 'Hello';");
+});
+
+test.run();
@@ -0,0 +1,33 @@
+import { test } from 'uvu';
+import * as assert from 'uvu/assert';
+import { transform, transformSync, parse, parseSync } from '@astrojs/compiler';
+
+const FIXTURE = `
+---
+let value = 'world';
+---
+
+<style>h1 { color: red; }</style>
+<h1>Hello {value}</h1>
+`;
+
+test('transformSync matches transform', async () => {
+  const result = transformSync(FIXTURE);
+  assert.type(result, 'object', `Expected "transformSync" to return an object!`);
+  assert.equal(result, await transform(FIXTURE));
+});
+
+test('parseSync matches parse', async () => {
+  const result = parseSync(FIXTURE);
+  assert.equal(result.ast.type, 'root', `Expected "ast" root node to be of type "root"`);
+  assert.equal(result, await parse(FIXTURE));
+});
+
+test('transformSync throws with an async preprocessor', () => {
+  assert.throws(
+    () => transformSync(FIXTURE, { preprocessStyle: async () => ({ code: '' }) }),
+    (err: Error) => err.message.includes('use transform instead')
+  );
+});
+
+test.run();